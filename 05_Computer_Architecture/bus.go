@@ -0,0 +1,143 @@
+package computer
+
+import (
+	"fmt"
+
+	logic "github.com/kazufusa/nand2tetris/01_Boolean_Logic"
+)
+
+const (
+	BUS_SIZE = 1 << 15
+	// BUS_MAX_DEVICES is the number of attachments a Bus can hold.
+	BUS_MAX_DEVICES = 255
+)
+
+// IDevice is a peripheral on a Bus. addr is relative to the start of the
+// range the device is attached at. Like IMemory, Fetch returns the current
+// value and stores in when load is set.
+type IDevice interface {
+	Fetch(in Word, load Bit, addr int) Word
+}
+
+// DeviceFunc adapts a function to IDevice.
+type DeviceFunc func(in Word, load Bit, addr int) Word
+
+func (f DeviceFunc) Fetch(in Word, load Bit, addr int) Word {
+	return f(in, load, addr)
+}
+
+type busDevice struct {
+	base, size int
+	dev        IDevice
+}
+
+// Bus decodes the 15-bit address space to devices attached at address
+// ranges. A device attached later overlays the ranges of earlier ones, so
+// tools can wrap a device (see Device) or replace part of it. Addresses
+// without a device read 0 and ignore writes.
+type Bus struct {
+	devices []busDevice
+	// slots holds the index+1 of the device answering each address.
+	slots [BUS_SIZE]uint8
+}
+
+var _ IMemory = (*Bus)(nil)
+
+// Attach maps addresses base to base+size-1 to dev.
+func (b *Bus) Attach(base, size int, dev IDevice) {
+	if base < 0 || size < 1 || base+size > BUS_SIZE {
+		panic(fmt.Sprintf("bus: invalid range %d+%d", base, size))
+	}
+	if len(b.devices) == BUS_MAX_DEVICES {
+		panic("bus: too many devices")
+	}
+	b.devices = append(b.devices, busDevice{base: base, size: size, dev: dev})
+	for i := base; i < base+size; i++ {
+		b.slots[i] = uint8(len(b.devices))
+	}
+}
+
+// Detach removes every attachment of dev, uncovering what was beneath. dev
+// must be comparable, e.g. a pointer.
+func (b *Bus) Detach(dev IDevice) {
+	devices := b.devices
+	b.devices = nil
+	b.slots = [BUS_SIZE]uint8{}
+	for _, d := range devices {
+		if d.dev != dev {
+			b.Attach(d.base, d.size, d.dev)
+		}
+	}
+}
+
+// Device returns the device answering addr and the base address it is
+// attached at, or nil.
+func (b *Bus) Device(addr int) (IDevice, int) {
+	i := b.slots[addr]
+	if i == 0 {
+		return nil, 0
+	}
+	d := b.devices[i-1]
+	return d.dev, d.base
+}
+
+func (b *Bus) Fetch(in Word, load Bit, addr [15]Bit) (out Word) {
+	a := addr2int(addr)
+	i := b.slots[a]
+	if i == 0 {
+		return Word{}
+	}
+	d := &b.devices[i-1]
+	return d.dev.Fetch(in, load, a-d.base)
+}
+
+// WordRAM is a RAM device on a plain word array.
+type WordRAM []Word
+
+func (r WordRAM) Fetch(in Word, load Bit, addr int) (out Word) {
+	out = r[addr]
+	if load == logic.I {
+		r[addr] = in
+	}
+	return out
+}
+
+// ScreenDevice puts an IScreen on a Bus.
+type ScreenDevice struct {
+	IScreen
+}
+
+func (s ScreenDevice) Fetch(in Word, load Bit, addr int) Word {
+	var addr8k [13]Bit
+	a := int2Addr(addr)
+	copy(addr8k[:], a[:13])
+	return s.IScreen.Fetch(in, load, addr8k)
+}
+
+// KeyboardDevice puts a read-only IKeyboard on a Bus.
+type KeyboardDevice struct {
+	IKeyboard
+}
+
+func (k KeyboardDevice) Fetch(in Word, load Bit, addr int) Word {
+	return k.IKeyboard.Fetch()
+}
+
+// attachClassic attaches the standard Hack memory map: ram below
+// SCREEN_ADDR, the screen and the keyboard, which is mirrored over the
+// rest of the address space like the original decoder did.
+func (b *Bus) attachClassic(ram IDevice, screen IScreen, keyboard IKeyboard) {
+	b.Attach(0, SCREEN_ADDR, ram)
+	b.Attach(SCREEN_ADDR, KBD_ADDR-SCREEN_ADDR, ScreenDevice{screen})
+	b.Attach(KBD_ADDR, BUS_SIZE-KBD_ADDR, KeyboardDevice{keyboard})
+}
+
+// Keyboard returns the keyboard of the classic map, or nil if KBD_ADDR is
+// answered by another device.
+func (b *Bus) Keyboard() IKeyboard {
+	dev, _ := b.Device(KBD_ADDR)
+	if k, ok := dev.(KeyboardDevice); ok {
+		return k.IKeyboard
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package computer
+
+import (
+	"testing"
+
+	logic "github.com/kazufusa/nand2tetris/01_Boolean_Logic"
+	"github.com/stretchr/testify/assert"
+)
+
+// accessLog wraps a device and records the absolute addresses written.
+type accessLog struct {
+	dev    IDevice
+	base   int
+	writes []int
+}
+
+func (l *accessLog) Fetch(in Word, load Bit, addr int) Word {
+	if load == logic.I {
+		l.writes = append(l.writes, l.base+addr)
+	}
+	return l.dev.Fetch(in, load, addr)
+}
+
+// stuckAt reads a fixed value and ignores writes.
+type stuckAt struct {
+	v int
+}
+
+func (s *stuckAt) Fetch(in Word, load Bit, addr int) Word {
+	return int2Word(s.v)
+}
+
+func TestBus(t *testing.T) {
+	kb := &TuiKeyboard{}
+	kb.SetWord(int2Word(65))
+	sc := &TuiScreen{}
+	ram := NewFastMemory(sc, kb)
+	fetch := func(addr int) int {
+		return word2Int(ram.Fetch(Word{}, logic.O, int2Addr(addr)))
+	}
+	store := func(addr, v int) {
+		ram.Fetch(int2Word(v), logic.I, int2Addr(addr))
+	}
+
+	store(100, 1)
+	store(SCREEN_ADDR+1, -1)
+	assert.Equal(t, 1, fetch(100))
+	assert.Equal(t, int2Word(-1), sc.words[1])
+	assert.Equal(t, 65, fetch(KBD_ADDR))
+	assert.Equal(t, 65, fetch(BUS_SIZE-1))
+	assert.Equal(t, kb, ram.Keyboard())
+
+	// A logger wraps the RAM without editing the decoder.
+	dev, base := ram.Device(100)
+	log := &accessLog{dev: dev, base: base}
+	ram.Attach(base, SCREEN_ADDR, log)
+	store(3, 7)
+	store(200, 8)
+	assert.Equal(t, 7, fetch(3))
+	assert.Equal(t, []int{3, 200}, log.writes)
+
+	// A fault injector overlays a single word.
+	stuck := &stuckAt{v: -1}
+	ram.Attach(200, 1, stuck)
+	assert.Equal(t, -1, fetch(200))
+	assert.Equal(t, 7, fetch(3))
+
+	ram.Detach(stuck)
+	assert.Equal(t, 8, fetch(200))
+	ram.Detach(log)
+	store(4, 1)
+	assert.Equal(t, []int{3, 200}, log.writes)
+	assert.Equal(t, 1, fetch(4))
+
+	var calls int
+	ram.Attach(KBD_ADDR+1, 1, DeviceFunc(func(in Word, load Bit, addr int) Word {
+		calls++
+		return in
+	}))
+	store(KBD_ADDR+1, 9)
+	assert.Equal(t, 1, calls)
+
+	var empty Bus
+	assert.Equal(t, Word{}, empty.Fetch(int2Word(5), logic.I, int2Addr(0)))
+	assert.Nil(t, empty.Keyboard())
+	assert.Panics(t, func() { empty.Attach(BUS_SIZE-1, 2, stuck) })
+}
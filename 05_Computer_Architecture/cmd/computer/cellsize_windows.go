@@ -0,0 +1,6 @@
+package main
+
+// cellPixels is not available on Windows consoles.
+func cellPixels() (w, h int) {
+	return 0, 0
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	computer "github.com/kazufusa/nand2tetris/05_Computer_Architecture"
+)
+
+// newCoverage loads the -source-map files, by default PROGRAM.map, and then
+// FILE.map of every source file they name, so that the map of the VM
+// translator is found from the map of the assembler and so on.
+func newCoverage(program string) (*computer.Coverage, error) {
+	cov := computer.NewCoverage()
+	var maps []string
+	if *sourceMaps != "" {
+		maps = strings.Split(*sourceMaps, ",")
+	} else {
+		maps = []string{program + ".map"}
+	}
+	for _, p := range maps {
+		if err := loadSourceMap(cov, p); err != nil {
+			return nil, err
+		}
+	}
+	tried := make(map[string]bool)
+	for {
+		loaded := false
+		for _, src := range cov.Sources() {
+			p := src + ".map"
+			if tried[p] {
+				continue
+			}
+			tried[p] = true
+			if _, err := os.Stat(p); err != nil {
+				continue
+			}
+			if err := loadSourceMap(cov, p); err != nil {
+				return nil, err
+			}
+			loaded = true
+		}
+		if !loaded {
+			return cov, nil
+		}
+	}
+}
+
+func loadSourceMap(cov *computer.Coverage, p string) error {
+	f, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return cov.LoadSourceMap(f, filepath.Dir(p))
+}
+
+func writeCoverage(cov *computer.Coverage) error {
+	if *coverageOut != "" {
+		if err := writeFile(*coverageOut, cov.WriteLCOV); err != nil {
+			return err
+		}
+	}
+	if *coverageHTML != "" {
+		return writeFile(*coverageHTML, cov.WriteHTML)
+	}
+	return nil
+}
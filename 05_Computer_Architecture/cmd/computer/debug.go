@@ -0,0 +1,193 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	computer "github.com/kazufusa/nand2tetris/05_Computer_Architecture"
+	"github.com/rivo/tview"
+)
+
+const debugHelp = `b LOC       set breakpoint (address or label)
+d LOC       delete breakpoint
+w FROM [N]  watch writes to N words of RAM (address, variable, SCREEN or KBD)
+W           clear watchpoints
+s           step
+n           step over
+c           continue
+p           pause
+S           step back
+r LOC       run back to the last write of RAM[LOC]
+t CYCLE     rewind to CYCLE
+x FROM [N]  examine N words of RAM
+`
+
+// debugPanel shows the registers and a command line next to the screen.
+type debugPanel struct {
+	*tview.Flex
+	app     *tview.Application
+	dbg     *computer.Debugger
+	info    *tview.TextView
+	output  *tview.TextView
+	running bool
+	examine [2]int
+}
+
+func newDebugPanel(app *tview.Application, dbg *computer.Debugger) *debugPanel {
+	p := &debugPanel{
+		Flex:    tview.NewFlex().SetDirection(tview.FlexRow),
+		app:     app,
+		dbg:     dbg,
+		info:    tview.NewTextView(),
+		output:  tview.NewTextView(),
+		examine: [2]int{0, 16},
+	}
+	input := tview.NewInputField().SetLabel("> ")
+	input.SetDoneFunc(func(_ tcell.Key) {
+		cmd := input.GetText()
+		input.SetText("")
+		p.exec(cmd)
+	})
+	p.info.SetBorder(true).SetTitle("cpu")
+	p.output.SetBorder(true).SetTitle("debugger")
+	fmt.Fprint(p.output, debugHelp)
+	p.AddItem(p.info, 0, 2, false).
+		AddItem(p.output, 0, 1, false).
+		AddItem(input, 1, 0, true)
+	p.refresh()
+	return p
+}
+
+func (p *debugPanel) exec(line string) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return
+	}
+	if p.running && fields[0] != "p" {
+		p.printf("running; pause first\n")
+		return
+	}
+
+	var err error
+	switch fields[0] {
+	case "b":
+		err = p.withArg(fields, p.dbg.Break)
+	case "d":
+		err = p.withArg(fields, p.dbg.ClearBreak)
+	case "w":
+		var from, n int
+		if from, n, err = p.rangeArgs(fields, 1); err == nil {
+			p.dbg.Watch(from, from+n-1)
+		}
+	case "W":
+		p.dbg.ClearWatches()
+	case "s":
+		p.stopped(p.dbg.Step())
+	case "n":
+		p.background(func() computer.StopReason { return p.dbg.StepOver(0) })
+	case "c":
+		p.background(func() computer.StopReason { return p.dbg.Continue(0) })
+	case "p":
+		p.dbg.Pause()
+	case "S":
+		if err = p.dbg.StepBack(); err == nil {
+			p.stopped(computer.STOP_STEP)
+		}
+	case "r":
+		var addr int
+		if addr, _, err = p.rangeArgs(fields, 1); err == nil {
+			if err = p.dbg.RunBackToWrite(addr); err == nil {
+				p.printf("RAM[%d] is written next\n", addr)
+			}
+		}
+	case "t":
+		var cycle uint64
+		if len(fields) != 2 {
+			err = fmt.Errorf("t needs one argument")
+		} else if cycle, err = strconv.ParseUint(fields[1], 10, 64); err == nil {
+			err = p.dbg.Rewind(cycle)
+		}
+	case "x":
+		var from, n int
+		if from, n, err = p.rangeArgs(fields, 16); err == nil {
+			p.examine = [2]int{from, n}
+		}
+	default:
+		err = fmt.Errorf("unknown command %q", fields[0])
+	}
+	if err != nil {
+		p.printf("error: %v\n", err)
+	}
+	p.refresh()
+}
+
+func (p *debugPanel) withArg(fields []string, f func(string) error) error {
+	if len(fields) != 2 {
+		return fmt.Errorf("%s needs one argument", fields[0])
+	}
+	return f(fields[1])
+}
+
+// rangeArgs parses "CMD FROM [N]"; FROM may be a variable, SCREEN or KBD.
+func (p *debugPanel) rangeArgs(fields []string, n int) (int, int, error) {
+	if len(fields) < 2 || len(fields) > 3 {
+		return 0, 0, fmt.Errorf("usage: %s FROM [N]", fields[0])
+	}
+	from, err := p.dbg.ResolveRAM(fields[1])
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(fields) == 3 {
+		if n, err = strconv.Atoi(fields[2]); err != nil {
+			return 0, 0, err
+		}
+	}
+	return from, n, nil
+}
+
+func (p *debugPanel) background(f func() computer.StopReason) {
+	p.running = true
+	p.printf("running...\n")
+	go func() {
+		reason := f()
+		p.app.QueueUpdateDraw(func() {
+			p.running = false
+			p.stopped(reason)
+			p.refresh()
+		})
+	}()
+}
+
+func (p *debugPanel) stopped(reason computer.StopReason) {
+	_, _, pc := p.dbg.Registers()
+	p.printf("stopped (%v) at %d\n", reason, pc)
+	if hit := p.dbg.LastHit(); hit != nil && reason == computer.STOP_WATCHPOINT {
+		p.printf("  RAM[%d] = %d\n", hit.Addr, hit.Value)
+	}
+}
+
+func (p *debugPanel) printf(format string, a ...interface{}) {
+	fmt.Fprintf(p.output, format, a...)
+	p.output.ScrollToEnd()
+}
+
+func (p *debugPanel) refresh() {
+	if p.running {
+		return
+	}
+	a, d, pc := p.dbg.Registers()
+	p.info.Clear()
+	fmt.Fprintf(p.info, "A  %6d\nD  %6d\nPC %6d\n", a, d, pc)
+	if cycle, oldest, ok := p.dbg.Cycle(); ok {
+		fmt.Fprintf(p.info, "cycle %d (back to %d)\n", cycle, oldest)
+	}
+	fmt.Fprintln(p.info)
+	fmt.Fprintf(p.info, "breakpoints %v\n", p.dbg.Breakpoints())
+	fmt.Fprintf(p.info, "watches     %v\n\n", p.dbg.Watches())
+	from, n := p.examine[0], p.examine[1]
+	for i, v := range p.dbg.Memory(from, from+n-1) {
+		fmt.Fprintf(p.info, "RAM[%5d] %6d\n", from+i, v)
+	}
+}
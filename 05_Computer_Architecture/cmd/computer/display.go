@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/gdamore/tcell/v2"
+	computer "github.com/kazufusa/nand2tetris/05_Computer_Architecture"
+	"github.com/rivo/tview"
+)
+
+const (
+	// DEFAULT_CELL_WIDTH and DEFAULT_CELL_HEIGHT size the image box when the
+	// terminal does not report its pixel size.
+	DEFAULT_CELL_WIDTH  = 8
+	DEFAULT_CELL_HEIGHT = 16
+)
+
+func parseDisplay(name string) (computer.GraphicsProtocol, error) {
+	switch name {
+	case "auto":
+		return computer.DetectGraphics(os.Getenv), nil
+	case "braille":
+		return computer.GRAPHICS_BRAILLE, nil
+	case "sixel":
+		return computer.GRAPHICS_SIXEL, nil
+	case "kitty":
+		return computer.GRAPHICS_KITTY, nil
+	}
+	return 0, fmt.Errorf("unknown -display %q", name)
+}
+
+// display is the primitive showing the screen, as braille text or as an
+// image written directly to the terminal. It repaints only when the screen
+// changed, from a ScreenBuffer, so drawing never holds the screen lock.
+type display struct {
+	*tview.Box
+	buf      *computer.ScreenBuffer
+	graphics *computer.GraphicsRenderer
+	cols     int
+	rows     int
+	x, y     int
+
+	// mu guards buf between refresh and Draw.
+	mu sync.Mutex
+}
+
+func newDisplay(sc *computer.TuiScreen, proto computer.GraphicsProtocol) *display {
+	d := &display{Box: tview.NewBox(), cols: computer.NCOL_C, rows: computer.NROW_C}
+	if proto == computer.GRAPHICS_BRAILLE {
+		d.buf = computer.NewScreenBuffer(sc)
+		return d
+	}
+	d.graphics = computer.NewGraphicsRenderer(sc, proto)
+	cw, ch := cellPixels()
+	if cw > 0 && ch > 0 {
+		d.graphics.SetCellHeight(ch)
+	} else {
+		cw, ch = DEFAULT_CELL_WIDTH, DEFAULT_CELL_HEIGHT
+	}
+	d.cols = (computer.NCOL + cw - 1) / cw
+	d.rows = (computer.NROW + ch - 1) / ch
+	return d
+}
+
+// width is the number of terminal columns the screen needs, borders
+// included.
+func (d *display) width() int {
+	return d.cols + 2
+}
+
+func (d *display) Draw(screen tcell.Screen) {
+	d.DrawForSubclass(screen, d)
+	if d.buf == nil {
+		return
+	}
+	x, y, w, h := d.GetInnerRect()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for i := 0; i < d.rows && i < h; i++ {
+		for j, r := range d.buf.Line(i) {
+			if j >= w {
+				break
+			}
+			screen.SetContent(x+j, y+i, r, nil, tcell.StyleDefault)
+		}
+	}
+}
+
+// refresh updates the screen if the program changed it. Images are written
+// on the goroutine of app, so they do not interleave with the cells tview
+// writes.
+func (d *display) refresh(app *tview.Application) {
+	if d.graphics == nil {
+		d.mu.Lock()
+		changed := len(d.buf.Update()) > 0
+		d.mu.Unlock()
+		if changed {
+			app.Draw()
+		}
+		return
+	}
+	app.QueueUpdate(func() {
+		x, y, _, _ := d.GetInnerRect()
+		if x != d.x || y != d.y {
+			d.x, d.y = x, y
+			d.graphics.Invalidate()
+		}
+		d.graphics.Render(os.Stdout, y, x)
+	})
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	computer "github.com/kazufusa/nand2tetris/05_Computer_Architecture"
+)
+
+// EXIT_TIMEOUT is returned when the program does not halt within -cycles.
+const EXIT_TIMEOUT = 2
+
+type headlessResult struct {
+	Cycles int        `json:"cycles"`
+	Halted bool       `json:"halted"`
+	A      int        `json:"a"`
+	D      int        `json:"d"`
+	PC     int        `json:"pc"`
+	RAM    []ramRange `json:"ram"`
+}
+
+type ramRange struct {
+	From   int   `json:"from"`
+	Values []int `json:"values"`
+}
+
+// runHeadless runs com without a terminal UI and writes the final state as
+// JSON. It returns the process exit code.
+func runHeadless(com *computer.Computer, cycles int, dump, out string) (int, error) {
+	ranges, err := parseRanges(dump)
+	if err != nil {
+		return 1, err
+	}
+
+	var res headlessResult
+	res.Cycles, res.Halted = com.Run(cycles)
+	res.A, res.D, res.PC = com.A(), com.D(), com.PC()
+	for _, r := range ranges {
+		rr := ramRange{From: r[0], Values: []int{}}
+		for addr := r[0]; addr <= r[1]; addr++ {
+			rr.Values = append(rr.Values, com.RAM(addr))
+		}
+		res.RAM = append(res.RAM, rr)
+	}
+
+	var w io.Writer = os.Stdout
+	if out != "" {
+		f, err := os.Create(out)
+		if err != nil {
+			return 1, err
+		}
+		defer f.Close()
+		w = f
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(res); err != nil {
+		return 1, err
+	}
+	if !res.Halted {
+		return EXIT_TIMEOUT, nil
+	}
+	return 0, nil
+}
+
+// parseRanges parses a comma separated list of addresses and FROM-TO ranges.
+// Addresses cover the whole bus, so device registers above KBD_ADDR can be
+// dumped too.
+func parseRanges(s string) ([][2]int, error) {
+	var ret [][2]int
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		bounds := strings.SplitN(part, "-", 2)
+		from, err := strconv.Atoi(bounds[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid RAM range %q", part)
+		}
+		to := from
+		if len(bounds) == 2 {
+			if to, err = strconv.Atoi(bounds[1]); err != nil {
+				return nil, fmt.Errorf("invalid RAM range %q", part)
+			}
+		}
+		if from < 0 || to < from {
+			return nil, fmt.Errorf("invalid RAM range %q", part)
+		}
+		if to >= computer.BUS_SIZE {
+			return nil, fmt.Errorf("RAM range %q is beyond the last address %d", part, computer.BUS_SIZE-1)
+		}
+		ret = append(ret, [2]int{from, to})
+	}
+	return ret, nil
+}
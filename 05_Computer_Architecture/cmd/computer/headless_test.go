@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	memory "github.com/kazufusa/nand2tetris/03_Memory"
+	computer "github.com/kazufusa/nand2tetris/05_Computer_Architecture"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sendHi writes "Hi" to the serial port and halts.
+var sendHi = []string{
+	"0000000001001000", // @72
+	"1110110000010000", // D=A
+	"0110000000100000", // @SERIAL_TX_DATA
+	"1110001100001000", // M=D
+	"0000000001101001", // @105
+	"1110110000010000", // D=A
+	"0110000000100000", // @SERIAL_TX_DATA
+	"1110001100001000", // M=D
+	"0000000000001000", // (END) @END
+	"1110101010000111", // 0;JMP
+}
+
+func TestHeadlessSerialStdio(t *testing.T) {
+	dir := t.TempDir()
+	*headless = true
+	defer func() { *headless, *dumpOut = false, "" }()
+
+	_, _, err := newSerial("stdio")
+	assert.EqualError(t, err, "-serial stdio writes to stdout, so the headless result needs -out")
+
+	*dumpOut = filepath.Join(dir, "result.json")
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	stdout := os.Stdout
+	os.Stdout = w
+	serial, _, err := newSerial("stdio")
+	os.Stdout = stdout
+	require.NoError(t, err)
+
+	hack := filepath.Join(dir, "Hi.hack")
+	require.NoError(t, ioutil.WriteFile(hack, []byte(strings.Join(sendHi, "\n")+"\n"), 0644))
+	rom := computer.VROM32K{}
+	require.NoError(t, rom.LoadHackFile(hack))
+	clock := memory.Clock(0)
+	ram := computer.NewFastMemory(&computer.TuiScreen{}, &computer.TuiKeyboard{})
+	serial.Map(&ram.Bus)
+	cpu := computer.NewFastCPU()
+	com := computer.NewComputer(&cpu, &ram, &rom, &clock)
+
+	code, err := runHeadless(&com, 100, "0", *dumpOut)
+	require.NoError(t, err)
+	assert.Equal(t, 0, code)
+	w.Close()
+	tx, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, "Hi", string(tx), "stdout holds only the serial output")
+
+	buf, err := ioutil.ReadFile(*dumpOut)
+	require.NoError(t, err)
+	var res headlessResult
+	require.NoError(t, json.Unmarshal(buf, &res))
+	assert.True(t, res.Halted)
+	assert.Equal(t, 8, res.PC)
+}
+
+func TestParseRanges(t *testing.T) {
+	ranges, err := parseRanges("0, 16-18, 24608-24611, 32767")
+	require.NoError(t, err)
+	assert.Equal(t, [][2]int{{0, 0}, {16, 18}, {24608, 24611}, {32767, 32767}}, ranges)
+
+	_, err = parseRanges("32760-32768")
+	assert.EqualError(t, err, `RAM range "32760-32768" is beyond the last address 32767`)
+	_, err = parseRanges("18-16")
+	assert.EqualError(t, err, `invalid RAM range "18-16"`)
+}
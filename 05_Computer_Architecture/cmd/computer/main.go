@@ -1,61 +1,413 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"sync"
 	"time"
 
 	"github.com/gdamore/tcell/v2"
 	logic "github.com/kazufusa/nand2tetris/01_Boolean_Logic"
 	memory "github.com/kazufusa/nand2tetris/03_Memory"
 	computer "github.com/kazufusa/nand2tetris/05_Computer_Architecture"
+	"github.com/kazufusa/nand2tetris/05_Computer_Architecture/gdbstub"
 	"github.com/rivo/tview"
 )
 
-type Word = computer.Word
+var (
+	fast         = flag.Bool("fast", false, "use the word-level CPU, memory and ROM instead of the gate-level ones")
+	debug        = flag.Bool("debug", false, "start paused with the debugger panel")
+	historySize  = flag.Int("history", 1000000, "instructions the debugger can step back through; 0 disables reverse debugging")
+	symbols      = flag.String("symbols", "", "symbol file of the assembler, whose labels the debugger and the profiler use")
+	loadSnapshot = flag.String("load-snapshot", "", "restore the machine from a snapshot before starting; needs -fast")
+	snapshotOut  = flag.String("snapshot", "computer.snap", "file written by Ctrl-S, which needs -fast")
+	headless     = flag.Bool("headless", false, "run without the terminal UI and print the final state as JSON; exits with 2 if the program does not halt")
+	cycles       = flag.Int("cycles", 10000000, "maximum number of instructions in headless mode")
+	dumpRAM      = flag.String("dump", "0-15", "comma separated addresses (0-32767, devices included) or FROM-TO ranges printed in headless mode")
+	dumpOut      = flag.String("out", "", "file for the headless result instead of stdout")
+	profileOut   = flag.String("profile", "", "write a pprof profile of the executed instructions on exit")
+	reportOut    = flag.String("profile-report", "", "write a text report of the hottest instructions on exit")
+	profileBy    = flag.String("profile-by", "function", "granularity of the text report: address, label or function")
+	traceOut     = flag.String("trace", "", "write every executed instruction to this file")
+	traceFormat  = flag.String("trace-format", "text", "trace format: text or binary")
+	traceRange   = flag.String("trace-range", "", "trace only ROM addresses FROM-TO; labels of -symbols are accepted")
+	traceCycles  = flag.Int("trace-cycles", 0, "trace only the first N cycles")
+	screenshot   = flag.String("screenshot", "", "PNG or PPM file written by Ctrl-P (default screen.png) and at the end of headless mode")
+	record       = flag.String("record", "", "record the screen as an animated GIF")
+	fps          = flag.Int("fps", 10, "frame rate of -record")
+	keysIn       = flag.String("keys", "", "replay a \"CYCLE CODE\" key timeline instead of the live keyboard")
+	keysOut      = flag.String("record-keys", "", "record the key codes seen by the program as a timeline for -keys")
+	mhz          = flag.Float64("mhz", 0, "target clock frequency in MHz; 0 runs as fast as possible")
+	refresh      = flag.Int("refresh", 20, "screen refresh rate of the terminal UI in frames per second")
+	gdbAddr      = flag.String("gdb", "", "wait for gdb remote connections on this address, e.g. localhost:1234")
+	timerDevice  = flag.Bool("timer", false, "map the timer above KBD and let it interrupt to ROM[2]")
+	diskImage    = flag.String("disk", "", "map a block storage device above KBD backed by this disk image")
+	diskSectors  = flag.Int("disk-sectors", 2048, "number of 512 byte sectors of a newly created -disk image")
+	displayMode  = flag.String("display", "auto", "screen renderer: braille, sixel, kitty or auto to detect the terminal")
+	coverageOut  = flag.String("coverage", "", "write the line coverage of the sources named by the source maps as LCOV on exit")
+	coverageHTML = flag.String("coverage-html", "", "write an HTML coverage report on exit")
+	sourceMaps   = flag.String("source-map", "", "comma separated source maps for -coverage (default PROGRAM.map); FILE.map of every mapped FILE is loaded too")
+	serialMode   = flag.String("serial", "", "map a serial port above KBD connected to stdio (with -headless and -out) or to a pane of the terminal UI")
+)
 
 func main() {
+	flag.Parse()
+	// Snapshots read and write every word of RAM and ROM, which takes
+	// minutes through the gate-level ones.
+	if !*fast {
+		flag.Visit(func(f *flag.Flag) {
+			if f.Name == "load-snapshot" || f.Name == "snapshot" {
+				log.Fatalf("-%s needs -fast", f.Name)
+			}
+		})
+	}
+
 	sc := computer.TuiScreen{}
 	clock := memory.Clock(0)
 
 	kb := computer.TuiKeyboard{}
-	ram := computer.NewMemory(&clock, &sc, &kb)
-	ram.Fetch(Word{0, 0, 0, 1}, logic.I, [15]logic.Bit{})
-	clock.Progress()
+	var keyboard computer.IKeyboard = &kb
+	var replay *computer.ReplayKeyboard
+	if *keysIn != "" {
+		f, err := os.Open(*keysIn)
+		if err != nil {
+			log.Fatal(err)
+		}
+		events, err := computer.ReadKeyTimeline(f)
+		f.Close()
+		if err != nil {
+			log.Fatal(err)
+		}
+		replay = computer.NewReplayKeyboard(events)
+		keyboard = replay
+	}
+	var keyRec *computer.RecordingKeyboard
+	if *keysOut != "" {
+		keyRec = computer.NewRecordingKeyboard(keyboard)
+		keyboard = keyRec
+	}
 
-	rom := computer.NewROM32K()
-	if err := rom.LoadHackFile(os.Args[1]); err != nil {
-		log.Fatal(err)
+	var ram computer.IMemory
+	var bus *computer.Bus
+	if *fast {
+		mem := computer.NewFastMemory(&sc, keyboard)
+		ram, bus = &mem, &mem.Bus
+	} else {
+		mem := computer.NewMemory(&clock, &sc, keyboard)
+		ram, bus = &mem, &mem.Bus
+	}
+	var timer *computer.Timer
+	if *timerDevice {
+		timer = computer.NewTimer()
+		timer.Map(bus)
+	}
+	var storage *computer.Storage
+	if *diskImage != "" {
+		var err error
+		storage, err = computer.OpenStorage(*diskImage, *diskSectors)
+		if err != nil {
+			log.Fatal(err)
+		}
+		storage.Map(bus)
+	}
+	var serial *computer.Serial
+	var serialOut *tview.TextView
+	if *serialMode != "" {
+		var err error
+		serial, serialOut, err = newSerial(*serialMode)
+		if err != nil {
+			log.Fatal(err)
+		}
+		serial.Map(bus)
+	}
+
+	var com computer.Computer
+	if *fast {
+		rom := computer.VROM32K{}
+		if err := rom.LoadHackFile(flag.Arg(0)); err != nil {
+			log.Fatal(err)
+		}
+		cpu := computer.NewFastCPU()
+		com = computer.NewComputer(&cpu, ram, &rom, &clock)
+	} else {
+		rom := computer.NewROM32K()
+		if err := rom.LoadHackFile(flag.Arg(0)); err != nil {
+			log.Fatal(err)
+		}
+		cpu := computer.NewCPU()
+		com = computer.NewComputer(&cpu, ram, &rom, &clock)
+	}
+	com.SetRAM(0, 8)
+	if timer != nil {
+		timer.Attach(&com)
+	}
+	if replay != nil {
+		com.AddObserver(replay)
+	}
+	if keyRec != nil {
+		com.AddObserver(keyRec)
+	}
+
+	if *loadSnapshot != "" {
+		if err := restore(&com, *loadSnapshot); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// finish writes the files which are complete only when the run ends.
+	var finish []func() error
+	if storage != nil {
+		finish = append(finish, storage.Close)
+	}
+	if *keysOut != "" {
+		finish = append(finish, func() error { return writeKeys(keyRec) })
+	}
+	if *profileOut != "" || *reportOut != "" {
+		prof := computer.NewProfiler()
+		if *symbols != "" {
+			if err := loadSymbols(prof.LoadSymbols, *symbols); err != nil {
+				log.Fatal(err)
+			}
+		}
+		com.AddObserver(prof)
+		finish = append(finish, func() error { return writeProfile(prof) })
+	}
+	if *coverageOut != "" || *coverageHTML != "" {
+		cov, err := newCoverage(flag.Arg(0))
+		if err != nil {
+			log.Fatal(err)
+		}
+		com.AddObserver(cov)
+		finish = append(finish, func() error { return writeCoverage(cov) })
+	}
+	if *traceOut != "" {
+		trace, err := newTracer()
+		if err != nil {
+			log.Fatal(err)
+		}
+		com.AddObserver(trace)
+		finish = append(finish, trace.Close)
+	}
+	if *record != "" {
+		rec := startRecorder(&sc, *record, *fps)
+		finish = append(finish, rec.Close)
+	}
+	finishAll := func() {
+		for _, f := range finish {
+			if err := f(); err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
+
+	if *headless {
+		code, err := runHeadless(&com, *cycles, *dumpRAM, *dumpOut)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if *screenshot != "" {
+			if err := writeScreenshot(&sc, *screenshot); err != nil {
+				log.Fatal(err)
+			}
+		}
+		finishAll()
+		os.Exit(code)
 	}
 
-	cpu := computer.NewCPU()
+	proto, err := parseDisplay(*displayMode)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	com := computer.NewComputer(&cpu, &ram, &rom, &clock)
+	// mu serializes the CPU loop and the debugger with snapshots.
+	var mu sync.Mutex
+	throttle := computer.NewThrottle(*mhz * 1e6)
 
 	app := tview.NewApplication()
-	textView := tview.NewTextView().
-		SetChangedFunc(func() { app.Draw() })
-	textView.SetInputCapture(func(key *tcell.EventKey) *tcell.EventKey {
+	disp := newDisplay(&sc, proto)
+	disp.SetInputCapture(func(key *tcell.EventKey) *tcell.EventKey {
+		if key.Key() == tcell.KeyCtrlS {
+			if !*fast {
+				return nil
+			}
+			mu.Lock()
+			err := dump(&com, *snapshotOut)
+			mu.Unlock()
+			if err != nil {
+				app.Stop()
+				log.Fatal(err)
+			}
+			return nil
+		}
+		if key.Key() == tcell.KeyCtrlP {
+			p := *screenshot
+			if p == "" {
+				p = "screen.png"
+			}
+			if err := writeScreenshot(&sc, p); err != nil {
+				app.Stop()
+				log.Fatal(err)
+			}
+			return nil
+		}
+		if handleSpeedKey(throttle, key) {
+			return nil
+		}
 		kb.Set(key)
 		return key
 	})
 
-	go func() {
-		for {
-			com.FetchAndExecute(logic.O)
+	var root tview.Primitive = disp
+	if *debug {
+		dbg := computer.NewDebugger(&com)
+		dbg.SetLock(&mu)
+		if *historySize > 0 {
+			h := computer.NewHistory(*historySize)
+			h.Attach(&com)
+			dbg.SetHistory(h)
 		}
-	}()
+		if *symbols != "" {
+			if err := loadSymbols(dbg.LoadSymbols, *symbols); err != nil {
+				log.Fatal(err)
+			}
+		}
+		root = tview.NewFlex().
+			AddItem(disp, disp.width(), 0, false).
+			AddItem(newDebugPanel(app, dbg), 0, 1, true)
+	} else if *gdbAddr != "" {
+		srv := gdbstub.NewServer(&com)
+		srv.Lock = &mu
+		go func() {
+			if err := srv.ListenAndServe(*gdbAddr); err != nil {
+				app.Stop()
+				log.Fatal(err)
+			}
+		}()
+	} else {
+		status := tview.NewTextView()
+		root = tview.NewFlex().SetDirection(tview.FlexRow).
+			AddItem(disp, 0, 1, true).
+			AddItem(status, 1, 0, false)
+		go func() {
+			for {
+				mu.Lock()
+				n := throttle.Run(func() { com.FetchAndExecute(logic.O) })
+				mu.Unlock()
+				throttle.Wait(n)
+			}
+		}()
+		go func() {
+			for {
+				app.QueueUpdateDraw(func() {
+					status.SetText(speedStatus(throttle))
+				})
+				time.Sleep(computer.THROTTLE_WINDOW)
+			}
+		}()
+	}
+
+	if serialOut != nil {
+		root = tview.NewFlex().SetDirection(tview.FlexRow).
+			AddItem(root, 0, 1, true).
+			AddItem(newSerialPane(app, serial, serialOut), 10, 0, false)
+	}
 
+	frame := time.Second / 20
+	if *refresh > 0 {
+		frame = time.Second / time.Duration(*refresh)
+	}
 	go func() {
 		for {
-			textView.Clear()
-			fmt.Fprintf(textView, sc.Str())
-			time.Sleep(50 * time.Millisecond)
+			disp.refresh(app)
+			time.Sleep(frame)
 		}
 	}()
 
-	if err := app.SetRoot(textView, true).EnableMouse(true).Run(); err != nil {
+	if err := app.SetRoot(root, true).EnableMouse(true).Run(); err != nil {
 		log.Fatal(err)
 	}
+	mu.Lock()
+	defer mu.Unlock()
+	finishAll()
+}
+
+func writeKeys(r *computer.RecordingKeyboard) error {
+	return writeFile(*keysOut, func(w io.Writer) error {
+		return computer.WriteKeyTimeline(w, r.Events())
+	})
+}
+
+func loadSymbols(load func(io.Reader) error, p string) error {
+	f, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return load(f)
+}
+
+func writeProfile(prof *computer.Profiler) error {
+	if *profileOut != "" {
+		if err := writeFile(*profileOut, prof.WritePprof); err != nil {
+			return err
+		}
+	}
+	if *reportOut != "" {
+		var by computer.ProfileBy
+		switch *profileBy {
+		case "address":
+			by = computer.PROFILE_ADDRESS
+		case "label":
+			by = computer.PROFILE_LABEL
+		case "function":
+			by = computer.PROFILE_FUNCTION
+		default:
+			return fmt.Errorf("unknown -profile-by %q", *profileBy)
+		}
+		return writeFile(*reportOut, func(w io.Writer) error {
+			return prof.WriteReport(w, by)
+		})
+	}
+	return nil
+}
+
+func writeFile(p string, write func(io.Writer) error) error {
+	f, err := os.Create(p)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func restore(com *computer.Computer, p string) error {
+	f, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	snap, err := computer.ReadSnapshot(f)
+	if err != nil {
+		return err
+	}
+	com.Restore(snap)
+	return nil
+}
+
+func dump(com *computer.Computer, p string) error {
+	f, err := os.Create(p)
+	if err != nil {
+		return err
+	}
+	if _, err := com.Snapshot().WriteTo(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
 }
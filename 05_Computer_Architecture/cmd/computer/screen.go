@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	computer "github.com/kazufusa/nand2tetris/05_Computer_Architecture"
+)
+
+// writeScreenshot writes the screen as PNG or, for a .ppm file, as PPM.
+func writeScreenshot(sc *computer.TuiScreen, p string) error {
+	switch strings.ToLower(filepath.Ext(p)) {
+	case ".png":
+		return writeFile(p, sc.WritePNG)
+	case ".ppm":
+		return writeFile(p, sc.WritePPM)
+	}
+	return fmt.Errorf("screenshot %s: use a .png or .ppm file", p)
+}
+
+// recorder captures the screen into a GIF from its own goroutine.
+type recorder struct {
+	rec  *computer.GIFRecorder
+	p    string
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+func startRecorder(sc *computer.TuiScreen, p string, fps int) *recorder {
+	r := &recorder{rec: computer.NewGIFRecorder(sc, fps), p: p, stop: make(chan struct{})}
+	if fps < 1 {
+		fps = 1
+	}
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		t := time.NewTicker(time.Second / time.Duration(fps))
+		defer t.Stop()
+		for {
+			r.rec.Capture()
+			select {
+			case <-t.C:
+			case <-r.stop:
+				return
+			}
+		}
+	}()
+	return r
+}
+
+// Close stops the capture and writes the GIF.
+func (r *recorder) Close() error {
+	close(r.stop)
+	r.wg.Wait()
+	return writeFile(r.p, func(w io.Writer) error {
+		return r.rec.Encode(w)
+	})
+}
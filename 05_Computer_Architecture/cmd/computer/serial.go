@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/gdamore/tcell/v2"
+	computer "github.com/kazufusa/nand2tetris/05_Computer_Architecture"
+	"github.com/rivo/tview"
+)
+
+// newSerial connects the serial port to stdout and stdin ("stdio") or to a
+// log pane of the terminal UI ("pane"), which is returned to be laid out.
+// As the headless result is JSON on stdout too, "stdio" requires -out.
+func newSerial(mode string) (*computer.Serial, *tview.TextView, error) {
+	switch mode {
+	case "stdio":
+		if !*headless {
+			return nil, nil, fmt.Errorf("-serial stdio needs -headless; use -serial pane")
+		}
+		if *dumpOut == "" {
+			return nil, nil, fmt.Errorf("-serial stdio writes to stdout, so the headless result needs -out")
+		}
+		serial := computer.NewSerial(os.Stdout)
+		go func() {
+			if _, err := serial.ReadFrom(os.Stdin); err != nil {
+				log.Print(err)
+			}
+		}()
+		return serial, nil, nil
+	case "pane":
+		if *headless {
+			return nil, nil, fmt.Errorf("-serial pane needs the terminal UI; use -serial stdio")
+		}
+		out := tview.NewTextView()
+		return computer.NewSerial(out), out, nil
+	}
+	return nil, nil, fmt.Errorf("unknown -serial %q", mode)
+}
+
+// newSerialPane shows the serial output above a line editor whose lines are
+// sent to the program when Enter is pressed.
+func newSerialPane(app *tview.Application, serial *computer.Serial, out *tview.TextView) tview.Primitive {
+	out.SetChangedFunc(func() { app.Draw() }).
+		SetBorder(true).
+		SetTitle("serial")
+	input := tview.NewInputField().SetLabel("> ")
+	input.SetDoneFunc(func(key tcell.Key) {
+		if key == tcell.KeyEnter {
+			serial.Receive([]byte(input.GetText() + "\n"))
+			input.SetText("")
+		}
+	})
+	return tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(out, 0, 1, false).
+		AddItem(input, 1, 0, false)
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/gdamore/tcell/v2"
+	computer "github.com/kazufusa/nand2tetris/05_Computer_Architecture"
+)
+
+// handleSpeedKey applies Ctrl-T (pause/resume), Ctrl-F (twice as fast) and
+// Ctrl-B (half as fast). It reports whether key was one of them.
+func handleSpeedKey(th *computer.Throttle, key *tcell.EventKey) bool {
+	switch key.Key() {
+	case tcell.KeyCtrlT:
+		if th.Paused() {
+			th.Resume()
+		} else {
+			th.Pause()
+		}
+	case tcell.KeyCtrlF:
+		th.SetMultiplier(th.Multiplier() * 2)
+	case tcell.KeyCtrlB:
+		th.SetMultiplier(th.Multiplier() / 2)
+	default:
+		return false
+	}
+	return true
+}
+
+func speedStatus(th *computer.Throttle) string {
+	if th.Paused() {
+		return " paused | Ctrl-T resume"
+	}
+	if th.Target() <= 0 {
+		return fmt.Sprintf(" %s | unlimited | Ctrl-T pause", formatHz(th.Rate()))
+	}
+	return fmt.Sprintf(" %s | target %s (x%g) | Ctrl-T pause, Ctrl-F faster, Ctrl-B slower",
+		formatHz(th.Rate()), formatHz(th.Target()), th.Multiplier())
+}
+
+func formatHz(hz float64) string {
+	switch {
+	case hz >= 1e6:
+		return fmt.Sprintf("%.2f MHz", hz/1e6)
+	case hz >= 1e3:
+		return fmt.Sprintf("%.2f kHz", hz/1e3)
+	}
+	return fmt.Sprintf("%.0f Hz", hz)
+}
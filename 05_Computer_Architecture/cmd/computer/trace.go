@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	computer "github.com/kazufusa/nand2tetris/05_Computer_Architecture"
+	assembler "github.com/kazufusa/nand2tetris/06_Assembler"
+)
+
+// tracer writes the -trace file.
+type tracer struct {
+	*computer.Tracer
+	f *os.File
+}
+
+func newTracer() (*tracer, error) {
+	var format computer.TraceFormat
+	switch *traceFormat {
+	case "text":
+		format = computer.TRACE_TEXT
+	case "binary":
+		format = computer.TRACE_BINARY
+	default:
+		return nil, fmt.Errorf("unknown -trace-format %q", *traceFormat)
+	}
+	var from, to int
+	if *traceRange != "" {
+		var err error
+		if from, to, err = parseTraceRange(*traceRange); err != nil {
+			return nil, err
+		}
+	}
+
+	f, err := os.Create(*traceOut)
+	if err != nil {
+		return nil, err
+	}
+	t := &tracer{Tracer: computer.NewTracer(f, format), f: f}
+	if *traceRange != "" {
+		t.SetRange(from, to)
+	}
+	t.SetLimit(*traceCycles)
+	return t, nil
+}
+
+// parseTraceRange parses FROM-TO, where both ends are ROM addresses or labels
+// of the -symbols file. A label as TO covers the code up to the next label.
+func parseTraceRange(s string) (from, to int, err error) {
+	parts := strings.SplitN(s, "-", 2)
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("invalid -trace-range %q", s)
+	}
+	var syms []assembler.Symbol
+	if *symbols != "" {
+		f, err := os.Open(*symbols)
+		if err != nil {
+			return 0, 0, err
+		}
+		syms, err = assembler.ReadSymbols(f)
+		f.Close()
+		if err != nil {
+			return 0, 0, err
+		}
+		syms = assembler.Labels(syms)
+	}
+	resolve := func(loc string, end bool) (int, error) {
+		if addr, err := strconv.Atoi(loc); err == nil {
+			return addr, nil
+		}
+		for _, sym := range syms {
+			if sym.Name != loc {
+				continue
+			}
+			if !end {
+				return sym.Addr, nil
+			}
+			next := computer.SNAPSHOT_ROM_SIZE
+			for _, x := range syms {
+				if x.Addr > sym.Addr && x.Addr < next {
+					next = x.Addr
+				}
+			}
+			return next - 1, nil
+		}
+		return 0, fmt.Errorf("unknown location %q", loc)
+	}
+	if from, err = resolve(parts[0], false); err != nil {
+		return 0, 0, err
+	}
+	if to, err = resolve(parts[1], true); err != nil {
+		return 0, 0, err
+	}
+	return from, to, nil
+}
+
+func (t *tracer) Close() error {
+	if err := t.Flush(); err != nil {
+		t.f.Close()
+		return err
+	}
+	return t.f.Close()
+}
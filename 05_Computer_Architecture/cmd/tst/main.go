@@ -0,0 +1,36 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/kazufusa/nand2tetris/05_Computer_Architecture/tst"
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s FILE.tst...\n\nchips: %s\n",
+			os.Args[0], strings.Join(tst.Chips(), " "))
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	failed := false
+	for _, p := range flag.Args() {
+		if err := tst.RunFile(p); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			failed = true
+			continue
+		}
+		fmt.Printf("%s: End of script - Comparison ended successfully\n", p)
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
@@ -11,10 +11,13 @@ type IMemory interface {
 
 type IROM32K interface {
 	Fetch(addr [15]Bit) (out Word)
+	// Peek reads a word without the side effects of an instruction fetch.
+	Peek(addr [15]Bit) (out Word)
+	Store(addr [15]Bit, w Word)
 }
 
 type Computer struct {
-	cpu   *CPU
+	cpu   ICPU
 	ram   IMemory
 	rom   IROM32K
 	clock *memory.Clock
@@ -22,19 +25,186 @@ type Computer struct {
 	pc       [15]logic.Bit
 	inM      Word
 	addressM [15]logic.Bit
+	// inst is the last executed instruction.
+	inst Word
+
+	observers []IObserver
+	history   *History
+}
+
+// Event describes one executed instruction. InM is the memory input the CPU
+// saw at InAddressM, AddressM the new A register, A and D the registers after
+// the instruction and NextPC the address of the next instruction.
+type Event struct {
+	PC         int
+	Inst       int
+	InM        int
+	InAddressM int
+	OutM       int
+	WriteM     bool
+	AddressM   int
+	A, D       int
+	NextPC     int
+	Reset      bool
+}
+
+// IObserver is notified after every FetchAndExecute.
+type IObserver interface {
+	Observe(e *Event)
 }
 
-func NewComputer(cpu *CPU, ram IMemory, rom IROM32K, clock *memory.Clock) Computer {
+func NewComputer(cpu ICPU, ram IMemory, rom IROM32K, clock *memory.Clock) Computer {
 	return Computer{cpu: cpu, ram: ram, rom: rom, clock: clock}
 }
 
 func (com *Computer) FetchAndExecute(reset logic.Bit) {
 	var outM Word
 	var writeM logic.Bit
+	pc, inM, inAddressM := com.pc, com.inM, com.addressM
+	var d delta
+	if com.history != nil {
+		d = delta{pc: uint16(addr2int(pc)), a: uint16(com.A()), d: uint16(com.D()), addr: NO_WRITE}
+	}
 	inst := com.rom.Fetch(com.pc)
+	com.inst = inst
 	outM, writeM, com.addressM, com.pc = com.cpu.Fetch(com.inM, inst, reset)
 
+	if com.history != nil {
+		if addr := addr2int(com.addressM); writeM == logic.I && addr < KBD_ADDR {
+			d.addr = int16(addr)
+			d.old = uint16(word2Int(com.ram.Fetch(Word{}, logic.O, com.addressM)))
+		}
+		com.history.push(d)
+	}
 	com.ram.Fetch(outM, writeM, com.addressM)
 	com.clock.Progress()
 	com.inM = com.ram.Fetch(com.inM, logic.O, com.addressM)
+
+	if len(com.observers) > 0 {
+		e := Event{
+			PC:         addr2int(pc),
+			Inst:       word2Int(inst),
+			InM:        word2Int(inM),
+			InAddressM: addr2int(inAddressM),
+			OutM:       word2Int(outM),
+			WriteM:     writeM == logic.I,
+			AddressM:   addr2int(com.addressM),
+			A:          com.A(),
+			D:          com.D(),
+			NextPC:     addr2int(com.pc),
+			Reset:      reset == logic.I,
+		}
+		for _, o := range com.observers {
+			o.Observe(&e)
+		}
+	}
+}
+
+// AddObserver registers o to be notified of every executed instruction.
+func (com *Computer) AddObserver(o IObserver) {
+	com.observers = append(com.observers, o)
+}
+
+func (com *Computer) RemoveObserver(o IObserver) {
+	for i, x := range com.observers {
+		if x == o {
+			com.observers = append(com.observers[:i:i], com.observers[i+1:]...)
+			return
+		}
+	}
+}
+
+// A returns the A register as a signed 16-bit value.
+func (com *Computer) A() int {
+	return word2Int(com.cpu.A())
+}
+
+func (com *Computer) SetA(v int) {
+	com.cpu.SetA(int2Word(v))
+	com.addressM = int2Addr(v)
+	com.inM = com.ram.Fetch(Word{}, logic.O, com.addressM)
+}
+
+// D returns the D register as a signed 16-bit value.
+func (com *Computer) D() int {
+	return word2Int(com.cpu.D())
+}
+
+func (com *Computer) SetD(v int) {
+	com.cpu.SetD(int2Word(v))
+}
+
+// PC returns the address of the next instruction.
+func (com *Computer) PC() int {
+	return addr2int(com.pc)
+}
+
+func (com *Computer) SetPC(v int) {
+	com.pc = int2Addr(v)
+	com.cpu.SetPC(com.pc)
+}
+
+// RAM returns the word at addr of the data memory, including the screen and
+// the keyboard, as a signed 16-bit value.
+func (com *Computer) RAM(addr int) int {
+	return word2Int(com.ram.Fetch(Word{}, logic.O, int2Addr(addr)))
+}
+
+func (com *Computer) SetRAM(addr, v int) {
+	com.ram.Fetch(int2Word(v), logic.I, int2Addr(addr))
+	com.clock.Progress()
+	com.inM = com.ram.Fetch(Word{}, logic.O, com.addressM)
+}
+
+// ROM returns the instruction at addr as a signed 16-bit value.
+func (com *Computer) ROM(addr int) int {
+	return word2Int(com.rom.Peek(int2Addr(addr)))
+}
+
+func (com *Computer) SetROM(addr, v int) {
+	com.rom.Store(int2Addr(addr), int2Word(v))
+}
+
+// IsHalted reports whether the CPU is spinning in the conventional
+// "(END) @END 0;JMP" loop which ends a Hack program.
+func (com *Computer) IsHalted() bool {
+	pc := com.PC()
+	return com.isHaltLoop(pc) || pc > 0 && com.A() == pc-1 && com.isHaltLoop(pc-1)
+}
+
+// isHaltLoop reports whether a halt loop starts at addr.
+func (com *Computer) isHaltLoop(addr int) bool {
+	return com.ROM(addr) == addr && isUnconditionalJump(com.ROM(addr+1))
+}
+
+// isUnconditionalJump reports whether inst is a "0;JMP"-like C-instruction
+// which does not change A.
+func isUnconditionalJump(inst int) bool {
+	return inst < 0 && inst&0x7 == 0x7 && inst&0x20 == 0
+}
+
+// Run executes up to limit instructions and stops early when the program
+// halts. It returns the number of executed instructions.
+// Rather than peeking ROM on every instruction as IsHalted does, it
+// remembers which addresses start a halt loop, and it knows A to be pc-1
+// only right after an A-instruction loading its own address.
+func (com *Computer) Run(limit int) (cycles int, halted bool) {
+	loops := map[int]bool{}
+	isHaltLoop := func(addr int) bool {
+		l, ok := loops[addr]
+		if !ok {
+			l = com.isHaltLoop(addr)
+			loops[addr] = l
+		}
+		return l
+	}
+	halted = com.IsHalted()
+	for cycles < limit && !halted {
+		com.FetchAndExecute(logic.O)
+		cycles++
+		pc := com.PC()
+		halted = isHaltLoop(pc) ||
+			com.inst[15] == logic.O && word2Int(com.inst) == pc-1 && isHaltLoop(pc-1)
+	}
+	return cycles, halted
 }
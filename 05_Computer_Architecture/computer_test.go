@@ -144,3 +144,117 @@ func TestEmulator(t *testing.T) {
 		})
 	}
 }
+
+func TestComputerInspection(t *testing.T) {
+	gateLevel := func() *Computer {
+		clock := memory.Clock(0)
+		sc := NewTestScreen(&clock)
+		kb := TestKeyboard{}
+		ram := NewMemory(&clock, &sc, &kb)
+		rom := NewROM32K()
+		rom.BulkLoad(maxInstructions)
+		cpu := NewCPU()
+		com := NewComputer(&cpu, &ram, &rom, &clock)
+		return &com
+	}
+	fast := func() *Computer {
+		com := NewEmulator(maxInstructions)
+		cpu := NewFastCPU()
+		com.cpu = &cpu
+		return com
+	}
+	var tests = []struct {
+		name string
+		com  func() *Computer
+	}{
+		{"gate level", gateLevel},
+		{"emulator", func() *Computer { return NewEmulator(maxInstructions) }},
+		{"fast cpu", fast},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			com := tt.com()
+			assert.Equal(t, -1008, com.ROM(1))
+			com.SetROM(16, 12345)
+			assert.Equal(t, 12345, com.ROM(16))
+
+			com.SetRAM(0, 6)
+			com.SetRAM(1, 10)
+			com.SetRAM(SCREEN_ADDR+3, -2)
+			assert.Equal(t, 6, com.RAM(0))
+			assert.Equal(t, 10, com.RAM(1))
+			assert.Equal(t, -2, com.RAM(SCREEN_ADDR+3))
+
+			com.SetA(300)
+			com.SetD(-7)
+			assert.Equal(t, 300, com.A())
+			assert.Equal(t, -7, com.D())
+
+			// Skip the comparison and run from "@1; D=M" with RAM[1] = 10.
+			com.SetPC(6)
+			assert.Equal(t, 6, com.PC())
+			for i := 0; i < 8; i++ {
+				com.FetchAndExecute(logic.O)
+			}
+			assert.Equal(t, 10, com.RAM(2))
+			assert.Equal(t, 10, com.D())
+			assert.Equal(t, 14, com.PC())
+
+			// A write through M must be visible to the next instruction.
+			com.SetPC(11)
+			com.SetA(0)
+			com.SetRAM(0, 42)
+			com.FetchAndExecute(logic.O)
+			assert.Equal(t, 42, com.D())
+		})
+	}
+}
+
+func TestComputerRun(t *testing.T) {
+	com, _, _ := newFastComputer(maxInstructions)
+	com.SetRAM(0, 6)
+	com.SetRAM(1, 10)
+	assert.False(t, com.IsHalted())
+
+	cycles, halted := com.Run(1000)
+	assert.True(t, halted)
+	assert.Equal(t, 12, cycles)
+	assert.Equal(t, 14, com.PC())
+	assert.Equal(t, 10, com.RAM(2))
+
+	// Inside the loop, right after "@14".
+	com.FetchAndExecute(logic.O)
+	assert.True(t, com.IsHalted())
+
+	com.SetPC(0)
+	cycles, halted = com.Run(5)
+	assert.False(t, halted)
+	assert.Equal(t, 5, cycles)
+}
+
+// peekCounter counts the Peek calls on a ROM.
+type peekCounter struct {
+	*VROM32K
+	peeks int
+}
+
+func (r *peekCounter) Peek(addr [15]Bit) Word {
+	r.peeks++
+	return r.VROM32K.Peek(addr)
+}
+
+func TestComputerRunPeeksEachAddressOnce(t *testing.T) {
+	clock := memory.Clock(0)
+	ram := NewFastMemory(&TuiScreen{}, &TuiKeyboard{})
+	rom := &peekCounter{VROM32K: &VROM32K{}}
+	// "@1 (LOOP) 0;JMP" spins without being a halt loop.
+	rom.BulkLoad([]Word{int2Word(1), int2Word(-5497)})
+	cpu := NewFastCPU()
+	com := NewComputer(&cpu, &ram, rom, &clock)
+
+	cycles, halted := com.Run(1000)
+	assert.False(t, halted)
+	assert.Equal(t, 1000, cycles)
+	assert.LessOrEqual(t, rom.peeks, 10)
+}
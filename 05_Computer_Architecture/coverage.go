@@ -0,0 +1,242 @@
+package computer
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// COVERAGE_MAX_DEPTH bounds the chain of source maps followed from a ROM
+// address, e.g. hack -> asm -> vm -> jack.
+const COVERAGE_MAX_DEPTH = 8
+
+// SourceLine is a "FILE:LINE" position of a source map.
+type SourceLine struct {
+	File string
+	Line int
+}
+
+func (l SourceLine) String() string {
+	return fmt.Sprintf("%s:%d", l.File, l.Line)
+}
+
+func parseSourceLine(s, dir string) (SourceLine, error) {
+	i := strings.LastIndex(s, ":")
+	if i <= 0 {
+		return SourceLine{}, fmt.Errorf("expected FILE:LINE, got %q", s)
+	}
+	n, err := strconv.Atoi(s[i+1:])
+	if err != nil || n < 1 {
+		return SourceLine{}, fmt.Errorf("bad line number in %q", s)
+	}
+	return SourceLine{File: filepath.Join(dir, filepath.FromSlash(s[:i])), Line: n}, nil
+}
+
+// FileCoverage is the line coverage of one source file. Lines holds the
+// execution count of every line which generated code.
+type FileCoverage struct {
+	File  string
+	Lines map[int]uint64
+}
+
+// Hit returns the number of lines executed at least once.
+func (f FileCoverage) Hit() int {
+	n := 0
+	for _, c := range f.Lines {
+		if c > 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// Coverage records executed ROM addresses and reports them as line coverage
+// of the sources named by source maps. A source map has one
+// "OUT:LINE SRC:LINE" line per generated line, as written by the assembler
+// and the VM translator; the lines of a .hack file are ROM addresses counted
+// from 1. Maps chain, so with the maps of the assembler and of the VM
+// translator the .asm and the .vm files are both covered. Register it with
+// Computer.AddObserver.
+type Coverage struct {
+	counts [SNAPSHOT_ROM_SIZE]uint64
+	rom    map[int]SourceLine
+	links  map[SourceLine]SourceLine
+	// outputs holds the files generated according to the loaded maps.
+	outputs map[string]bool
+}
+
+func NewCoverage() *Coverage {
+	return &Coverage{
+		rom:     make(map[int]SourceLine),
+		links:   make(map[SourceLine]SourceLine),
+		outputs: make(map[string]bool),
+	}
+}
+
+func (c *Coverage) Observe(e *Event) {
+	c.counts[e.PC]++
+}
+
+// LoadSourceMap reads a source map whose paths are relative to dir,
+// normally the directory of the map.
+func (c *Coverage) LoadSourceMap(r io.Reader, dir string) error {
+	sc := bufio.NewScanner(r)
+	for ln := 1; sc.Scan(); ln++ {
+		fields := strings.Fields(sc.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return fmt.Errorf("source map:%d: expected \"OUT:LINE SRC:LINE\"", ln)
+		}
+		out, err := parseSourceLine(fields[0], dir)
+		if err != nil {
+			return fmt.Errorf("source map:%d: %v", ln, err)
+		}
+		src, err := parseSourceLine(fields[1], dir)
+		if err != nil {
+			return fmt.Errorf("source map:%d: %v", ln, err)
+		}
+		c.outputs[out.File] = true
+		if filepath.Ext(out.File) == ".hack" {
+			if out.Line > SNAPSHOT_ROM_SIZE {
+				return fmt.Errorf("source map:%d: %s is outside the ROM", ln, out)
+			}
+			c.rom[out.Line-1] = src
+		} else {
+			c.links[out] = src
+		}
+	}
+	return sc.Err()
+}
+
+// Sources returns the files the loaded maps refer to which are not the
+// output of a loaded map, i.e. the files whose own map may be missing.
+func (c *Coverage) Sources() []string {
+	seen := make(map[string]bool)
+	var files []string
+	add := func(l SourceLine) {
+		if !c.outputs[l.File] && !seen[l.File] {
+			seen[l.File] = true
+			files = append(files, l.File)
+		}
+	}
+	for _, src := range c.rom {
+		add(src)
+	}
+	for _, src := range c.links {
+		add(src)
+	}
+	sort.Strings(files)
+	return files
+}
+
+// Files returns the coverage of every mapped source file, sorted by path.
+// A line generating several instructions counts the executions of the most
+// executed one.
+func (c *Coverage) Files() []FileCoverage {
+	lines := make(map[string]map[int]uint64)
+	for addr, src := range c.rom {
+		n := c.counts[addr]
+		for depth, ok := 0, true; ok && depth < COVERAGE_MAX_DEPTH; depth++ {
+			m := lines[src.File]
+			if m == nil {
+				m = make(map[int]uint64)
+				lines[src.File] = m
+			}
+			if n >= m[src.Line] {
+				m[src.Line] = n
+			}
+			src, ok = c.links[src]
+		}
+	}
+	files := make([]FileCoverage, 0, len(lines))
+	for f, m := range lines {
+		files = append(files, FileCoverage{File: f, Lines: m})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].File < files[j].File })
+	return files
+}
+
+// WriteLCOV writes the coverage as an LCOV tracefile, as read by genhtml
+// and most coverage services.
+func (c *Coverage) WriteLCOV(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	for _, f := range c.Files() {
+		fmt.Fprintf(bw, "TN:\nSF:%s\n", f.File)
+		for _, ln := range sortedLines(f) {
+			fmt.Fprintf(bw, "DA:%d,%d\n", ln, f.Lines[ln])
+		}
+		fmt.Fprintf(bw, "LF:%d\nLH:%d\nend_of_record\n", len(f.Lines), f.Hit())
+	}
+	return bw.Flush()
+}
+
+func sortedLines(f FileCoverage) []int {
+	lines := make([]int, 0, len(f.Lines))
+	for ln := range f.Lines {
+		lines = append(lines, ln)
+	}
+	sort.Ints(lines)
+	return lines
+}
+
+// WriteHTML writes a page with the coverage of every file and, for the
+// files which can be read, their source with the lines marked.
+func (c *Coverage) WriteHTML(w io.Writer) error {
+	files := c.Files()
+	bw := bufio.NewWriter(w)
+	bw.WriteString(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>Coverage</title>
+<style>
+body { font-family: sans-serif; }
+td, th { padding: 0 1em; text-align: right; }
+td:first-child, th:first-child { text-align: left; }
+pre span { display: block; }
+.hit { background: #dfd; }
+.miss { background: #fdd; }
+</style></head><body>
+<h1>Coverage</h1>
+<table>
+<tr><th>File</th><th>Lines</th><th>Hit</th><th>Coverage</th></tr>
+`)
+	var total, hit uint64
+	for i, f := range files {
+		total += uint64(len(f.Lines))
+		hit += uint64(f.Hit())
+		fmt.Fprintf(bw, "<tr><td><a href=\"#f%d\">%s</a></td><td>%d</td><td>%d</td><td>%.1f%%</td></tr>\n",
+			i, html.EscapeString(f.File), len(f.Lines), f.Hit(), percent(uint64(f.Hit()), uint64(len(f.Lines))))
+	}
+	fmt.Fprintf(bw, "<tr><th>Total</th><th>%d</th><th>%d</th><th>%.1f%%</th></tr>\n</table>\n",
+		total, hit, percent(hit, total))
+
+	for i, f := range files {
+		fmt.Fprintf(bw, "<h2 id=\"f%d\">%s</h2>\n", i, html.EscapeString(f.File))
+		src, err := ioutil.ReadFile(f.File)
+		if err != nil {
+			fmt.Fprintf(bw, "<p>%s</p>\n", html.EscapeString(err.Error()))
+			continue
+		}
+		bw.WriteString("<pre>")
+		for j, text := range strings.Split(strings.TrimSuffix(string(src), "\n"), "\n") {
+			class, count := "", ""
+			if n, ok := f.Lines[j+1]; ok {
+				class, count = "miss", strconv.FormatUint(n, 10)
+				if n > 0 {
+					class = "hit"
+				}
+			}
+			fmt.Fprintf(bw, "<span class=\"%s\">%5d %8s  %s</span>", class, j+1, count,
+				html.EscapeString(strings.TrimSuffix(text, "\r")))
+		}
+		bw.WriteString("</pre>\n")
+	}
+	bw.WriteString("</body></html>\n")
+	return bw.Flush()
+}
@@ -0,0 +1,94 @@
+package computer
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// maxSourceMaps maps the 16 instructions of maxInstructions to the lines of
+// Max.asm, three VM commands and two Jack lines. ROM[10] and ROM[11] come
+// from the second VM command, which is skipped when RAM[0] < RAM[1].
+func maxSourceMaps() []string {
+	var hack, asm strings.Builder
+	for i := 1; i <= 16; i++ {
+		fmt.Fprintf(&hack, "Max.hack:%d Max.asm:%d\n", i, i+2)
+		vm := 1
+		if i > 12 {
+			vm = 3
+		} else if i > 10 {
+			vm = 2
+		}
+		fmt.Fprintf(&asm, "Max.asm:%d sub/Max.vm:%d\n", i+2, vm)
+	}
+	jack := "Max.vm:1 Max.jack:5\nMax.vm:2 Max.jack:6\nMax.vm:3 Max.jack:6\n"
+	return []string{hack.String(), asm.String(), jack}
+}
+
+func TestCoverage(t *testing.T) {
+	com, _, _ := newFastComputer(maxInstructions)
+	com.SetRAM(0, 6)
+	com.SetRAM(1, 10)
+	c := NewCoverage()
+	com.AddObserver(c)
+	com.Run(1000)
+
+	dir := t.TempDir()
+	maps := maxSourceMaps()
+	require.NoError(t, c.LoadSourceMap(strings.NewReader(maps[0]), dir))
+	assert.Equal(t, []string{filepath.Join(dir, "Max.asm")}, c.Sources())
+	require.NoError(t, c.LoadSourceMap(strings.NewReader(maps[1]), dir))
+	require.NoError(t, c.LoadSourceMap(strings.NewReader(maps[2]), filepath.Join(dir, "sub")))
+	assert.Equal(t, []string{filepath.Join(dir, "sub", "Max.jack")}, c.Sources())
+
+	files := c.Files()
+	require.Len(t, files, 3)
+	assert.Equal(t, filepath.Join(dir, "Max.asm"), files[0].File)
+	assert.Len(t, files[0].Lines, 16)
+	// Run stops at the halting loop of ROM[14] and ROM[15].
+	assert.Equal(t, 12, files[0].Hit())
+	assert.Equal(t, uint64(0), files[0].Lines[13])
+	assert.Equal(t, uint64(1), files[0].Lines[3])
+
+	assert.Equal(t, filepath.Join(dir, "sub", "Max.jack"), files[1].File)
+	assert.Equal(t, map[int]uint64{5: 1, 6: 1}, files[1].Lines, "a line counts its most executed instruction")
+
+	assert.Equal(t, filepath.Join(dir, "sub", "Max.vm"), files[2].File)
+	assert.Equal(t, map[int]uint64{1: 1, 2: 0, 3: 1}, files[2].Lines)
+
+	var buf bytes.Buffer
+	require.NoError(t, c.WriteLCOV(&buf))
+	assert.Contains(t, buf.String(), fmt.Sprintf("TN:\nSF:%s\nDA:1,1\nDA:2,0\nDA:3,1\nLF:3\nLH:2\nend_of_record\n",
+		filepath.Join(dir, "sub", "Max.vm")))
+	assert.Equal(t, 3, strings.Count(buf.String(), "end_of_record"))
+
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "sub"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "sub", "Max.vm"),
+		[]byte("push argument 0\npush argument 1\ngt\n// <max>\n"), 0644))
+	buf.Reset()
+	require.NoError(t, c.WriteHTML(&buf))
+	out := buf.String()
+	assert.Contains(t, out, "<td>3</td><td>2</td><td>66.7%</td>")
+	assert.Contains(t, out, "<tr><th>Total</th><th>21</th><th>16</th>")
+	assert.Contains(t, out, `<span class="miss">    2        0  push argument 1</span>`)
+	assert.Contains(t, out, `<span class="">    4           // &lt;max&gt;</span>`)
+	assert.Contains(t, out, "<p>open ", "sources which cannot be read have no listing")
+}
+
+func TestLoadSourceMapErrors(t *testing.T) {
+	for _, s := range []string{
+		"Max.hack:1",
+		"Max.hack Max.asm:1",
+		"Max.hack:0 Max.asm:1",
+		"Max.hack:32769 Max.asm:1",
+	} {
+		assert.Error(t, NewCoverage().LoadSourceMap(strings.NewReader(s), "."), s)
+	}
+}
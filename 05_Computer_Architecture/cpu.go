@@ -12,6 +12,17 @@ type Word = [16]Bit
 
 type Address = [15]Bit
 
+// ICPU executes a single Hack instruction per Fetch and exposes its registers.
+type ICPU interface {
+	Fetch(inM, inst Word, reset Bit) (outM Word, writeM Bit, addressM, pc [15]Bit)
+	A() Word
+	D() Word
+	PC() Address
+	SetA(Word)
+	SetD(Word)
+	SetPC(Address)
+}
+
 type CPU struct {
 	a, d   memory.Register
 	pc     memory.PC
@@ -63,6 +74,47 @@ func (cpu *CPU) Fetch(inM, inst Word, reset Bit) (outM Word, writeM Bit, address
 	return
 }
 
+func (cpu *CPU) A() Word {
+	return cpu.a.Apply(logic.O, Word{})
+}
+
+func (cpu *CPU) D() Word {
+	return cpu.d.Apply(logic.O, Word{})
+}
+
+func (cpu *CPU) PC() (pc Address) {
+	_pc := cpu.pc.Apply(Word{}, logic.O, logic.O, logic.O)
+	copy(pc[:], _pc[:15])
+	return
+}
+
+func (cpu *CPU) SetA(w Word) {
+	cpu.set(func() { cpu.a.Apply(logic.I, w) })
+}
+
+func (cpu *CPU) SetD(w Word) {
+	cpu.set(func() { cpu.d.Apply(logic.I, w) })
+}
+
+func (cpu *CPU) SetPC(pc Address) {
+	var w Word
+	copy(w[:15], pc[:])
+	cpu.set(func() { cpu.pc.Apply(w, logic.I, logic.O, logic.O) })
+}
+
+// set applies load outside of Fetch. Every register is read before and after
+// the clock tick so that none of them is left with a stale DFF timestamp.
+func (cpu *CPU) set(load func()) {
+	cpu.A()
+	cpu.D()
+	cpu.PC()
+	load()
+	cpu.clock.Progress()
+	cpu.A()
+	cpu.D()
+	cpu.PC()
+}
+
 func (c *CPU) decode(inst Word) (i Bit, a Bit, cccccc []Bit, ddd, jjj []Bit) {
 	return inst[15],
 		inst[12],
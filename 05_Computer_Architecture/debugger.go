@@ -0,0 +1,406 @@
+package computer
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	logic "github.com/kazufusa/nand2tetris/01_Boolean_Logic"
+	assembler "github.com/kazufusa/nand2tetris/06_Assembler"
+)
+
+const (
+	SCREEN_ADDR = 16384
+	KBD_ADDR    = 24576
+)
+
+type StopReason int
+
+const (
+	STOP_STEP StopReason = iota
+	STOP_BREAKPOINT
+	STOP_WATCHPOINT
+	STOP_PAUSED
+	STOP_LIMIT
+)
+
+func (r StopReason) String() string {
+	switch r {
+	case STOP_STEP:
+		return "step"
+	case STOP_BREAKPOINT:
+		return "breakpoint"
+	case STOP_WATCHPOINT:
+		return "watchpoint"
+	case STOP_PAUSED:
+		return "paused"
+	case STOP_LIMIT:
+		return "cycle limit"
+	}
+	return "unknown"
+}
+
+// Watch covers the RAM addresses From..To inclusive.
+type Watch struct {
+	From, To int
+}
+
+func (w Watch) contains(addr int) bool {
+	return w.From <= addr && addr <= w.To
+}
+
+// WatchHit describes the access that triggered a watchpoint.
+type WatchHit struct {
+	Watch Watch
+	Addr  int
+	Value int
+}
+
+// Debugger drives a Computer one instruction at a time and stops on
+// breakpoints (ROM addresses) and watchpoints (RAM writes).
+type Debugger struct {
+	mu          sync.Mutex
+	com         *Computer
+	breakpoints map[int]bool
+	watches     []Watch
+	symbols     map[string]int
+	lastKbd     int
+	lastHit     *WatchHit
+	paused      int32
+	history     *History
+	// ext, if set, is taken before mu.
+	ext sync.Locker
+	// variables holds the RAM addresses of the symbol file.
+	variables map[string]int
+}
+
+// ErrNoHistory is returned when stepping back beyond the recorded history.
+var ErrNoHistory = errors.New("no recorded history")
+
+func NewDebugger(com *Computer) *Debugger {
+	return &Debugger{
+		com:         com,
+		breakpoints: make(map[int]bool),
+		symbols:     make(map[string]int),
+		variables:   make(map[string]int),
+	}
+}
+
+// SetLock makes the debugger hold l whenever it uses the Computer, so that
+// it does not run concurrently with other users of the Computer. It must be
+// called before the debugger is used.
+func (d *Debugger) SetLock(l sync.Locker) {
+	d.ext = l
+}
+
+func (d *Debugger) lock() {
+	if d.ext != nil {
+		d.ext.Lock()
+	}
+	d.mu.Lock()
+}
+
+func (d *Debugger) unlock() {
+	d.mu.Unlock()
+	if d.ext != nil {
+		d.ext.Unlock()
+	}
+}
+
+// LoadSymbols reads the ROM labels and the RAM variables of a symbol file.
+func (d *Debugger) LoadSymbols(r io.Reader) error {
+	syms, err := assembler.ReadSymbols(r)
+	if err != nil {
+		return err
+	}
+	d.lock()
+	defer d.unlock()
+	for _, s := range syms {
+		if s.RAM {
+			d.variables[s.Name] = s.Addr
+		} else {
+			d.symbols[s.Name] = s.Addr
+		}
+	}
+	return nil
+}
+
+// Resolve converts a decimal address or a label into a ROM address.
+func (d *Debugger) Resolve(loc string) (int, error) {
+	d.lock()
+	defer d.unlock()
+	return d.resolve(loc)
+}
+
+func (d *Debugger) resolve(loc string) (int, error) {
+	if addr, err := strconv.Atoi(loc); err == nil {
+		return addr, nil
+	}
+	if addr, ok := d.symbols[loc]; ok {
+		return addr, nil
+	}
+	if _, ok := d.variables[loc]; ok {
+		return 0, fmt.Errorf("%s is a RAM variable, not a ROM location", loc)
+	}
+	return 0, fmt.Errorf("unknown location %q", loc)
+}
+
+// ResolveRAM converts a decimal address, SCREEN, KBD or a variable into a
+// RAM address. Labels are rejected, as they are ROM addresses.
+func (d *Debugger) ResolveRAM(loc string) (int, error) {
+	d.lock()
+	defer d.unlock()
+	if addr, err := strconv.Atoi(loc); err == nil {
+		return addr, nil
+	}
+	switch loc {
+	case "SCREEN":
+		return SCREEN_ADDR, nil
+	case "KBD":
+		return KBD_ADDR, nil
+	}
+	if addr, ok := d.variables[loc]; ok {
+		return addr, nil
+	}
+	if _, ok := d.symbols[loc]; ok {
+		return 0, fmt.Errorf("%s is a ROM label, not a RAM address", loc)
+	}
+	return 0, fmt.Errorf("unknown RAM address %q", loc)
+}
+
+func (d *Debugger) Break(loc string) error {
+	d.lock()
+	defer d.unlock()
+	addr, err := d.resolve(loc)
+	if err != nil {
+		return err
+	}
+	d.breakpoints[addr] = true
+	return nil
+}
+
+func (d *Debugger) ClearBreak(loc string) error {
+	d.lock()
+	defer d.unlock()
+	addr, err := d.resolve(loc)
+	if err != nil {
+		return err
+	}
+	delete(d.breakpoints, addr)
+	return nil
+}
+
+func (d *Debugger) Breakpoints() []int {
+	d.lock()
+	defer d.unlock()
+	ret := make([]int, 0, len(d.breakpoints))
+	for addr := range d.breakpoints {
+		ret = append(ret, addr)
+	}
+	sort.Ints(ret)
+	return ret
+}
+
+func (d *Debugger) Watch(from, to int) {
+	d.lock()
+	defer d.unlock()
+	d.watches = append(d.watches, Watch{From: from, To: to})
+	d.lastKbd = d.com.RAM(KBD_ADDR)
+}
+
+// ClearWatch removes the watchpoints covering exactly w.
+func (d *Debugger) ClearWatch(w Watch) {
+	d.lock()
+	defer d.unlock()
+	watches := d.watches[:0]
+	for _, x := range d.watches {
+		if x != w {
+			watches = append(watches, x)
+		}
+	}
+	d.watches = watches
+}
+
+func (d *Debugger) ClearWatches() {
+	d.lock()
+	defer d.unlock()
+	d.watches = nil
+}
+
+func (d *Debugger) Watches() []Watch {
+	d.lock()
+	defer d.unlock()
+	return append([]Watch(nil), d.watches...)
+}
+
+// LastHit returns the access which stopped the last run on a watchpoint.
+func (d *Debugger) LastHit() *WatchHit {
+	d.lock()
+	defer d.unlock()
+	return d.lastHit
+}
+
+// Registers returns A, D and PC as integers.
+func (d *Debugger) Registers() (a, dd, pc int) {
+	d.lock()
+	defer d.unlock()
+	return d.com.A(), d.com.D(), d.com.PC()
+}
+
+// Memory returns RAM[from..to] inclusive.
+func (d *Debugger) Memory(from, to int) []int {
+	d.lock()
+	defer d.unlock()
+	var ret []int
+	for addr := from; addr <= to; addr++ {
+		ret = append(ret, d.com.RAM(addr))
+	}
+	return ret
+}
+
+// Step executes exactly one instruction.
+func (d *Debugger) Step() StopReason {
+	d.lock()
+	defer d.unlock()
+	if d.step() {
+		return STOP_WATCHPOINT
+	}
+	return STOP_STEP
+}
+
+// StepOver executes one instruction, but runs a jump through until control
+// comes back to the following instruction, as it does after a VM call.
+func (d *Debugger) StepOver(limit int) StopReason {
+	d.lock()
+	pc := d.com.PC()
+	inst := d.com.ROM(pc)
+	d.unlock()
+	if inst >= 0 || inst&0x7 == 0 {
+		return d.Step()
+	}
+	return d.run(limit, func(next int) bool { return next == pc+1 })
+}
+
+// Continue runs until a breakpoint, a watchpoint, Pause or limit cycles
+// elapse. A limit of 0 or less means no limit.
+func (d *Debugger) Continue(limit int) StopReason {
+	return d.run(limit, func(int) bool { return false })
+}
+
+// Pause stops a running Continue or StepOver from another goroutine.
+func (d *Debugger) Pause() {
+	atomic.StoreInt32(&d.paused, 1)
+}
+
+func (d *Debugger) run(limit int, done func(int) bool) StopReason {
+	atomic.StoreInt32(&d.paused, 0)
+	for i := 0; limit <= 0 || i < limit; i++ {
+		if atomic.LoadInt32(&d.paused) == 1 {
+			return STOP_PAUSED
+		}
+		d.lock()
+		hit := d.step()
+		pc := d.com.PC()
+		brk := d.breakpoints[pc]
+		d.unlock()
+		switch {
+		case hit:
+			return STOP_WATCHPOINT
+		case brk:
+			return STOP_BREAKPOINT
+		case done(pc):
+			return STOP_STEP
+		}
+	}
+	return STOP_LIMIT
+}
+
+// SetHistory lets the debugger step backwards through h, which must be
+// attached to the same Computer.
+func (d *Debugger) SetHistory(h *History) {
+	d.lock()
+	defer d.unlock()
+	d.history = h
+}
+
+// Cycle returns the number of executed instructions and the earliest cycle
+// Rewind accepts. ok is false without a history.
+func (d *Debugger) Cycle() (cycle, oldest uint64, ok bool) {
+	d.lock()
+	defer d.unlock()
+	if d.history == nil {
+		return 0, 0, false
+	}
+	return d.history.Cycle(), d.history.Oldest(), true
+}
+
+// StepBack undoes the last instruction.
+func (d *Debugger) StepBack() error {
+	d.lock()
+	defer d.unlock()
+	if d.history == nil || !d.history.StepBack() {
+		return ErrNoHistory
+	}
+	return nil
+}
+
+// RunBackToWrite rewinds to the latest instruction which wrote RAM[addr],
+// which is then the next instruction to execute.
+func (d *Debugger) RunBackToWrite(addr int) error {
+	d.lock()
+	defer d.unlock()
+	if d.history == nil {
+		return ErrNoHistory
+	}
+	if !d.history.RunBackToWrite(addr) {
+		return fmt.Errorf("no write to RAM[%d] since cycle %d", addr, d.history.Oldest())
+	}
+	return nil
+}
+
+// Rewind returns to the state before instruction cycle was executed.
+func (d *Debugger) Rewind(cycle uint64) error {
+	d.lock()
+	defer d.unlock()
+	if d.history == nil {
+		return ErrNoHistory
+	}
+	return d.history.Rewind(cycle)
+}
+
+// step executes one instruction and reports whether a watchpoint triggered.
+func (d *Debugger) step() bool {
+	inst := d.com.ROM(d.com.PC())
+	d.com.FetchAndExecute(logic.O)
+	d.lastHit = nil
+	if len(d.watches) == 0 {
+		return false
+	}
+
+	if inst < 0 && inst&0x8 != 0 {
+		addr := d.com.A()
+		for _, w := range d.watches {
+			if w.contains(addr) {
+				d.lastHit = &WatchHit{Watch: w, Addr: addr, Value: d.com.RAM(addr)}
+				return true
+			}
+		}
+	}
+
+	for _, w := range d.watches {
+		if !w.contains(KBD_ADDR) {
+			continue
+		}
+		if kbd := d.com.RAM(KBD_ADDR); kbd != d.lastKbd {
+			d.lastKbd = kbd
+			d.lastHit = &WatchHit{Watch: w, Addr: KBD_ADDR, Value: kbd}
+			return true
+		}
+		break
+	}
+	return false
+}
@@ -0,0 +1,154 @@
+package computer
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDebugger(t *testing.T) {
+	com := NewEmulator(maxInstructions)
+	com.WriteRom(0, 6)
+	com.WriteRom(1, 10)
+	d := NewDebugger(com)
+	require.NoError(t, d.LoadSymbols(strings.NewReader("OUTPUT_FIRST 10\nOUTPUT_D 12 ROM\nresult 2 RAM\n")))
+	assert.Error(t, d.LoadSymbols(strings.NewReader("OUTPUT_D 12 RAW\n")))
+
+	assert.Equal(t, STOP_STEP, d.Step())
+	_, _, pc := d.Registers()
+	assert.Equal(t, 1, pc)
+
+	require.NoError(t, d.Break("OUTPUT_D"))
+	assert.Error(t, d.Break("NOWHERE"))
+	assert.Error(t, d.Break("result"), "variables are not locations in ROM")
+	assert.Equal(t, []int{12}, d.Breakpoints())
+	assert.Equal(t, STOP_BREAKPOINT, d.Continue(100))
+	a, dd, pc := d.Registers()
+	assert.Equal(t, 12, a)
+	assert.Equal(t, 10, dd)
+	assert.Equal(t, 12, pc)
+
+	require.NoError(t, d.ClearBreak("12"))
+	d.Watch(2, 2)
+	assert.Equal(t, STOP_WATCHPOINT, d.Continue(100))
+	assert.Equal(t, &WatchHit{Watch: Watch{2, 2}, Addr: 2, Value: 10}, d.LastHit())
+	_, _, pc = d.Registers()
+	assert.Equal(t, 14, pc)
+	assert.Equal(t, []int{6, 10, 10}, d.Memory(0, 2))
+
+	addr, err := d.ResolveRAM("result")
+	require.NoError(t, err)
+	assert.Equal(t, 2, addr)
+	addr, err = d.ResolveRAM("KBD")
+	require.NoError(t, err)
+	assert.Equal(t, KBD_ADDR, addr)
+	_, err = d.ResolveRAM("OUTPUT_D")
+	assert.EqualError(t, err, "OUTPUT_D is a ROM label, not a RAM address")
+	_, err = d.ResolveRAM("nothing")
+	assert.Error(t, err)
+
+	d.Watch(100, 200)
+	d.ClearWatch(Watch{2, 2})
+	assert.Equal(t, []Watch{{100, 200}}, d.Watches())
+	d.ClearWatches()
+	assert.Equal(t, STOP_LIMIT, d.Continue(10))
+	d.Pause()
+	assert.Equal(t, STOP_LIMIT, d.Continue(10), "Continue clears a stale Pause")
+}
+
+// countingLocker counts how often it is locked.
+type countingLocker struct {
+	sync.Mutex
+	locks int
+}
+
+func (l *countingLocker) Lock() {
+	l.Mutex.Lock()
+	l.locks++
+}
+
+func TestDebuggerLock(t *testing.T) {
+	com := NewEmulator(maxInstructions)
+	d := NewDebugger(com)
+	var l countingLocker
+	d.SetLock(&l)
+	d.Step()
+	assert.Equal(t, 1, l.locks)
+	assert.Equal(t, STOP_LIMIT, d.Continue(10))
+	assert.Equal(t, 11, l.locks, "taken for every instruction")
+
+	l.Lock()
+	done := make(chan StopReason)
+	go func() { done <- d.Step() }()
+	select {
+	case <-done:
+		t.Fatal("Step ran while the lock was held")
+	case <-time.After(10 * time.Millisecond):
+	}
+	l.Unlock()
+	assert.Equal(t, STOP_STEP, <-done)
+}
+
+func TestDebuggerStepOver(t *testing.T) {
+	com := NewEmulator(maxInstructions)
+	com.WriteRom(0, 6)
+	com.WriteRom(1, 10)
+	d := NewDebugger(com)
+	for i := 0; i < 5; i++ {
+		d.Step()
+	}
+	_, _, pc := d.Registers()
+	require.Equal(t, 5, pc)
+
+	// D;JGT at 5 is not taken, so control reaches 6 directly.
+	assert.Equal(t, STOP_STEP, d.StepOver(100))
+	_, _, pc = d.Registers()
+	assert.Equal(t, 6, pc)
+
+	// 0;JMP at 15 never returns to 16.
+	for i := 0; i < 9; i++ {
+		d.Step()
+	}
+	assert.Equal(t, STOP_LIMIT, d.StepOver(50))
+}
+
+func TestDebuggerReverse(t *testing.T) {
+	com := NewEmulator(maxInstructions)
+	com.WriteRom(0, 6)
+	com.WriteRom(1, 10)
+	d := NewDebugger(com)
+	assert.Equal(t, ErrNoHistory, d.StepBack())
+	_, _, ok := d.Cycle()
+	assert.False(t, ok)
+
+	h := NewHistory(100)
+	h.Attach(com)
+	d.SetHistory(h)
+	d.Watch(2, 2)
+	require.Equal(t, STOP_WATCHPOINT, d.Continue(100))
+	d.ClearWatches()
+	d.Continue(6)
+	cycle, oldest, ok := d.Cycle()
+	require.True(t, ok)
+	assert.Equal(t, uint64(18), cycle)
+	assert.Equal(t, uint64(0), oldest)
+
+	require.NoError(t, d.RunBackToWrite(2))
+	_, dd, pc := d.Registers()
+	assert.Equal(t, 13, pc, "M=D storing the maximum")
+	assert.Equal(t, 10, dd)
+	assert.Equal(t, []int{0}, d.Memory(2, 2), "before the write")
+	assert.Error(t, d.RunBackToWrite(3))
+
+	require.NoError(t, d.StepBack())
+	_, _, pc = d.Registers()
+	assert.Equal(t, 12, pc)
+	require.NoError(t, d.Rewind(1))
+	_, _, pc = d.Registers()
+	assert.Equal(t, 1, pc)
+	assert.Error(t, d.Rewind(2))
+}
@@ -0,0 +1,48 @@
+package computer
+
+import (
+	"fmt"
+
+	assembler "github.com/kazufusa/nand2tetris/06_Assembler"
+)
+
+// code decodes the fields of C-instructions with the tables of the
+// assembler.
+var code = &assembler.Code{}
+
+// Disassemble returns the assembly form of a single instruction, e.g. "@10"
+// or "D;JGT". Words whose comp field has no mnemonic are returned in binary.
+func Disassemble(inst int) string {
+	w := uint16(inst)
+	if w&0x8000 == 0 {
+		return fmt.Sprintf("@%d", w)
+	}
+	bits := fmt.Sprintf("%016b", w)
+	comp, ok := code.CompMnemonic(bits[3:10])
+	if !ok {
+		return bits
+	}
+	s := comp
+	if d, _ := code.DestMnemonic(bits[10:13]); d != "" {
+		s = d + "=" + s
+	}
+	if j, _ := code.JumpMnemonic(bits[13:]); j != "" {
+		s += ";" + j
+	}
+	return s
+}
+
+// readsM reports whether inst is a C-instruction whose comp uses M.
+func readsM(inst int) bool {
+	return inst < 0 && inst&0x1000 != 0
+}
+
+// jumps reports whether the C-instruction inst jumps given the ALU output.
+func jumps(inst, out int) bool {
+	if inst >= 0 {
+		return false
+	}
+	return inst&0x1 != 0 && out > 0 ||
+		inst&0x2 != 0 && out == 0 ||
+		inst&0x4 != 0 && out < 0
+}
@@ -0,0 +1,34 @@
+package computer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDisassemble(t *testing.T) {
+	var got []string
+	for _, line := range strings.Split(maxInstructionString, "\n") {
+		got = append(got, Disassemble(word2Int(string2Word(line))))
+	}
+	assert.Equal(t, []string{
+		"@0", "D=M", "@1", "D=D-M", "@10", "D;JGT", "@1", "D=M",
+		"@12", "0;JMP", "@0", "D=M", "@2", "M=D", "@14", "0;JMP",
+	}, got)
+
+	assert.Equal(t, "@32767", Disassemble(0x7fff))
+	assert.Equal(t, "AMD=M+1;JMP", Disassemble(int(int16(-0x0201))))
+	assert.Equal(t, "1111111111000000", Disassemble(-64), "unknown comp")
+}
+
+func TestJumps(t *testing.T) {
+	jgt, jeq, jle, jmp := -7423, -7422, -7418, -7417
+	assert.True(t, jumps(jgt, 1))
+	assert.False(t, jumps(jgt, 0))
+	assert.True(t, jumps(jeq, 0))
+	assert.True(t, jumps(jle, -1))
+	assert.False(t, jumps(jle, 1))
+	assert.True(t, jumps(jmp, 5))
+	assert.False(t, jumps(10, 0), "A-instruction")
+}
@@ -28,14 +28,10 @@ func NewEmulator(inst interface{}) *Computer {
 	return &Computer{cpu: &cpu, ram: &ram, rom: &rom, clock: &clock}
 }
 
+// WriteRom writes value to RAM[addr]. It is kept for existing callers; use
+// SetRAM instead.
 func (com *Computer) WriteRom(addr, value int) {
-	switch x := com.ram.(type) {
-	case *VMemory:
-		if x.values == nil {
-			x.values = make(map[int]Word)
-		}
-		x.values[addr] = int2Word(value)
-	}
+	com.SetRAM(addr, value)
 }
 
 func (com *Computer) ROMMap() (ret map[int]int) {
@@ -66,7 +62,7 @@ type VMemory struct {
 	values map[int]Word
 }
 
-var _ IMemory = (*Memory)(nil)
+var _ IMemory = (*VMemory)(nil)
 
 type VROM32K struct {
 	values  map[int]Word
@@ -96,6 +92,26 @@ func (m *VROM32K) Fetch(addr [15]Bit) (out Word) {
 	return m.values[addr2int(addr)]
 }
 
+func (m *VROM32K) Peek(addr [15]Bit) (out Word) {
+	return m.values[addr2int(addr)]
+}
+
+func (m *VROM32K) Store(addr [15]Bit, w Word) {
+	if m.values == nil {
+		m.values = make(map[int]Word)
+	}
+	m.values[addr2int(addr)] = w
+}
+
+func (m *VROM32K) LoadHackFile(p string) error {
+	ws, err := readHackFile(p)
+	if err != nil {
+		return err
+	}
+	m.BulkLoad(ws)
+	return nil
+}
+
 func (m *VROM32K) BulkLoad(ws []Word) {
 	if m.values == nil {
 		m.values = make(map[int]Word)
@@ -123,6 +139,12 @@ func addr2int(addr [15]Bit) int {
 		(B14)*int(addr[14])
 }
 
+func int2Addr(v int) (addr [15]Bit) {
+	w := int2Word(v)
+	copy(addr[:], w[:15])
+	return addr
+}
+
 func word2Int(w Word) int {
 	ret := (B0)*int(w[0]) +
 		(B1)*int(w[1]) +
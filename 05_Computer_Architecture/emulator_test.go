@@ -0,0 +1,24 @@
+package computer
+
+import (
+	"testing"
+
+	logic "github.com/kazufusa/nand2tetris/01_Boolean_Logic"
+	assembler "github.com/kazufusa/nand2tetris/06_Assembler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAssemblerWithEmulator(t *testing.T) {
+	asm, err := assembler.NewAssembler("../06_Assembler/Max.asm")
+	require.NoError(t, err)
+	ret, err := asm.Assemble()
+	require.NoError(t, err)
+	com := NewEmulator(ret)
+	com.WriteRom(0, 14)
+	com.WriteRom(1, 12)
+	for i := 0; i < 20; i++ {
+		com.FetchAndExecute(logic.O)
+	}
+	assert.Equal(t, 14, com.ROMMap()[2])
+}
@@ -0,0 +1,143 @@
+package computer
+
+import (
+	logic "github.com/kazufusa/nand2tetris/01_Boolean_Logic"
+)
+
+// FastCPU executes Hack instructions directly on machine integers instead of
+// evaluating the gate-level ALU and registers. It is cycle-compatible with CPU.
+type FastCPU struct {
+	a, d int16
+	pc   uint16
+}
+
+var (
+	_ ICPU = (*CPU)(nil)
+	_ ICPU = (*FastCPU)(nil)
+)
+
+func NewFastCPU() FastCPU {
+	return FastCPU{}
+}
+
+func (cpu *FastCPU) Fetch(inM, inst Word, reset Bit) (outM Word, writeM Bit, addressM, pc [15]Bit) {
+	in := word2Uint16(inst)
+	isC := in&0x8000 != 0
+
+	x := cpu.d
+	y := cpu.a
+	if in&0x1000 != 0 {
+		y = int16(word2Uint16(inM))
+	}
+	out := alu(x, y, in>>6)
+
+	jump := false
+	if isC {
+		jump = in&0x4 != 0 && out < 0 ||
+			in&0x2 != 0 && out == 0 ||
+			in&0x1 != 0 && out > 0
+	}
+
+	switch {
+	case reset == logic.I:
+		cpu.pc = 0
+	case jump:
+		cpu.pc = uint16(cpu.a)
+	default:
+		cpu.pc++
+	}
+
+	if !isC {
+		cpu.a = int16(in)
+	} else {
+		if in&0x20 != 0 {
+			cpu.a = out
+		}
+		if in&0x10 != 0 {
+			cpu.d = out
+		}
+		if in&0x8 != 0 {
+			writeM = logic.I
+		}
+	}
+
+	outM = uint162Word(uint16(out))
+	addressM = uint162Address(uint16(cpu.a))
+	pc = uint162Address(cpu.pc)
+	return
+}
+
+func (cpu *FastCPU) A() Word {
+	return uint162Word(uint16(cpu.a))
+}
+
+func (cpu *FastCPU) D() Word {
+	return uint162Word(uint16(cpu.d))
+}
+
+func (cpu *FastCPU) PC() Address {
+	return uint162Address(cpu.pc)
+}
+
+// alu applies the six control bits zx, nx, zy, ny, f and no (from the most
+// significant to the least significant bit of c) to x and y.
+func alu(x, y int16, c uint16) int16 {
+	if c&0x20 != 0 {
+		x = 0
+	}
+	if c&0x10 != 0 {
+		x = ^x
+	}
+	if c&0x8 != 0 {
+		y = 0
+	}
+	if c&0x4 != 0 {
+		y = ^y
+	}
+	var out int16
+	if c&0x2 != 0 {
+		out = x + y
+	} else {
+		out = x & y
+	}
+	if c&0x1 != 0 {
+		out = ^out
+	}
+	return out
+}
+
+func word2Uint16(w Word) uint16 {
+	var ret uint16
+	for i := 15; i >= 0; i-- {
+		ret = ret<<1 | uint16(w[i])
+	}
+	return ret
+}
+
+func uint162Word(v uint16) (w Word) {
+	for i := range w {
+		w[i] = Bit(v >> uint(i) & 1)
+	}
+	return w
+}
+
+func uint162Address(v uint16) (addr Address) {
+	for i := range addr {
+		addr[i] = Bit(v >> uint(i) & 1)
+	}
+	return addr
+}
+
+func (cpu *FastCPU) SetA(w Word) {
+	cpu.a = int16(word2Uint16(w))
+}
+
+func (cpu *FastCPU) SetD(w Word) {
+	cpu.d = int16(word2Uint16(w))
+}
+
+func (cpu *FastCPU) SetPC(pc Address) {
+	var w Word
+	copy(w[:15], pc[:])
+	cpu.pc = word2Uint16(w)
+}
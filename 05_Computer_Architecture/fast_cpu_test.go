@@ -0,0 +1,97 @@
+package computer
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	logic "github.com/kazufusa/nand2tetris/01_Boolean_Logic"
+	memory "github.com/kazufusa/nand2tetris/03_Memory"
+	"github.com/stretchr/testify/require"
+)
+
+// runDifferential executes the same ROM on the gate-level CPU and on FastCPU
+// and fails as soon as A, D, PC or RAM diverge.
+func runDifferential(t *testing.T, inst []Word, ram map[int]int, cycles int) {
+	t.Helper()
+	newComputer := func(cpu ICPU) *Computer {
+		clock := memory.Clock(0)
+		rom := VROM32K{}
+		rom.BulkLoad(inst)
+		com := NewComputer(cpu, &VMemory{}, &rom, &clock)
+		for addr, v := range ram {
+			com.WriteRom(addr, v)
+		}
+		return &com
+	}
+	gateCPU := NewCPU()
+	fastCPU := NewFastCPU()
+	gate := newComputer(&gateCPU)
+	fast := newComputer(&fastCPU)
+
+	for i := 0; i < cycles; i++ {
+		reset := logic.O
+		if i == cycles/2 {
+			reset = logic.I
+		}
+		gate.FetchAndExecute(reset)
+		fast.FetchAndExecute(reset)
+		require.Equal(t, gate.cpu.A(), fast.cpu.A(), "A at cycle %d", i)
+		require.Equal(t, gate.cpu.D(), fast.cpu.D(), "D at cycle %d", i)
+		require.Equal(t, gate.cpu.PC(), fast.cpu.PC(), "PC at cycle %d", i)
+		require.Equal(t, gate.pc, fast.pc, "pc at cycle %d", i)
+		require.Equal(t, gate.ROMMap(), fast.ROMMap(), "RAM at cycle %d", i)
+	}
+}
+
+func TestFastCPUDifferential(t *testing.T) {
+	t.Run("max", func(t *testing.T) {
+		runDifferential(t, maxInstructions, map[int]int{0: 6, 1: 10}, 40)
+	})
+
+	for seed := int64(0); seed < 20; seed++ {
+		r := rand.New(rand.NewSource(seed))
+		t.Run(fmt.Sprintf("random %d", seed), func(t *testing.T) {
+			inst := make([]Word, 64)
+			for i := range inst {
+				if r.Intn(3) == 0 {
+					inst[i] = uint162Word(uint16(r.Intn(len(inst))))
+				} else {
+					inst[i] = uint162Word(0xE000 | uint16(r.Intn(0x2000)))
+				}
+			}
+			ram := map[int]int{}
+			for i := 0; i < len(inst); i++ {
+				ram[i] = r.Intn(65536) - 32768
+			}
+			runDifferential(t, inst, ram, 200)
+		})
+	}
+}
+
+func TestFastCPUALU(t *testing.T) {
+	var tests = []struct {
+		comp     uint16
+		expected int16
+	}{
+		{0x2A, 0},
+		{0x3F, 1},
+		{0x3A, -1},
+		{0x0C, 7},
+		{0x30, -3},
+		{0x0D, ^int16(7)},
+		{0x0F, -7},
+		{0x1F, 8},
+		{0x37, -2},
+		{0x0E, 6},
+		{0x32, -4},
+		{0x02, 4},
+		{0x13, 10},
+		{0x07, -10},
+		{0x00, 5},
+		{0x15, -1},
+	}
+	for _, tt := range tests {
+		require.Equal(t, tt.expected, alu(7, -3, tt.comp), "comp %06b", tt.comp)
+	}
+}
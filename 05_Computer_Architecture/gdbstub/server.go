@@ -0,0 +1,571 @@
+// Package gdbstub serves a Computer over the GDB remote serial protocol, so
+// that gdb and editors with gdb integration can debug Hack programs.
+//
+// The target has three 16-bit little-endian registers: a, d and pc. GDB
+// addresses bytes, so a Hack word at address N occupies bytes 2N and 2N+1.
+// ROM starts at ROM_BASE and RAM at RAM_BASE, and pc holds the byte address
+// of the next instruction in ROM, i.e. twice the Hack PC.
+package gdbstub
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	computer "github.com/kazufusa/nand2tetris/05_Computer_Architecture"
+)
+
+const (
+	ROM_BASE = 0x000000
+	RAM_BASE = 0x800000
+	ROM_SIZE = 32768
+	RAM_SIZE = computer.KBD_ADDR + 1
+
+	// CONTINUE_CHUNK instructions run between checks for a halted program.
+	CONTINUE_CHUNK = 1000
+	PACKET_SIZE    = 4096
+)
+
+const targetXML = `<?xml version="1.0"?>
+<!DOCTYPE target SYSTEM "gdb-target.dtd">
+<target version="1.0">
+  <feature name="org.nand2tetris.hack">
+    <reg name="a" bitsize="16" type="int16" regnum="0"/>
+    <reg name="d" bitsize="16" type="int16"/>
+    <reg name="pc" bitsize="16" type="code_ptr"/>
+  </feature>
+</target>
+`
+
+var errUnsupported = errors.New("unsupported")
+
+type Server struct {
+	com *computer.Computer
+	dbg *computer.Debugger
+	// Lock, if set, is held whenever the server uses the computer, so that
+	// other goroutines such as a screen renderer can share it.
+	Lock sync.Locker
+
+	w     *bufio.Writer
+	noAck bool
+	last  string
+}
+
+func NewServer(com *computer.Computer) *Server {
+	return &Server{com: com, dbg: computer.NewDebugger(com)}
+}
+
+// ListenAndServe accepts gdb connections on addr, one session at a time.
+func (s *Server) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer l.Close()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		s.Serve(conn)
+		conn.Close()
+	}
+}
+
+type itemKind int
+
+const (
+	ITEM_PACKET itemKind = iota
+	ITEM_BAD_PACKET
+	ITEM_ACK
+	ITEM_NACK
+	ITEM_INTERRUPT
+)
+
+type item struct {
+	kind itemKind
+	data string
+}
+
+// Serve handles one session until gdb detaches, kills the target or
+// disconnects.
+func (s *Server) Serve(rw io.ReadWriter) error {
+	s.w = bufio.NewWriter(rw)
+	s.noAck = false
+	s.last = ""
+	items := make(chan item)
+	var readErr error
+	go func() {
+		readErr = readItems(bufio.NewReader(rw), items)
+		close(items)
+	}()
+
+	for it := range items {
+		switch it.kind {
+		case ITEM_ACK:
+			continue
+		case ITEM_NACK:
+			if err := s.send(s.last); err != nil {
+				return err
+			}
+			continue
+		case ITEM_BAD_PACKET:
+			if err := s.write("-"); err != nil {
+				return err
+			}
+			continue
+		case ITEM_INTERRUPT:
+			if err := s.send("S02"); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if !s.noAck {
+			if err := s.write("+"); err != nil {
+				return err
+			}
+		}
+		pkt := it.data
+		var reply string
+		switch {
+		case pkt == "D":
+			return s.send("OK")
+		case pkt == "k":
+			return nil
+		case pkt == "QStartNoAckMode":
+			if err := s.send("OK"); err != nil {
+				return err
+			}
+			s.noAck = true
+			continue
+		case pkt[0] == 'c' || pkt[0] == 's':
+			var err error
+			if reply, err = s.resume(pkt, items); err == io.EOF {
+				return nil
+			} else if err != nil {
+				return err
+			}
+		default:
+			s.lock()
+			r, err := s.handle(pkt)
+			s.unlock()
+			switch {
+			case err == errUnsupported:
+				reply = ""
+			case err != nil:
+				reply = "E01"
+			default:
+				reply = r
+			}
+		}
+		if err := s.send(reply); err != nil {
+			return err
+		}
+	}
+	if readErr == io.EOF {
+		return nil
+	}
+	return readErr
+}
+
+func (s *Server) lock() {
+	if s.Lock != nil {
+		s.Lock.Lock()
+	}
+}
+
+func (s *Server) unlock() {
+	if s.Lock != nil {
+		s.Lock.Unlock()
+	}
+}
+
+// readItems splits the byte stream from gdb into packets, acknowledgements
+// and interrupts.
+func readItems(r *bufio.Reader, items chan<- item) error {
+	for {
+		c, err := r.ReadByte()
+		if err != nil {
+			return err
+		}
+		switch c {
+		case '+':
+			items <- item{kind: ITEM_ACK}
+		case '-':
+			items <- item{kind: ITEM_NACK}
+		case 0x03:
+			items <- item{kind: ITEM_INTERRUPT}
+		case '$':
+			data, err := r.ReadString('#')
+			if err != nil {
+				return err
+			}
+			data = data[:len(data)-1]
+			var sum [2]byte
+			if _, err := io.ReadFull(r, sum[:]); err != nil {
+				return err
+			}
+			want, err := strconv.ParseUint(string(sum[:]), 16, 8)
+			if err != nil || byte(want) != checksum(data) {
+				items <- item{kind: ITEM_BAD_PACKET}
+				continue
+			}
+			if data == "" {
+				items <- item{kind: ITEM_BAD_PACKET}
+				continue
+			}
+			items <- item{kind: ITEM_PACKET, data: unescape(data)}
+		}
+	}
+}
+
+func checksum(data string) byte {
+	var sum byte
+	for i := 0; i < len(data); i++ {
+		sum += data[i]
+	}
+	return sum
+}
+
+func escape(data string) string {
+	var b strings.Builder
+	for i := 0; i < len(data); i++ {
+		switch c := data[i]; c {
+		case '#', '$', '}', '*':
+			b.WriteByte('}')
+			b.WriteByte(c ^ 0x20)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+func unescape(data string) string {
+	if !strings.Contains(data, "}") {
+		return data
+	}
+	var b strings.Builder
+	for i := 0; i < len(data); i++ {
+		if data[i] == '}' && i+1 < len(data) {
+			i++
+			b.WriteByte(data[i] ^ 0x20)
+			continue
+		}
+		b.WriteByte(data[i])
+	}
+	return b.String()
+}
+
+func (s *Server) write(raw string) error {
+	if _, err := s.w.WriteString(raw); err != nil {
+		return err
+	}
+	return s.w.Flush()
+}
+
+func (s *Server) send(reply string) error {
+	s.last = reply
+	data := escape(reply)
+	return s.write(fmt.Sprintf("$%s#%02x", data, checksum(data)))
+}
+
+// resume handles c and s. While the program runs, any input from gdb, which
+// is an interrupt in practice, pauses it.
+func (s *Server) resume(pkt string, items <-chan item) (string, error) {
+	if len(pkt) > 1 {
+		addr, err := strconv.ParseUint(pkt[1:], 16, 32)
+		if err != nil {
+			return "E01", nil
+		}
+		s.lock()
+		s.com.SetPC(int(addr-ROM_BASE) / 2)
+		s.unlock()
+	}
+	if pkt[0] == 's' {
+		s.lock()
+		reason := s.dbg.Step()
+		s.unlock()
+		return s.stopReply(reason), nil
+	}
+
+	// open receives whether the connection is still open when gdb sends
+	// anything while the program runs.
+	open := make(chan bool, 1)
+	stop := make(chan struct{})
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		select {
+		case _, ok := <-items:
+			open <- ok
+			s.dbg.Pause()
+		case <-stop:
+		}
+	}()
+	// The watcher must be gone before the reply, or it could take the next
+	// packet.
+	defer func() {
+		close(stop)
+		<-exited
+	}()
+
+	for {
+		select {
+		case ok := <-open:
+			if !ok {
+				return "", io.EOF
+			}
+			return "S02", nil
+		default:
+		}
+		s.lock()
+		reason := s.dbg.Continue(CONTINUE_CHUNK)
+		halted := s.com.IsHalted()
+		s.unlock()
+		switch {
+		case reason == computer.STOP_PAUSED:
+			continue
+		case reason != computer.STOP_LIMIT:
+			return s.stopReply(reason), nil
+		case halted:
+			return "S05", nil
+		}
+	}
+}
+
+func (s *Server) stopReply(reason computer.StopReason) string {
+	if reason == computer.STOP_WATCHPOINT {
+		if hit := s.dbg.LastHit(); hit != nil {
+			return fmt.Sprintf("T05watch:%x;", RAM_BASE+2*hit.Addr)
+		}
+	}
+	return "S05"
+}
+
+func (s *Server) handle(pkt string) (string, error) {
+	switch {
+	case pkt == "?":
+		return "S05", nil
+	case pkt == "g":
+		var b strings.Builder
+		for _, v := range s.registers() {
+			b.WriteString(encodeWord(v))
+		}
+		return b.String(), nil
+	case pkt[0] == 'G':
+		data := pkt[1:]
+		if len(data) != 12 {
+			return "", errors.New("G needs 3 registers")
+		}
+		for i := 0; i < 3; i++ {
+			v, err := decodeWord(data[4*i : 4*i+4])
+			if err != nil {
+				return "", err
+			}
+			s.setRegister(i, v)
+		}
+		return "OK", nil
+	case pkt[0] == 'p':
+		n, err := strconv.ParseUint(pkt[1:], 16, 8)
+		if err != nil || n > 2 {
+			return "", errors.New("invalid register")
+		}
+		return encodeWord(s.registers()[n]), nil
+	case pkt[0] == 'P':
+		parts := strings.SplitN(pkt[1:], "=", 2)
+		if len(parts) != 2 {
+			return "", errors.New("invalid P packet")
+		}
+		n, err := strconv.ParseUint(parts[0], 16, 8)
+		if err != nil || n > 2 {
+			return "", errors.New("invalid register")
+		}
+		v, err := decodeWord(parts[1])
+		if err != nil {
+			return "", err
+		}
+		s.setRegister(int(n), v)
+		return "OK", nil
+	case pkt[0] == 'm':
+		addr, n, err := parseAddrLen(pkt[1:])
+		if err != nil {
+			return "", err
+		}
+		b, err := s.readMemory(addr, n)
+		if err != nil {
+			return "", err
+		}
+		return hex.EncodeToString(b), nil
+	case pkt[0] == 'M':
+		parts := strings.SplitN(pkt[1:], ":", 2)
+		if len(parts) != 2 {
+			return "", errors.New("invalid M packet")
+		}
+		addr, n, err := parseAddrLen(parts[0])
+		if err != nil {
+			return "", err
+		}
+		b, err := hex.DecodeString(parts[1])
+		if err != nil || len(b) != n {
+			return "", errors.New("invalid M data")
+		}
+		return "OK", s.writeMemory(addr, b)
+	case pkt[0] == 'Z' || pkt[0] == 'z':
+		return s.breakpoint(pkt)
+	case strings.HasPrefix(pkt, "qSupported"):
+		return fmt.Sprintf("PacketSize=%x;qXfer:features:read+;QStartNoAckMode+", PACKET_SIZE), nil
+	case strings.HasPrefix(pkt, "qXfer:features:read:target.xml:"):
+		off, n, err := parseAddrLen(strings.TrimPrefix(pkt, "qXfer:features:read:target.xml:"))
+		if err != nil {
+			return "", err
+		}
+		if off >= len(targetXML) {
+			return "l", nil
+		}
+		end := off + n
+		if end >= len(targetXML) {
+			return "l" + targetXML[off:], nil
+		}
+		return "m" + targetXML[off:end], nil
+	case pkt == "qAttached":
+		return "1", nil
+	case pkt == "qC":
+		return "QC1", nil
+	case pkt == "qfThreadInfo":
+		return "m1", nil
+	case pkt == "qsThreadInfo":
+		return "l", nil
+	case pkt[0] == 'H' || pkt[0] == 'T':
+		return "OK", nil
+	}
+	return "", errUnsupported
+}
+
+// registers returns a, d and pc in the target's layout.
+func (s *Server) registers() [3]uint16 {
+	return [3]uint16{uint16(s.com.A()), uint16(s.com.D()), uint16(2 * s.com.PC())}
+}
+
+func (s *Server) setRegister(n int, v uint16) {
+	switch n {
+	case 0:
+		s.com.SetA(int(int16(v)))
+	case 1:
+		s.com.SetD(int(int16(v)))
+	case 2:
+		s.com.SetPC(int(v / 2))
+	}
+}
+
+func encodeWord(v uint16) string {
+	return fmt.Sprintf("%02x%02x", v&0xff, v>>8)
+}
+
+func decodeWord(s string) (uint16, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) != 2 {
+		return 0, errors.New("invalid register value")
+	}
+	return uint16(b[0]) | uint16(b[1])<<8, nil
+}
+
+func parseAddrLen(s string) (addr, n int, err error) {
+	parts := strings.SplitN(s, ",", 2)
+	if len(parts) != 2 {
+		return 0, 0, errors.New("expected ADDR,LENGTH")
+	}
+	a, err := strconv.ParseUint(parts[0], 16, 32)
+	if err != nil {
+		return 0, 0, err
+	}
+	l, err := strconv.ParseUint(parts[1], 16, 32)
+	if err != nil {
+		return 0, 0, err
+	}
+	return int(a), int(l), nil
+}
+
+// word returns the word containing the byte at addr and how to store it.
+func (s *Server) word(addr int) (get func() int, set func(int), err error) {
+	switch {
+	case addr >= RAM_BASE && addr < RAM_BASE+2*RAM_SIZE:
+		i := (addr - RAM_BASE) / 2
+		return func() int { return s.com.RAM(i) }, func(v int) { s.com.SetRAM(i, v) }, nil
+	case addr >= ROM_BASE && addr < ROM_BASE+2*ROM_SIZE:
+		i := (addr - ROM_BASE) / 2
+		return func() int { return s.com.ROM(i) }, func(v int) { s.com.SetROM(i, v) }, nil
+	}
+	return nil, nil, fmt.Errorf("address %x is outside ROM and RAM", addr)
+}
+
+func (s *Server) readMemory(addr, n int) ([]byte, error) {
+	b := make([]byte, 0, n)
+	for a := addr; a < addr+n; a++ {
+		get, _, err := s.word(a)
+		if err != nil {
+			return nil, err
+		}
+		b = append(b, byte(uint16(get())>>(8*uint(a%2))))
+	}
+	return b, nil
+}
+
+func (s *Server) writeMemory(addr int, b []byte) error {
+	for i, c := range b {
+		a := addr + i
+		get, set, err := s.word(a)
+		if err != nil {
+			return err
+		}
+		v := uint16(get())
+		shift := 8 * uint(a%2)
+		v = v&^(0xff<<shift) | uint16(c)<<shift
+		set(int(int16(v)))
+	}
+	return nil
+}
+
+// breakpoint handles Z and z packets: software and hardware breakpoints on
+// ROM and write watchpoints on RAM.
+func (s *Server) breakpoint(pkt string) (string, error) {
+	insert := pkt[0] == 'Z'
+	parts := strings.Split(pkt[1:], ",")
+	if len(parts) < 3 {
+		return "", errors.New("invalid breakpoint packet")
+	}
+	addr, n, err := parseAddrLen(parts[1] + "," + parts[2])
+	if err != nil {
+		return "", err
+	}
+	switch parts[0] {
+	case "0", "1":
+		if addr < ROM_BASE || addr >= ROM_BASE+2*ROM_SIZE || addr%2 != 0 {
+			return "", fmt.Errorf("breakpoint %x is not an instruction", addr)
+		}
+		loc := strconv.Itoa((addr - ROM_BASE) / 2)
+		if insert {
+			return "OK", s.dbg.Break(loc)
+		}
+		return "OK", s.dbg.ClearBreak(loc)
+	case "2":
+		if addr < RAM_BASE || addr+n > RAM_BASE+2*RAM_SIZE || n < 1 {
+			return "", fmt.Errorf("watchpoint %x is outside RAM", addr)
+		}
+		w := computer.Watch{From: (addr - RAM_BASE) / 2, To: (addr - RAM_BASE + n - 1) / 2}
+		if insert {
+			s.dbg.Watch(w.From, w.To)
+		} else {
+			s.dbg.ClearWatch(w)
+		}
+		return "OK", nil
+	}
+	return "", errUnsupported
+}
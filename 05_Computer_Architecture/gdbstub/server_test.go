@@ -0,0 +1,155 @@
+package gdbstub
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	computer "github.com/kazufusa/nand2tetris/05_Computer_Architecture"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// max stores max(RAM[0], RAM[1]) in RAM[2] and ends in "@14 0;JMP".
+const max = `0000000000000000
+1111110000010000
+0000000000000001
+1111010011010000
+0000000000001010
+1110001100000001
+0000000000000001
+1111110000010000
+0000000000001100
+1110101010000111
+0000000000000000
+1111110000010000
+0000000000000010
+1110001100001000
+0000000000001110
+1110101010000111`
+
+type client struct {
+	t    *testing.T
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func newClient(t *testing.T) (*client, *computer.Computer, chan error) {
+	com := computer.NewEmulator(max)
+	com.SetRAM(0, 6)
+	com.SetRAM(1, 10)
+	srv, conn := net.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		done <- NewServer(com).Serve(srv)
+		srv.Close()
+	}()
+	return &client{t: t, conn: conn, r: bufio.NewReader(conn)}, com, done
+}
+
+func (c *client) send(pkt string) {
+	_, err := fmt.Fprintf(c.conn, "$%s#%02x", pkt, checksum(pkt))
+	require.NoError(c.t, err)
+}
+
+func (c *client) reply() string {
+	for {
+		b, err := c.r.ReadByte()
+		require.NoError(c.t, err)
+		if b == '+' {
+			continue
+		}
+		require.Equal(c.t, byte('$'), b)
+		data, err := c.r.ReadString('#')
+		require.NoError(c.t, err)
+		data = data[:len(data)-1]
+		sum := make([]byte, 2)
+		_, err = c.r.Read(sum)
+		require.NoError(c.t, err)
+		require.Equal(c.t, fmt.Sprintf("%02x", checksum(data)), string(sum))
+		// The server may already be gone after replying to D.
+		c.conn.Write([]byte("+"))
+		return unescape(data)
+	}
+}
+
+func (c *client) call(pkt string) string {
+	c.send(pkt)
+	return c.reply()
+}
+
+func TestServer(t *testing.T) {
+	c, com, done := newClient(t)
+	defer c.conn.Close()
+
+	assert.Contains(t, c.call("qSupported:swbreak+"), "qXfer:features:read+")
+	assert.Equal(t, "S05", c.call("?"))
+	assert.Equal(t, "000000000000", c.call("g"))
+
+	xml := c.call("qXfer:features:read:target.xml:0,20")
+	assert.Equal(t, "m<?xml version=\"1.0\"?>", xml[:22])
+	assert.Equal(t, "l", c.call("qXfer:features:read:target.xml:1000,20"))
+
+	// RAM[0..1] = 6, 10; ROM[1] = D=M (0xfc10).
+	assert.Equal(t, "06000a00", c.call("m800000,4"))
+	assert.Equal(t, "10fc", c.call("m2,2"))
+	assert.Equal(t, "E01", c.call("m400000,2"))
+
+	assert.Equal(t, "S05", c.call("s"))
+	assert.Equal(t, "S05", c.call("s"))
+	assert.Equal(t, "000006000400", c.call("g"), "a=0 d=6 pc=byte 4")
+	assert.Equal(t, "0600", c.call("p1"))
+
+	// A breakpoint on ROM[12] (byte 24) is hit after the jump at 9.
+	assert.Equal(t, "OK", c.call("Z0,18,2"))
+	assert.Equal(t, "S05", c.call("c"))
+	assert.Equal(t, 12, com.PC())
+	assert.Equal(t, "OK", c.call("z0,18,2"))
+
+	// A write watchpoint on RAM[2].
+	assert.Equal(t, "OK", c.call("Z2,800004,2"))
+	assert.Equal(t, "T05watch:800004;", c.call("c"))
+	assert.Equal(t, "0a00", c.call("m800004,2"))
+	assert.Equal(t, "OK", c.call("z2,800004,2"))
+
+	// Without breakpoints the program runs into its end loop.
+	assert.Equal(t, "S05", c.call("c"))
+	assert.True(t, com.IsHalted())
+
+	assert.Equal(t, "OK", c.call("M800006,2:3412"))
+	assert.Equal(t, 0x1234, com.RAM(3))
+	assert.Equal(t, "OK", c.call("P0=ffff"))
+	assert.Equal(t, -1, com.A())
+	assert.Equal(t, "OK", c.call("P2=0000"))
+	assert.Equal(t, 0, com.PC())
+	assert.Equal(t, "", c.call("vCont?"))
+
+	assert.Equal(t, "OK", c.call("D"))
+	require.NoError(t, <-done)
+}
+
+func TestServerInterrupt(t *testing.T) {
+	c, com, done := newClient(t)
+	defer c.conn.Close()
+	assert.Equal(t, "OK", c.call("QStartNoAckMode"))
+	// Spin forever at 14..15 without halt detection by jumping to 14 via 15.
+	com.SetROM(14, 0x000f)
+	com.SetROM(15, -5497) // 0;JMP
+	com.SetPC(14)
+	c.send("c")
+	time.Sleep(50 * time.Millisecond)
+	_, err := c.conn.Write([]byte{0x03})
+	require.NoError(t, err)
+	assert.Equal(t, "S02", c.reply())
+
+	_, err = c.conn.Write([]byte("$g#00"))
+	require.NoError(t, err)
+	b, err := c.r.ReadByte()
+	require.NoError(t, err)
+	assert.Equal(t, byte('-'), b, "bad checksum")
+
+	c.send("k")
+	require.NoError(t, <-done)
+}
@@ -0,0 +1,121 @@
+package computer
+
+import (
+	"fmt"
+)
+
+// NO_WRITE marks a delta of an instruction which wrote no RAM.
+const NO_WRITE = -1
+
+// delta is what an instruction changed: the registers before it and the
+// previous value of the RAM word it wrote.
+type delta struct {
+	pc, a, d uint16
+	addr     int16
+	old      uint16
+}
+
+// History is a ring buffer of the changes made by the last instructions of
+// a Computer, so that execution can be stepped backwards. Only the
+// registers and the RAM below KBD_ADDR are restored; writes to devices and
+// changes made through SetRAM, SetA, SetD and SetPC are not recorded, and
+// running forward again discards the undone instructions.
+type History struct {
+	com    *Computer
+	deltas []delta
+	head   int
+	n      int
+	cycle  uint64
+}
+
+// NewHistory returns a History remembering up to size instructions.
+func NewHistory(size int) *History {
+	return &History{deltas: make([]delta, size)}
+}
+
+// Attach makes h record every instruction com executes.
+func (h *History) Attach(com *Computer) {
+	h.com = com
+	com.history = h
+}
+
+// Cycle returns the number of instructions executed since Attach, less the
+// ones stepped back.
+func (h *History) Cycle() uint64 {
+	return h.cycle
+}
+
+// Oldest returns the earliest cycle h can rewind to.
+func (h *History) Oldest() uint64 {
+	return h.cycle - uint64(h.n)
+}
+
+func (h *History) push(d delta) {
+	if len(h.deltas) == 0 {
+		h.cycle++
+		return
+	}
+	h.deltas[h.head] = d
+	h.head = (h.head + 1) % len(h.deltas)
+	if h.n < len(h.deltas) {
+		h.n++
+	}
+	h.cycle++
+}
+
+func (h *History) last() *delta {
+	return &h.deltas[(h.head+len(h.deltas)-1)%len(h.deltas)]
+}
+
+// StepBack undoes the last instruction. It returns false when the history
+// is exhausted.
+func (h *History) StepBack() bool {
+	if h.n == 0 {
+		return false
+	}
+	d := h.last()
+	if d.addr != NO_WRITE {
+		h.com.SetRAM(int(d.addr), int(int16(d.old)))
+	}
+	h.com.SetD(int(int16(d.d)))
+	h.com.SetA(int(int16(d.a)))
+	h.com.SetPC(int(d.pc))
+	h.head = (h.head + len(h.deltas) - 1) % len(h.deltas)
+	h.n--
+	h.cycle--
+	return true
+}
+
+// Rewind steps back to the state before instruction cycle was executed.
+func (h *History) Rewind(cycle uint64) error {
+	if cycle < h.Oldest() || cycle > h.cycle {
+		return fmt.Errorf("cycle %d is outside the history %d-%d", cycle, h.Oldest(), h.cycle)
+	}
+	for h.cycle > cycle {
+		h.StepBack()
+	}
+	return nil
+}
+
+// LastWrite returns the cycle of the latest instruction in the history
+// which wrote RAM[addr].
+func (h *History) LastWrite(addr int) (uint64, bool) {
+	for i := 0; i < h.n; i++ {
+		if int(h.deltas[(h.head+len(h.deltas)-1-i)%len(h.deltas)].addr) == addr {
+			return h.cycle - 1 - uint64(i), true
+		}
+	}
+	return 0, false
+}
+
+// RunBackToWrite rewinds to just before the latest instruction which wrote
+// RAM[addr], so PC is that instruction and stepping once redoes the write.
+// It returns false and changes nothing when the history has no such write.
+func (h *History) RunBackToWrite(addr int) bool {
+	cycle, ok := h.LastWrite(addr)
+	if !ok {
+		return false
+	}
+	h.Rewind(cycle)
+	return true
+}
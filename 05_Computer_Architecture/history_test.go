@@ -0,0 +1,125 @@
+package computer
+
+import (
+	"testing"
+
+	memory "github.com/kazufusa/nand2tetris/03_Memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// counterProgram increments RAM[16] and copies it to RAM[17] forever.
+var counterProgram = []string{
+	"0000000000010000", // (LOOP) @16
+	"1111110111011000", // MD=M+1
+	"0000000000010001", // @17
+	"1110001100001000", // M=D
+	"0000000000000000", // @LOOP
+	"1110101010000111", // 0;JMP
+}
+
+type machineState struct {
+	A, D, PC, R16, R17 int
+}
+
+func stateOf(com *Computer) machineState {
+	return machineState{com.A(), com.D(), com.PC(), com.RAM(16), com.RAM(17)}
+}
+
+func newCounterComputer(gate bool) *Computer {
+	var inst []Word
+	for _, s := range counterProgram {
+		inst = append(inst, string2Word(s))
+	}
+	if !gate {
+		com, _, _ := newFastComputer(inst)
+		return com
+	}
+	clock := memory.Clock(0)
+	sc := NewTestScreen(&clock)
+	ram := NewMemory(&clock, &sc, &TestKeyboard{})
+	rom := NewROM32K()
+	rom.BulkLoad(inst)
+	cpu := NewCPU()
+	com := NewComputer(&cpu, &ram, &rom, &clock)
+	return &com
+}
+
+func TestHistory(t *testing.T) {
+	for name, gate := range map[string]bool{"fast": false, "gate": true} {
+		com := newCounterComputer(gate)
+		t.Run(name, func(t *testing.T) {
+			h := NewHistory(100)
+			h.Attach(com)
+			states := []machineState{stateOf(com)}
+			for i := 0; i < 30; i++ {
+				com.FetchAndExecute(0)
+				states = append(states, stateOf(com))
+			}
+			assert.Equal(t, uint64(30), h.Cycle())
+			assert.Equal(t, uint64(0), h.Oldest())
+
+			require.NoError(t, h.Rewind(12))
+			assert.Equal(t, states[12], stateOf(com))
+			require.True(t, h.StepBack())
+			assert.Equal(t, uint64(11), h.Cycle())
+			assert.Equal(t, states[11], stateOf(com))
+
+			for i := 0; i < 19; i++ {
+				com.FetchAndExecute(0)
+			}
+			assert.Equal(t, states[30], stateOf(com), "the program runs forward the same way")
+
+			cycle, ok := h.LastWrite(17)
+			require.True(t, ok)
+			assert.Equal(t, uint64(27), cycle)
+			require.True(t, h.RunBackToWrite(17))
+			assert.Equal(t, states[27], stateOf(com))
+			assert.Equal(t, 3, com.PC(), "stopped at the writing instruction")
+			require.True(t, h.RunBackToWrite(16))
+			assert.Equal(t, states[25], stateOf(com))
+
+			_, ok = h.LastWrite(18)
+			assert.False(t, ok)
+			assert.False(t, h.RunBackToWrite(18))
+			assert.Equal(t, states[25], stateOf(com))
+
+			require.NoError(t, h.Rewind(0))
+			assert.Equal(t, states[0], stateOf(com))
+			assert.False(t, h.StepBack())
+		})
+	}
+}
+
+func TestHistoryBounded(t *testing.T) {
+	com := newCounterComputer(false)
+	h := NewHistory(5)
+	h.Attach(com)
+	for i := 0; i < 30; i++ {
+		com.FetchAndExecute(0)
+	}
+	assert.Equal(t, uint64(25), h.Oldest())
+	assert.Error(t, h.Rewind(24))
+	assert.Error(t, h.Rewind(31))
+	for i := 0; i < 5; i++ {
+		require.True(t, h.StepBack())
+	}
+	assert.False(t, h.StepBack())
+	assert.Equal(t, uint64(25), h.Cycle())
+	// 25 instructions ran four loops and the first instruction of the fifth.
+	assert.Equal(t, machineState{A: 16, D: 4, PC: 1, R16: 4, R17: 4}, stateOf(com))
+}
+
+func TestHistoryIgnoresDevices(t *testing.T) {
+	com, timer := newTimerComputer(t)
+	h := NewHistory(10)
+	h.Attach(com)
+	for i := 0; i < 15; i++ {
+		com.FetchAndExecute(0)
+	}
+	_, ok := h.LastWrite(TIMER_ADDR + TIMER_RELOAD)
+	assert.False(t, ok)
+	require.NoError(t, h.Rewind(7))
+	assert.Equal(t, 12, com.PC(), "instruction 7 stores the count")
+	assert.Equal(t, 20, timer.read(TIMER_RELOAD), "device registers keep their state")
+}
@@ -0,0 +1,156 @@
+package computer
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// KeyEvent sets the keyboard register to Code from instruction Cycle on.
+// Code 0 releases the key.
+type KeyEvent struct {
+	Cycle int
+	Code  int
+}
+
+// ReadKeyTimeline reads "CYCLE CODE" lines. Empty lines and lines starting
+// with '#' are ignored. The events are returned sorted by cycle.
+func ReadKeyTimeline(r io.Reader) ([]KeyEvent, error) {
+	var events []KeyEvent
+	sc := bufio.NewScanner(r)
+	for ln := 1; sc.Scan(); ln++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("keys:%d: expected \"CYCLE CODE\"", ln)
+		}
+		cycle, err := strconv.Atoi(fields[0])
+		if err != nil || cycle < 0 {
+			return nil, fmt.Errorf("keys:%d: invalid cycle %q", ln, fields[0])
+		}
+		code, err := strconv.Atoi(fields[1])
+		if err != nil || code < 0 || code > 0xffff {
+			return nil, fmt.Errorf("keys:%d: invalid key code %q", ln, fields[1])
+		}
+		events = append(events, KeyEvent{Cycle: cycle, Code: code})
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Cycle < events[j].Cycle
+	})
+	return events, nil
+}
+
+func WriteKeyTimeline(w io.Writer, events []KeyEvent) error {
+	bw := bufio.NewWriter(w)
+	for _, e := range events {
+		fmt.Fprintf(bw, "%d %d\n", e.Cycle, e.Code)
+	}
+	return bw.Flush()
+}
+
+// ReplayKeyboard plays back a key timeline. It counts instructions as an
+// observer, so it must be added to the Computer with AddObserver.
+type ReplayKeyboard struct {
+	mu     sync.Mutex
+	events []KeyEvent
+	next   int
+	cycle  int
+	word   Word
+}
+
+var (
+	_ IKeyboard = (*ReplayKeyboard)(nil)
+	_ IObserver = (*ReplayKeyboard)(nil)
+	_ IKeyboard = (*RecordingKeyboard)(nil)
+	_ IObserver = (*RecordingKeyboard)(nil)
+)
+
+func NewReplayKeyboard(events []KeyEvent) *ReplayKeyboard {
+	kb := &ReplayKeyboard{events: events}
+	kb.advance()
+	return kb
+}
+
+func (kb *ReplayKeyboard) Fetch() Word {
+	kb.mu.Lock()
+	defer kb.mu.Unlock()
+	return kb.word
+}
+
+func (kb *ReplayKeyboard) Observe(e *Event) {
+	kb.mu.Lock()
+	defer kb.mu.Unlock()
+	kb.cycle++
+	kb.advance()
+}
+
+func (kb *ReplayKeyboard) advance() {
+	for kb.next < len(kb.events) && kb.events[kb.next].Cycle <= kb.cycle {
+		kb.word = int2Word(kb.events[kb.next].Code)
+		kb.next++
+	}
+}
+
+// Done reports whether every event has been played.
+func (kb *ReplayKeyboard) Done() bool {
+	kb.mu.Lock()
+	defer kb.mu.Unlock()
+	return kb.next == len(kb.events)
+}
+
+// RecordingKeyboard wraps a keyboard and records every change of the value
+// seen by the program, stamped with the instruction count. Replaying the
+// events with a ReplayKeyboard feeds the program the same values at the same
+// cycles. It must be added to the Computer with AddObserver.
+type RecordingKeyboard struct {
+	mu     sync.Mutex
+	kb     IKeyboard
+	cycle  int
+	last   int
+	events []KeyEvent
+}
+
+func NewRecordingKeyboard(kb IKeyboard) *RecordingKeyboard {
+	return &RecordingKeyboard{kb: kb}
+}
+
+func (r *RecordingKeyboard) Fetch() Word {
+	w := r.kb.Fetch()
+	code := int(uint16(word2Int(w)))
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if code != r.last {
+		r.last = code
+		r.events = append(r.events, KeyEvent{Cycle: r.cycle, Code: code})
+	}
+	return w
+}
+
+func (r *RecordingKeyboard) Observe(e *Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cycle++
+}
+
+// SetWord forwards to the wrapped keyboard so that snapshots can be restored.
+func (r *RecordingKeyboard) SetWord(w Word) {
+	if x, ok := r.kb.(keyboardSetter); ok {
+		x.SetWord(w)
+	}
+}
+
+func (r *RecordingKeyboard) Events() []KeyEvent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]KeyEvent(nil), r.events...)
+}
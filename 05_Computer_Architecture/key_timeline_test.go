@@ -0,0 +1,100 @@
+package computer
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	memory "github.com/kazufusa/nand2tetris/03_Memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// echoKeys copies the keyboard to RAM[0] forever:
+// @24576, D=M, @0, M=D, @0, 0;JMP
+var echoKeys = []string{
+	"0110000000000000",
+	"1111110000010000",
+	"0000000000000000",
+	"1110001100001000",
+	"0000000000000000",
+	"1110101010000111",
+}
+
+type ramWrites []int
+
+func (w *ramWrites) Observe(e *Event) {
+	if e.WriteM {
+		*w = append(*w, e.OutM)
+	}
+}
+
+func newKeyboardComputer(kb IKeyboard) *Computer {
+	var inst []Word
+	for _, s := range echoKeys {
+		inst = append(inst, string2Word(s))
+	}
+	clock := memory.Clock(0)
+	ram := NewFastMemory(&TuiScreen{}, kb)
+	rom := VROM32K{}
+	rom.BulkLoad(inst)
+	cpu := NewFastCPU()
+	com := NewComputer(&cpu, &ram, &rom, &clock)
+	return &com
+}
+
+func TestKeyboardRecordAndReplay(t *testing.T) {
+	live := &TuiKeyboard{}
+	rec := NewRecordingKeyboard(live)
+	com := newKeyboardComputer(rec)
+	com.AddObserver(rec)
+	var recorded ramWrites
+	com.AddObserver(&recorded)
+	for _, code := range []int{0, 65, 131, 0} {
+		live.SetWord(int2Word(code))
+		com.Run(13)
+	}
+	events := rec.Events()
+	require.Len(t, events, 3)
+	assert.Equal(t, 65, events[0].Code)
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteKeyTimeline(&buf, events))
+	loaded, err := ReadKeyTimeline(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, events, loaded)
+
+	replay := NewReplayKeyboard(loaded)
+	com = newKeyboardComputer(replay)
+	com.AddObserver(replay)
+	var replayed ramWrites
+	com.AddObserver(&replayed)
+	com.Run(4 * 13)
+	assert.True(t, replay.Done())
+	assert.Equal(t, recorded, replayed)
+	assert.Contains(t, []int(replayed), 131)
+}
+
+func TestReadKeyTimeline(t *testing.T) {
+	events, err := ReadKeyTimeline(strings.NewReader("# press A\n10 65\n\n5 131\n"))
+	require.NoError(t, err)
+	assert.Equal(t, []KeyEvent{{5, 131}, {10, 65}}, events)
+
+	_, err = ReadKeyTimeline(strings.NewReader("10\n"))
+	assert.EqualError(t, err, `keys:1: expected "CYCLE CODE"`)
+	_, err = ReadKeyTimeline(strings.NewReader("x 1\n"))
+	assert.EqualError(t, err, `keys:1: invalid cycle "x"`)
+	_, err = ReadKeyTimeline(strings.NewReader("1 70000\n"))
+	assert.EqualError(t, err, `keys:1: invalid key code "70000"`)
+}
+
+func TestReplayKeyboard(t *testing.T) {
+	kb := NewReplayKeyboard([]KeyEvent{{0, 1}, {2, 2}, {2, 3}, {3, 0}})
+	var codes []int
+	for i := 0; i < 4; i++ {
+		codes = append(codes, word2Int(kb.Fetch()))
+		kb.Observe(&Event{})
+	}
+	assert.Equal(t, []int{1, 1, 3, 0}, codes)
+	assert.True(t, kb.Done())
+}
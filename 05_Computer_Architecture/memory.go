@@ -5,51 +5,66 @@ import (
 	memory "github.com/kazufusa/nand2tetris/03_Memory"
 )
 
+// Memory is the Hack memory map on a Bus with gate-level RAM.
+//
+// 16K RAM:   0-16383
+// 8K Screen: 16384-24575
+// Keyboard:  24576, mirrored up to 32767
 type Memory struct {
-	ram      memory.RAM16384
-	rams     [4]memory.RAM4096
-	screen   IScreen
-	keyboard IKeyboard
+	Bus
+	ram    gateRAM
+	screen ScreenDevice
 }
 
 func NewMemory(clock *memory.Clock, screen IScreen, keyboard IKeyboard) Memory {
-	return Memory{
-		ram:      memory.NewRAM16384(clock),
-		screen:   screen,
-		keyboard: keyboard,
-	}
+	ram := memory.NewRAM16384(clock)
+	m := Memory{ram: gateRAM{&ram}, screen: ScreenDevice{screen}}
+	m.attachClassic(m.ram, screen, keyboard)
+	return m
 }
 
-// 8K RAM:        0-16383
-// 8K Screen:     16384-24575
-// 1Bit Keyboard: 24576
-// 16383: 0 011 111111111111
-// 16384: 0 100 000000000000
-// 24575: 0 101 111111111111
-// 24576: 0 110 000000000000
-func (m *Memory) Fetch(in Word, load Bit, addr [15]Bit) (out Word) {
-	addr8k := [13]logic.Bit{
-		addr[0], addr[1], addr[2], addr[3],
-		addr[4], addr[5], addr[6], addr[7],
-		addr[8], addr[9], addr[10], addr[11],
-		addr[12],
+// Fetch clocks the gate-level RAM and screen on every access, with load
+// masked off unless they answer addr, as the DFFs lose every bit which is
+// not applied each tick.
+func (m *Memory) Fetch(in Word, load Bit, addr [15]Bit) Word {
+	a := addr2int(addr)
+	dev, _ := m.Device(a)
+	if dev != IDevice(m.ram) {
+		m.ram.Fetch(in, logic.O, a%SCREEN_ADDR)
 	}
-	addr16k := [14]logic.Bit{
-		addr[0], addr[1], addr[2], addr[3],
-		addr[4], addr[5], addr[6], addr[7],
-		addr[8], addr[9], addr[10], addr[11],
-		addr[12], addr[13],
+	if dev != IDevice(m.screen) {
+		m.screen.Fetch(in, logic.O, a%(KBD_ADDR-SCREEN_ADDR))
 	}
-	loads := logic.Dmux8Way(load, [3]logic.Bit{addr[12], addr[13], addr[14]})
-	return logic.Mux16(
-		m.ram.Apply(in, logic.And(load, logic.Not(addr[14])), addr16k),
-		logic.Mux16(
-			m.screen.Fetch(in, logic.And(load, logic.Or(loads[4], loads[5])), addr8k),
-			m.keyboard.Fetch(),
-			addr[13],
-		),
-		addr[14],
-	)
+	return m.Bus.Fetch(in, load, addr)
+}
+
+// gateRAM puts a gate-level RAM16K on a Bus.
+type gateRAM struct {
+	ram *memory.RAM16384
+}
+
+func (r gateRAM) Fetch(in Word, load Bit, addr int) Word {
+	var addr16k [14]logic.Bit
+	a := int2Addr(addr)
+	copy(addr16k[:], a[:14])
+	return r.ram.Apply(in, load, addr16k)
+}
+
+// FastMemory implements the same memory map as Memory on a plain word array
+// instead of gate-level RAM.
+type FastMemory struct {
+	Bus
+}
+
+var (
+	_ IMemory = (*Memory)(nil)
+	_ IMemory = (*FastMemory)(nil)
+)
+
+func NewFastMemory(screen IScreen, keyboard IKeyboard) FastMemory {
+	m := FastMemory{}
+	m.attachClassic(make(WordRAM, SCREEN_ADDR), screen, keyboard)
+	return m
 }
 
 type IScreen interface {
@@ -130,3 +130,49 @@ func TestMemory(t *testing.T) {
 	assert.Equal(t, w6, mem.Fetch(w0, logic.O, addr5), "invalid screen.ram4k[1]")
 	assert.Equal(t, wkb, mem.Fetch(w0, logic.O, addr6), "inbalid keyboard")
 }
+
+func TestMemoryKeepsRAMAcrossDevices(t *testing.T) {
+	var inst []Word
+	for _, s := range []string{
+		"0000000000000101", // @5
+		"1110110000010000", // D=A
+		"0000000000000000", // @0
+		"1110001100001000", // M=D
+		"0100000000000000", // @16384
+		"1110111010001000", // M=-1
+		"0000000000000111", // @7
+		"1110110000010000", // D=A
+		"0000000000000000", // @0
+		"1111110000010000", // D=M
+	} {
+		inst = append(inst, string2Word(s))
+	}
+	clock := memory.Clock(0)
+	sc := NewTestScreen(&clock)
+	mem := NewMemory(&clock, &sc, &TestKeyboard{})
+	rom := NewROM32K()
+	rom.BulkLoad(inst)
+	cpu := NewCPU()
+	com := NewComputer(&cpu, &mem, &rom, &clock)
+	for range inst {
+		com.FetchAndExecute(logic.O)
+	}
+	assert.Equal(t, 5, com.D(), "writing the screen keeps RAM[0]")
+	assert.Equal(t, -1, com.RAM(SCREEN_ADDR))
+}
+
+func TestFastMemory(t *testing.T) {
+	sc := TuiScreen{}
+	kb := TestKeyboard{}
+	mem := NewFastMemory(&sc, &kb)
+
+	for _, addr := range [][15]logic.Bit{addr0, addr1, addr2, addr3, addr4, addr5} {
+		assert.Equal(t, w0, mem.Fetch(w7, logic.I, addr))
+		assert.Equal(t, w7, mem.Fetch(w0, logic.O, addr))
+	}
+	assert.Equal(t, w7, sc.words[0])
+	assert.Equal(t, w7, sc.words[4096])
+	assert.Equal(t, wkb, mem.Fetch(w0, logic.O, addr6))
+	assert.Equal(t, wkb, mem.Fetch(w7, logic.I, addr6))
+	assert.Equal(t, wkb, mem.Fetch(w0, logic.O, addr6))
+}
@@ -0,0 +1,298 @@
+package computer
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	assembler "github.com/kazufusa/nand2tetris/06_Assembler"
+)
+
+type ProfileBy int
+
+const (
+	PROFILE_ADDRESS ProfileBy = iota
+	PROFILE_LABEL
+	PROFILE_FUNCTION
+)
+
+func (by ProfileBy) String() string {
+	switch by {
+	case PROFILE_ADDRESS:
+		return "address"
+	case PROFILE_LABEL:
+		return "label"
+	case PROFILE_FUNCTION:
+		return "function"
+	}
+	return "unknown"
+}
+
+// ProfileEntry is one line of a profile report.
+type ProfileEntry struct {
+	Name  string
+	Count uint64
+}
+
+// Profiler counts executions per ROM address. Register it with
+// Computer.AddObserver.
+type Profiler struct {
+	counts  [SNAPSHOT_ROM_SIZE]uint64
+	total   uint64
+	symbols []assembler.Symbol
+}
+
+func NewProfiler() *Profiler {
+	return &Profiler{}
+}
+
+func (p *Profiler) Observe(e *Event) {
+	p.counts[e.PC]++
+	p.total++
+}
+
+// Count returns how many times the instruction at addr was executed.
+func (p *Profiler) Count(addr int) uint64 {
+	return p.counts[addr]
+}
+
+func (p *Profiler) Total() uint64 {
+	return p.total
+}
+
+func (p *Profiler) Reset() {
+	p.counts = [SNAPSHOT_ROM_SIZE]uint64{}
+	p.total = 0
+}
+
+// LoadSymbols reads the ROM labels of the program from a symbol file.
+// Variables are ignored.
+func (p *Profiler) LoadSymbols(r io.Reader) error {
+	syms, err := assembler.ReadSymbols(r)
+	if err != nil {
+		return err
+	}
+	p.SetSymbols(syms)
+	return nil
+}
+
+func (p *Profiler) SetSymbols(syms []assembler.Symbol) {
+	p.symbols = assembler.Labels(syms)
+	sort.SliceStable(p.symbols, func(i, j int) bool {
+		return p.symbols[i].Addr < p.symbols[j].Addr
+	})
+}
+
+// label returns the nearest label at or before addr. Of several labels at the
+// same address the last one of the symbol file wins, as
+// "(Main.main) (Main.main.LOOP)" starts the loop rather than the function.
+func (p *Profiler) label(addr int) (assembler.Symbol, bool) {
+	i := sort.Search(len(p.symbols), func(i int) bool {
+		return p.symbols[i].Addr > addr
+	})
+	if i == 0 {
+		return assembler.Symbol{}, false
+	}
+	return p.symbols[i-1], true
+}
+
+// vmFunction maps a label of the VM translator to its function:
+// "Main.main", "Main.main.LOOP", "Main.main.3" and "Main.main$ret.0" all
+// belong to "Main.main". Other labels are returned unchanged.
+func vmFunction(label string) string {
+	name := label
+	if i := strings.Index(name, "$"); i >= 0 {
+		name = name[:i]
+	}
+	if parts := strings.SplitN(name, ".", 3); len(parts) == 3 {
+		name = parts[0] + "." + parts[1]
+	}
+	if name == "" {
+		return label
+	}
+	return name
+}
+
+func (p *Profiler) name(addr int, by ProfileBy) string {
+	sym, ok := p.label(addr)
+	if !ok {
+		return fmt.Sprintf("ROM[%d]", addr)
+	}
+	switch by {
+	case PROFILE_LABEL:
+		return sym.Name
+	case PROFILE_FUNCTION:
+		return vmFunction(sym.Name)
+	}
+	if sym.Addr == addr {
+		return fmt.Sprintf("ROM[%d] %s", addr, sym.Name)
+	}
+	return fmt.Sprintf("ROM[%d] %s+%d", addr, sym.Name, addr-sym.Addr)
+}
+
+// Entries aggregates the counts by address, label or VM function, the
+// hottest first. Without symbols every granularity reports addresses.
+func (p *Profiler) Entries(by ProfileBy) []ProfileEntry {
+	index := make(map[string]int)
+	var entries []ProfileEntry
+	for addr, n := range p.counts {
+		if n == 0 {
+			continue
+		}
+		name := p.name(addr, by)
+		i, ok := index[name]
+		if !ok {
+			i = len(entries)
+			index[name] = i
+			entries = append(entries, ProfileEntry{Name: name})
+		}
+		entries[i].Count += n
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Count > entries[j].Count
+	})
+	return entries
+}
+
+// WriteReport writes the entries as a table sorted by count.
+func (p *Profiler) WriteReport(w io.Writer, by ProfileBy) error {
+	if _, err := fmt.Fprintf(w, "Total: %d instructions\n%12s %7s %7s  %s\n",
+		p.total, "count", "flat%", "sum%", by); err != nil {
+		return err
+	}
+	var sum uint64
+	for _, e := range p.Entries(by) {
+		sum += e.Count
+		if _, err := fmt.Fprintf(w, "%12d %6.2f%% %6.2f%%  %s\n",
+			e.Count, percent(e.Count, p.total), percent(sum, p.total), e.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func percent(n, total uint64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return 100 * float64(n) / float64(total)
+}
+
+// WritePprof writes a gzipped profile.proto readable by "go tool pprof".
+// Every executed address is a location whose frames are its label and, when
+// different, the VM function of the label, so -top shows hot labels and
+// -cum hot functions. The line number of a frame is the ROM address.
+func (p *Profiler) WritePprof(w io.Writer) error {
+	strs := []string{""}
+	strIndex := map[string]uint64{"": 0}
+	str := func(s string) uint64 {
+		if i, ok := strIndex[s]; ok {
+			return i
+		}
+		strIndex[s] = uint64(len(strs))
+		strs = append(strs, s)
+		return strIndex[s]
+	}
+	funcIndex := make(map[string]uint64)
+	var funcs []string
+	fn := func(name string) uint64 {
+		if id, ok := funcIndex[name]; ok {
+			return id
+		}
+		funcs = append(funcs, name)
+		funcIndex[name] = uint64(len(funcs))
+		return funcIndex[name]
+	}
+
+	var prof protoBuffer
+	valueType := func(typ, unit string) []byte {
+		var b protoBuffer
+		b.uintField(1, str(typ))
+		b.uintField(2, str(unit))
+		return b.Bytes()
+	}
+	prof.bytesField(1, valueType("instructions", "count"))
+
+	for addr, n := range p.counts {
+		if n == 0 {
+			continue
+		}
+		id := uint64(addr) + 1
+
+		var sample protoBuffer
+		sample.packedField(1, []uint64{id})
+		sample.packedField(2, []uint64{n})
+		prof.bytesField(2, sample.Bytes())
+
+		frames := []string{p.name(addr, PROFILE_LABEL)}
+		if f := p.name(addr, PROFILE_FUNCTION); f != frames[0] {
+			frames = append(frames, f)
+		}
+		var loc protoBuffer
+		loc.uintField(1, id)
+		loc.uintField(3, uint64(addr))
+		for _, f := range frames {
+			var line protoBuffer
+			line.uintField(1, fn(f))
+			line.uintField(2, uint64(addr))
+			loc.bytesField(4, line.Bytes())
+		}
+		prof.bytesField(4, loc.Bytes())
+	}
+
+	for i, f := range funcs {
+		var b protoBuffer
+		b.uintField(1, uint64(i)+1)
+		b.uintField(2, str(f))
+		b.uintField(3, str(f))
+		prof.bytesField(5, b.Bytes())
+	}
+	// The string table must be complete before it is written.
+	periodType := valueType("instructions", "count")
+	for _, s := range strs {
+		prof.bytesField(6, []byte(s))
+	}
+	prof.bytesField(11, periodType)
+	prof.uintField(12, 1)
+
+	zw := gzip.NewWriter(w)
+	if _, err := zw.Write(prof.Bytes()); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// protoBuffer encodes the protocol buffer wire format used by WritePprof.
+type protoBuffer struct {
+	bytes.Buffer
+}
+
+func (b *protoBuffer) varint(x uint64) {
+	for x >= 0x80 {
+		b.WriteByte(byte(x) | 0x80)
+		x >>= 7
+	}
+	b.WriteByte(byte(x))
+}
+
+func (b *protoBuffer) uintField(field int, x uint64) {
+	b.varint(uint64(field) << 3)
+	b.varint(x)
+}
+
+func (b *protoBuffer) bytesField(field int, p []byte) {
+	b.varint(uint64(field)<<3 | 2)
+	b.varint(uint64(len(p)))
+	b.Write(p)
+}
+
+func (b *protoBuffer) packedField(field int, xs []uint64) {
+	var p protoBuffer
+	for _, x := range xs {
+		p.varint(x)
+	}
+	b.bytesField(field, p.Bytes())
+}
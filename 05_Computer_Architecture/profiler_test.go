@@ -0,0 +1,103 @@
+package computer
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	logic "github.com/kazufusa/nand2tetris/01_Boolean_Logic"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const maxSymbols = `Main.max 0
+Main.max.FIRST 10
+Main.max.OUTPUT 12 ROM
+END 14
+result 2 RAM
+`
+
+type eventRecorder []Event
+
+func (r *eventRecorder) Observe(e *Event) {
+	*r = append(*r, *e)
+}
+
+func TestComputerObserver(t *testing.T) {
+	com, _, _ := newFastComputer(maxInstructions)
+	com.SetRAM(0, 6)
+	com.SetRAM(1, 10)
+	var rec eventRecorder
+	com.AddObserver(&rec)
+	com.Run(1000)
+	require.Len(t, rec, 12)
+	assert.Equal(t, Event{PC: 0, Inst: 0, InM: 6, AddressM: 0, D: 0, NextPC: 1}, rec[0])
+	assert.Equal(t, Event{PC: 1, Inst: -1008, InM: 6, OutM: 6, AddressM: 0, D: 6, NextPC: 2}, rec[1])
+	assert.Equal(t, Event{PC: 13, Inst: -7416, InM: 0, InAddressM: 2, OutM: 10, WriteM: true, AddressM: 2, A: 2, D: 10, NextPC: 14}, rec[11])
+
+	com.RemoveObserver(&rec)
+	com.FetchAndExecute(logic.O)
+	assert.Len(t, rec, 12)
+}
+
+func TestProfiler(t *testing.T) {
+	com, _, _ := newFastComputer(maxInstructions)
+	com.SetRAM(0, 6)
+	com.SetRAM(1, 10)
+	p := NewProfiler()
+	com.AddObserver(p)
+	com.Run(1000)
+	for i := 0; i < 8; i++ {
+		com.FetchAndExecute(logic.O)
+	}
+	assert.Equal(t, uint64(20), p.Total())
+	assert.Equal(t, uint64(1), p.Count(0))
+	assert.Equal(t, uint64(0), p.Count(10))
+	assert.Equal(t, uint64(4), p.Count(14))
+
+	entries := p.Entries(PROFILE_FUNCTION)
+	assert.Equal(t, ProfileEntry{"ROM[14]", 4}, entries[0], "addresses without symbols")
+	assert.Len(t, entries, 14)
+
+	require.NoError(t, p.LoadSymbols(strings.NewReader(maxSymbols)))
+	assert.Equal(t, []ProfileEntry{{"Main.max", 10}, {"END", 8}, {"Main.max.OUTPUT", 2}}, p.Entries(PROFILE_LABEL))
+	assert.Equal(t, []ProfileEntry{{"Main.max", 12}, {"END", 8}}, p.Entries(PROFILE_FUNCTION))
+	assert.Equal(t, ProfileEntry{"ROM[15] END+1", 4}, p.Entries(PROFILE_ADDRESS)[1])
+
+	var report bytes.Buffer
+	require.NoError(t, p.WriteReport(&report, PROFILE_FUNCTION))
+	assert.Equal(t, `Total: 20 instructions
+       count   flat%    sum%  function
+          12  60.00%  60.00%  Main.max
+           8  40.00% 100.00%  END
+`, report.String())
+
+	var prof bytes.Buffer
+	require.NoError(t, p.WritePprof(&prof))
+	zr, err := gzip.NewReader(&prof)
+	require.NoError(t, err)
+	raw, err := ioutil.ReadAll(zr)
+	require.NoError(t, err)
+	for _, s := range []string{"instructions", "count", "Main.max.OUTPUT", "END"} {
+		assert.Contains(t, string(raw), s)
+	}
+
+	p.Reset()
+	assert.Equal(t, uint64(0), p.Total())
+	assert.Empty(t, p.Entries(PROFILE_LABEL))
+}
+
+func TestVMFunction(t *testing.T) {
+	for label, want := range map[string]string{
+		"Main.main":       "Main.main",
+		"Main.main.LOOP":  "Main.main",
+		"Main.main.3":     "Main.main",
+		"Main.main$ret.0": "Main.main",
+		"$ret.0":          "$ret.0",
+		"LOOP":            "LOOP",
+	} {
+		assert.Equal(t, want, vmFunction(label), label)
+	}
+}
@@ -40,41 +40,37 @@ func (rom *ROM32K) Fetch(addr [15]logic.Bit) (out Word) {
 	)
 }
 
+func (rom *ROM32K) Peek(addr [15]logic.Bit) (out Word) {
+	return rom.Fetch(addr)
+}
+
+func (rom *ROM32K) Store(addr [15]logic.Bit, w Word) {
+	rom.load(addr, w)
+}
+
 func (rom *ROM32K) LoadHackFile(p string) error {
-	b, err := ioutil.ReadFile(p)
+	ws, err := readHackFile(p)
 	if err != nil {
 		return err
 	}
-	addr := Word{}
+	rom.BulkLoad(ws)
+	return nil
+}
+
+// readHackFile returns the 16-character binary lines of a .hack file.
+func readHackFile(p string) ([]Word, error) {
+	b, err := ioutil.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+	var ws []Word
 	for _, b := range bytes.Split(b, []byte("\n")) {
 		if len(b) != 16 {
 			continue
 		}
-		var _addr [15]logic.Bit
-		copy(_addr[:], addr[0:15])
-		word := Word{
-			b[15] - 48,
-			b[14] - 48,
-			b[13] - 48,
-			b[12] - 48,
-			b[11] - 48,
-			b[10] - 48,
-			b[9] - 48,
-			b[8] - 48,
-			b[7] - 48,
-			b[6] - 48,
-			b[5] - 48,
-			b[4] - 48,
-			b[3] - 48,
-			b[2] - 48,
-			b[1] - 48,
-			b[0] - 48,
-		}
-		rom.load(_addr, word)
-		addr = arithmetic.Inc16(addr)
+		ws = append(ws, string2Word(string(b)))
 	}
-
-	return nil
+	return ws, nil
 }
 
 func (rom *ROM32K) BulkLoad(ws []Word) {
@@ -0,0 +1,53 @@
+package computer
+
+// ScreenBuffer is a front buffer of a TuiScreen for one renderer. Update
+// copies only the rows written since the previous Update while holding the
+// screen lock, so rendering from the buffer does not stall the CPU.
+type ScreenBuffer struct {
+	screen *TuiScreen
+	words  screenWords
+	seen   [NROW]uint32
+	lines  [NROW_C][NCOL_C]rune
+	synced bool
+}
+
+func NewScreenBuffer(s *TuiScreen) *ScreenBuffer {
+	return &ScreenBuffer{screen: s}
+}
+
+// Update brings the buffer up to date and returns the pixel rows which
+// changed, in increasing order. The first Update returns every row.
+func (b *ScreenBuffer) Update() []int {
+	var rows []int
+	b.screen.mu.RLock()
+	for r := 0; r < NROW; r++ {
+		if b.synced && b.screen.changes[r] == b.seen[r] {
+			continue
+		}
+		b.seen[r] = b.screen.changes[r]
+		copy(b.words[r*NCOL_W:(r+1)*NCOL_W], b.screen.words[r*NCOL_W:(r+1)*NCOL_W])
+		rows = append(rows, r)
+	}
+	b.screen.mu.RUnlock()
+	b.synced = true
+
+	for i, r := range rows {
+		// Rows come in order, so each text line is rebuilt once.
+		if line := r / 4; i == 0 || rows[i-1]/4 != line {
+			for c := 0; c < NCOL_C; c++ {
+				b.lines[line][c] = brailleChar(&b.words, line, c)
+			}
+		}
+	}
+	return rows
+}
+
+// Line returns text line i, 0 <= i < NROW_C, of the braille rendering.
+func (b *ScreenBuffer) Line(i int) []rune {
+	return b.lines[i][:]
+}
+
+// Words returns the buffered framebuffer.
+func (b *ScreenBuffer) Words() *[NROW * NCOL_W]Word {
+	return &b.words
+}
@@ -0,0 +1,49 @@
+package computer
+
+import (
+	"strings"
+	"testing"
+
+	logic "github.com/kazufusa/nand2tetris/01_Boolean_Logic"
+	"github.com/stretchr/testify/assert"
+)
+
+// storeScreen writes v to word i of the screen like the CPU does.
+func storeScreen(sc *TuiScreen, i, v int) {
+	var addr [13]Bit
+	a := int2Addr(i)
+	copy(addr[:], a[:13])
+	sc.Fetch(int2Word(v), logic.I, addr)
+}
+
+func TestScreenBuffer(t *testing.T) {
+	sc := &TuiScreen{}
+	storeScreen(sc, 5*NCOL_W, -1)
+	buf := NewScreenBuffer(sc)
+	assert.Len(t, buf.Update(), NROW, "the first update copies everything")
+	assert.Empty(t, buf.Update())
+
+	lines := strings.Split(sc.Str(), "\n")
+	for i := 0; i < NROW_C; i++ {
+		assert.Equal(t, lines[i], string(buf.Line(i)))
+	}
+
+	// Writing the same value is not a change.
+	storeScreen(sc, 5*NCOL_W, -1)
+	assert.Empty(t, buf.Update())
+
+	storeScreen(sc, 9*NCOL_W+1, 1)
+	storeScreen(sc, 8*NCOL_W, 1)
+	storeScreen(sc, 200*NCOL_W, 1)
+	rows := buf.Update()
+	assert.Equal(t, []int{8, 9, 200}, rows)
+	assert.Equal(t, int2Word(1), buf.Words()[9*NCOL_W+1])
+	lines = strings.Split(sc.Str(), "\n")
+	assert.Equal(t, lines[2], string(buf.Line(2)))
+	assert.Equal(t, lines[50], string(buf.Line(50)))
+
+	// Each buffer tracks its own changes.
+	other := NewScreenBuffer(sc)
+	assert.Len(t, other.Update(), NROW)
+	assert.Empty(t, buf.Update())
+}
@@ -0,0 +1,246 @@
+package computer
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"io"
+	"strings"
+)
+
+type GraphicsProtocol int
+
+const (
+	GRAPHICS_BRAILLE GraphicsProtocol = iota
+	GRAPHICS_SIXEL
+	GRAPHICS_KITTY
+)
+
+func (p GraphicsProtocol) String() string {
+	switch p {
+	case GRAPHICS_SIXEL:
+		return "sixel"
+	case GRAPHICS_KITTY:
+		return "kitty"
+	}
+	return "braille"
+}
+
+const (
+	// KITTY_IMAGE_ID identifies the screen image to the terminal.
+	KITTY_IMAGE_ID = 1
+	// KITTY_CHUNK is the largest base64 payload of one escape sequence.
+	KITTY_CHUNK = 4096
+)
+
+// DetectGraphics guesses the image protocol of the terminal from its
+// environment. Terminals which are not recognized get GRAPHICS_BRAILLE.
+func DetectGraphics(getenv func(string) string) GraphicsProtocol {
+	term, program := getenv("TERM"), getenv("TERM_PROGRAM")
+	switch {
+	case getenv("KITTY_WINDOW_ID") != "" || term == "xterm-kitty" ||
+		program == "ghostty" || program == "WezTerm":
+		return GRAPHICS_KITTY
+	case strings.Contains(term, "sixel") || strings.HasPrefix(term, "foot") ||
+		term == "mlterm" || program == "iTerm.app":
+		return GRAPHICS_SIXEL
+	}
+	return GRAPHICS_BRAILLE
+}
+
+type screenWords = [NROW * NCOL_W]Word
+
+// screenPixel reports whether the pixel at row r and column c of words is
+// set. As on the Hack screen, bit c%16 of a word, counted from the least
+// significant bit, is column c.
+func screenPixel(words *screenWords, r, c int) bool {
+	return words[r*NCOL_W+c/16][c%16] == 1
+}
+
+// GraphicsRenderer draws the screen pixel-exact with the Sixel or the Kitty
+// graphics protocol. It remembers the words it drew last and only sends
+// the region which changed since.
+type GraphicsRenderer struct {
+	buf   *ScreenBuffer
+	proto GraphicsProtocol
+	// cellHeight is the pixel height of a terminal row. Sixel updates start
+	// at a row boundary, so without it every update redraws the whole image.
+	cellHeight int
+
+	last        screenWords
+	drawn       bool
+	transmitted bool
+}
+
+func NewGraphicsRenderer(s *TuiScreen, proto GraphicsProtocol) *GraphicsRenderer {
+	return &GraphicsRenderer{buf: NewScreenBuffer(s), proto: proto}
+}
+
+func (g *GraphicsRenderer) SetCellHeight(h int) {
+	g.cellHeight = h
+}
+
+// Invalidate makes the next Render draw the whole image, e.g. after the
+// terminal was cleared.
+func (g *GraphicsRenderer) Invalidate() {
+	g.drawn = false
+}
+
+// Render brings the image at terminal cell (row, col), counted from 0, up
+// to date. The cursor is saved and restored around the output.
+func (g *GraphicsRenderer) Render(w io.Writer, row, col int) error {
+	rows := g.buf.Update()
+	cur := g.buf.Words()
+	rect, ok := g.damage(cur, rows)
+	if !ok {
+		return nil
+	}
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "\x1b7\x1b[%d;%dH", row+1, col+1)
+	switch g.proto {
+	case GRAPHICS_SIXEL:
+		if g.cellHeight > 0 {
+			rect.Min.Y -= rect.Min.Y % g.cellHeight
+			if rect.Min.Y > 0 {
+				fmt.Fprintf(bw, "\x1b[%dB", rect.Min.Y/g.cellHeight)
+			}
+		} else {
+			rect.Min.Y = 0
+		}
+		writeSixel(bw, cur, rect.Min.Y, rect.Max.Y)
+	case GRAPHICS_KITTY:
+		if !g.drawn {
+			if g.transmitted {
+				fmt.Fprintf(bw, "\x1b_Ga=d,d=I,i=%d,q=2\x1b\\", KITTY_IMAGE_ID)
+			}
+			writeKitty(bw, cur, rect, fmt.Sprintf("a=T,i=%d,C=1", KITTY_IMAGE_ID))
+			g.transmitted = true
+		} else {
+			writeKitty(bw, cur, rect, fmt.Sprintf("a=f,r=1,i=%d,x=%d,y=%d", KITTY_IMAGE_ID, rect.Min.X, rect.Min.Y))
+		}
+	}
+	bw.WriteString("\x1b8")
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+	g.last = *cur
+	g.drawn = true
+	return nil
+}
+
+// damage returns the pixel rectangle covering the words changed since the
+// last Render. Only the rows the buffer updated can differ.
+func (g *GraphicsRenderer) damage(cur *screenWords, rows []int) (image.Rectangle, bool) {
+	full := image.Rect(0, 0, NCOL, NROW)
+	if !g.drawn {
+		return full, true
+	}
+	r0, r1, c0, c1 := NROW, -1, NCOL_W, -1
+	for _, r := range rows {
+		for c := 0; c < NCOL_W; c++ {
+			if cur[r*NCOL_W+c] == g.last[r*NCOL_W+c] {
+				continue
+			}
+			if r < r0 {
+				r0 = r
+			}
+			r1 = r
+			if c < c0 {
+				c0 = c
+			}
+			if c > c1 {
+				c1 = c
+			}
+		}
+	}
+	if r1 < 0 {
+		return image.Rectangle{}, false
+	}
+	return image.Rect(c0*16, r0, (c1+1)*16, r1+1), true
+}
+
+// writeSixel writes rows y0 to y1-1 of the screen as a two-colour sixel
+// image.
+func writeSixel(w *bufio.Writer, words *screenWords, y0, y1 int) {
+	fmt.Fprintf(w, "\x1bP0;1;0q\"1;1;%d;%d#0;2;100;100;100#1;2;0;0;0", NCOL, y1-y0)
+	for y := y0; y < y1; y += 6 {
+		for color := 0; color < 2; color++ {
+			fmt.Fprintf(w, "#%d", color)
+			var run byte
+			n := 0
+			for x := 0; x < NCOL; x++ {
+				bits := byte(0)
+				for k := 0; k < 6 && y+k < y1; k++ {
+					if screenPixel(words, y+k, x) == (color == 1) {
+						bits |= 1 << k
+					}
+				}
+				if c := '?' + bits; c == run {
+					n++
+				} else {
+					writeSixelRun(w, run, n)
+					run, n = c, 1
+				}
+			}
+			writeSixelRun(w, run, n)
+			if color == 0 {
+				w.WriteByte('$')
+			}
+		}
+		w.WriteByte('-')
+	}
+	w.WriteString("\x1b\\")
+}
+
+func writeSixelRun(w *bufio.Writer, c byte, n int) {
+	switch {
+	case n == 0:
+	case n > 3:
+		fmt.Fprintf(w, "!%d%c", n, c)
+	default:
+		for i := 0; i < n; i++ {
+			w.WriteByte(c)
+		}
+	}
+}
+
+// writeKitty sends rect of the screen as zlib-compressed RGB data with the
+// Kitty graphics protocol. control holds the action specific keys.
+func writeKitty(w *bufio.Writer, words *screenWords, rect image.Rectangle, control string) {
+	var z bytes.Buffer
+	zw := zlib.NewWriter(&z)
+	row := make([]byte, 3*rect.Dx())
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			v := byte(0xff)
+			if screenPixel(words, y, x) {
+				v = 0
+			}
+			i := 3 * (x - rect.Min.X)
+			row[i], row[i+1], row[i+2] = v, v, v
+		}
+		zw.Write(row)
+	}
+	zw.Close()
+
+	data := base64.StdEncoding.EncodeToString(z.Bytes())
+	for first := true; first || len(data) > 0; first = false {
+		chunk := data
+		if len(chunk) > KITTY_CHUNK {
+			chunk = chunk[:KITTY_CHUNK]
+		}
+		data = data[len(chunk):]
+		more := 0
+		if len(data) > 0 {
+			more = 1
+		}
+		if first {
+			fmt.Fprintf(w, "\x1b_G%s,f=24,o=z,s=%d,v=%d,q=2,m=%d;%s\x1b\\", control, rect.Dx(), rect.Dy(), more, chunk)
+		} else {
+			fmt.Fprintf(w, "\x1b_Gm=%d;%s\x1b\\", more, chunk)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package computer
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// decodeSixel paints a sixel image into img starting at row y0, following
+// the subset of the format writeSixel produces.
+func decodeSixel(t *testing.T, img *image.Paletted, y0 int, s string) {
+	i := strings.Index(s, "q")
+	require.True(t, i >= 0)
+	s = s[i+1 : strings.Index(s, "\x1b\\")]
+	color, x, y := 0, 0, y0
+	re := regexp.MustCompile(`^(#\d+(;\d+)*|"\d+(;\d+)*|!\d+[?-~]|[?-~$-])`)
+	for len(s) > 0 {
+		tok := re.FindString(s)
+		require.NotEmpty(t, tok, "unexpected %q", s)
+		s = s[len(tok):]
+		n := 1
+		switch tok[0] {
+		case '"':
+			continue
+		case '#':
+			if !strings.Contains(tok, ";") {
+				color, _ = strconv.Atoi(tok[1:])
+			}
+			continue
+		case '$':
+			x = 0
+			continue
+		case '-':
+			x, y = 0, y+6
+			continue
+		case '!':
+			n, _ = strconv.Atoi(tok[1 : len(tok)-1])
+			tok = tok[len(tok)-1:]
+		}
+		bits := tok[0] - '?'
+		for ; n > 0; n-- {
+			for k := 0; k < 6; k++ {
+				if bits&(1<<k) != 0 {
+					img.SetColorIndex(x, y+k, uint8(color))
+				}
+			}
+			x++
+		}
+	}
+}
+
+var kittyRe = regexp.MustCompile("\x1b_G([^;]*);([^\x1b]*)\x1b\\\\")
+
+// decodeKitty returns the control keys of the first chunk and the RGB data
+// of all chunks.
+func decodeKitty(t *testing.T, s string) (map[string]string, []byte) {
+	keys := map[string]string{}
+	var data string
+	for i, m := range kittyRe.FindAllStringSubmatch(s, -1) {
+		for _, kv := range strings.Split(m[1], ",") {
+			if i == 0 {
+				p := strings.SplitN(kv, "=", 2)
+				keys[p[0]] = p[1]
+			}
+		}
+		data += m[2]
+	}
+	z, err := base64.StdEncoding.DecodeString(data)
+	require.NoError(t, err)
+	zr, err := zlib.NewReader(bytes.NewReader(z))
+	require.NoError(t, err)
+	rgb, err := ioutil.ReadAll(zr)
+	require.NoError(t, err)
+	return keys, rgb
+}
+
+func testScreen() *TuiScreen {
+	sc := &TuiScreen{}
+	for r := 0; r < NROW; r += 3 {
+		storeScreen(sc, r*NCOL_W+r%NCOL_W, 0x1234+r)
+	}
+	return sc
+}
+
+func TestSixelRenderer(t *testing.T) {
+	sc := testScreen()
+	g := NewGraphicsRenderer(sc, GRAPHICS_SIXEL)
+	var buf bytes.Buffer
+	require.NoError(t, g.Render(&buf, 2, 5))
+	out := buf.String()
+	assert.True(t, strings.HasPrefix(out, "\x1b7\x1b[3;6H\x1bP"))
+	assert.True(t, strings.HasSuffix(out, "\x1b\\\x1b8"))
+
+	img := image.NewPaletted(image.Rect(0, 0, NCOL, NROW), screenPalette)
+	decodeSixel(t, img, 0, out)
+	assert.Equal(t, sc.Image().Pix, img.Pix)
+
+	buf.Reset()
+	require.NoError(t, g.Render(&buf, 2, 5))
+	assert.Equal(t, "", buf.String(), "nothing changed")
+
+	// With a known cell height only the rows from the changed cell row on
+	// are redrawn.
+	g.SetCellHeight(16)
+	storeScreen(sc, 100*NCOL_W+7, -1)
+	require.NoError(t, g.Render(&buf, 2, 5))
+	out = buf.String()
+	assert.Contains(t, out, "\x1b[6B")
+	assert.Contains(t, out, fmt.Sprintf("\"1;1;%d;%d", NCOL, 101-96))
+	decodeSixel(t, img, 96, out)
+	assert.Equal(t, sc.Image().Pix, img.Pix)
+}
+
+func TestKittyRenderer(t *testing.T) {
+	sc := testScreen()
+	g := NewGraphicsRenderer(sc, GRAPHICS_KITTY)
+	var buf bytes.Buffer
+	require.NoError(t, g.Render(&buf, 0, 0))
+	keys, rgb := decodeKitty(t, buf.String())
+	assert.Equal(t, "T", keys["a"])
+	assert.Equal(t, "512", keys["s"])
+	assert.Equal(t, "256", keys["v"])
+	require.Len(t, rgb, NROW*NCOL*3)
+	for i, p := range sc.Image().Pix {
+		assert.Equal(t, p == 1, rgb[3*i] == 0)
+	}
+	assert.Greater(t, strings.Count(buf.String(), "\x1b_G"), 0)
+
+	storeScreen(sc, 10*NCOL_W+3, 1)
+	storeScreen(sc, 12*NCOL_W+4, 1)
+	buf.Reset()
+	require.NoError(t, g.Render(&buf, 0, 0))
+	keys, rgb = decodeKitty(t, buf.String())
+	assert.Equal(t, "f", keys["a"])
+	assert.Equal(t, []string{"48", "10", "32", "3"}, []string{keys["x"], keys["y"], keys["s"], keys["v"]})
+	require.Len(t, rgb, 32*3*3)
+	// Column 0 of word 3 is its least significant bit.
+	assert.Equal(t, byte(0), rgb[0])
+	assert.Equal(t, byte(0xff), rgb[3*1])
+	assert.Equal(t, byte(0xff), rgb[3*15])
+
+	g.Invalidate()
+	buf.Reset()
+	require.NoError(t, g.Render(&buf, 0, 0))
+	assert.True(t, strings.HasPrefix(buf.String(), "\x1b7\x1b[1;1H\x1b_Ga=d,d=I,i=1"))
+}
+
+// TestGraphicsBitOrder draws 0x0001, which the Hack screen shows as the
+// leftmost pixel of the word only.
+func TestGraphicsBitOrder(t *testing.T) {
+	sc := &TuiScreen{}
+	storeScreen(sc, 0, 0x0001)
+
+	var buf bytes.Buffer
+	require.NoError(t, NewGraphicsRenderer(sc, GRAPHICS_SIXEL).Render(&buf, 0, 0))
+	img := image.NewPaletted(image.Rect(0, 0, NCOL, NROW), screenPalette)
+	decodeSixel(t, img, 0, buf.String())
+	assert.Equal(t, uint8(1), img.ColorIndexAt(0, 0))
+	assert.Equal(t, uint8(0), img.ColorIndexAt(15, 0))
+
+	buf.Reset()
+	require.NoError(t, NewGraphicsRenderer(sc, GRAPHICS_KITTY).Render(&buf, 0, 0))
+	_, rgb := decodeKitty(t, buf.String())
+	assert.Equal(t, []byte{0, 0, 0}, rgb[0:3])
+	assert.Equal(t, []byte{0xff, 0xff, 0xff}, rgb[3*15:3*16])
+}
+
+func TestDetectGraphics(t *testing.T) {
+	for env, want := range map[string]GraphicsProtocol{
+		"TERM=xterm-256color":                GRAPHICS_BRAILLE,
+		"TERM=xterm-kitty":                   GRAPHICS_KITTY,
+		"KITTY_WINDOW_ID=1":                  GRAPHICS_KITTY,
+		"TERM_PROGRAM=WezTerm":               GRAPHICS_KITTY,
+		"TERM=foot":                          GRAPHICS_SIXEL,
+		"TERM=xterm-sixel":                   GRAPHICS_SIXEL,
+		"TERM_PROGRAM=iTerm.app":             GRAPHICS_SIXEL,
+		"TERM=screen TERM_PROGRAM=Apple_Tty": GRAPHICS_BRAILLE,
+	} {
+		vars := map[string]string{}
+		for _, kv := range strings.Fields(env) {
+			p := strings.SplitN(kv, "=", 2)
+			vars[p[0]] = p[1]
+		}
+		assert.Equal(t, want, DetectGraphics(func(k string) string { return vars[k] }), env)
+	}
+	assert.Equal(t, "sixel", GRAPHICS_SIXEL.String())
+}
@@ -0,0 +1,99 @@
+package computer
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"io"
+)
+
+// screenPalette draws set pixels black on white.
+var screenPalette = color.Palette{color.White, color.Black}
+
+// pixel reports whether the pixel at row r and column c is set. Columns run
+// from the most significant bit of a word, in the same order as Str.
+func (s *TuiScreen) pixel(r, c int) bool {
+	return screenPixel(&s.words, r, c)
+}
+
+// Image returns the 512x256 framebuffer.
+func (s *TuiScreen) Image() *image.Paletted {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	img := image.NewPaletted(image.Rect(0, 0, NCOL, NROW), screenPalette)
+	for r := 0; r < NROW; r++ {
+		for c := 0; c < NCOL; c++ {
+			if s.pixel(r, c) {
+				img.Pix[r*img.Stride+c] = 1
+			}
+		}
+	}
+	return img
+}
+
+func (s *TuiScreen) WritePNG(w io.Writer) error {
+	return png.Encode(w, s.Image())
+}
+
+// WritePPM writes the framebuffer as a binary (P6) portable pixmap.
+func (s *TuiScreen) WritePPM(w io.Writer) error {
+	img := s.Image()
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "P6\n%d %d\n255\n", NCOL, NROW)
+	for _, p := range img.Pix {
+		v := byte(0xff)
+		if p == 1 {
+			v = 0
+		}
+		bw.Write([]byte{v, v, v})
+	}
+	return bw.Flush()
+}
+
+// GIFRecorder collects screen frames for an animated GIF. Identical
+// consecutive frames are merged into one longer frame.
+type GIFRecorder struct {
+	screen *TuiScreen
+	delay  int
+	anim   gif.GIF
+}
+
+// NewGIFRecorder records s at fps frames per second. GIF delays have a
+// resolution of 1/100 s, so fps above 100 is clamped.
+func NewGIFRecorder(s *TuiScreen, fps int) *GIFRecorder {
+	if fps < 1 {
+		fps = 1
+	}
+	delay := 100 / fps
+	if delay < 1 {
+		delay = 1
+	}
+	return &GIFRecorder{screen: s, delay: delay}
+}
+
+// Capture adds the current screen as the next frame.
+func (r *GIFRecorder) Capture() {
+	img := r.screen.Image()
+	if n := len(r.anim.Image); n > 0 && bytes.Equal(r.anim.Image[n-1].Pix, img.Pix) {
+		r.anim.Delay[n-1] += r.delay
+		return
+	}
+	r.anim.Image = append(r.anim.Image, img)
+	r.anim.Delay = append(r.anim.Delay, r.delay)
+}
+
+// Frames returns the number of distinct frames captured so far.
+func (r *GIFRecorder) Frames() int {
+	return len(r.anim.Image)
+}
+
+func (r *GIFRecorder) Encode(w io.Writer) error {
+	if len(r.anim.Image) == 0 {
+		r.Capture()
+	}
+	return gif.EncodeAll(w, &r.anim)
+}
@@ -0,0 +1,75 @@
+package computer
+
+import (
+	"bytes"
+	"image/gif"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTuiScreenImage(t *testing.T) {
+	sc := TuiScreen{}
+	// The least significant bit of a word is its leftmost pixel.
+	sc.words[0] = int2Word(1)
+	sc.words[NROW*NCOL/16-1] = int2Word(-32768)
+
+	img := sc.Image()
+	assert.Equal(t, NCOL, img.Bounds().Dx())
+	assert.Equal(t, NROW, img.Bounds().Dy())
+	assert.Equal(t, uint8(1), img.ColorIndexAt(0, 0))
+	assert.Equal(t, uint8(0), img.ColorIndexAt(1, 0))
+	assert.Equal(t, uint8(1), img.ColorIndexAt(NCOL-1, NROW-1))
+
+	var buf bytes.Buffer
+	require.NoError(t, sc.WritePNG(&buf))
+	decoded, err := png.Decode(&buf)
+	require.NoError(t, err)
+	r, g, b, _ := decoded.At(0, 0).RGBA()
+	assert.Equal(t, [3]uint32{0, 0, 0}, [3]uint32{r, g, b})
+	r, g, b, _ = decoded.At(1, 0).RGBA()
+	assert.Equal(t, [3]uint32{0xffff, 0xffff, 0xffff}, [3]uint32{r, g, b})
+
+	buf.Reset()
+	require.NoError(t, sc.WritePPM(&buf))
+	header := "P6\n512 256\n255\n"
+	require.Equal(t, len(header)+NROW*NCOL*3, buf.Len())
+	assert.Equal(t, header, buf.String()[:len(header)])
+	assert.Equal(t, []byte{0, 0, 0, 0xff, 0xff, 0xff}, buf.Bytes()[len(header):len(header)+6])
+}
+
+func TestScreenImageBitOrder(t *testing.T) {
+	com, sc, _ := newFastComputer(nil)
+	com.SetRAM(SCREEN_ADDR, 1)
+
+	var buf bytes.Buffer
+	require.NoError(t, sc.WritePNG(&buf))
+	decoded, err := png.Decode(&buf)
+	require.NoError(t, err)
+	r, g, b, _ := decoded.At(0, 0).RGBA()
+	assert.Equal(t, [3]uint32{0, 0, 0}, [3]uint32{r, g, b}, "RAM[SCREEN]=1 sets the leftmost pixel")
+	r, g, b, _ = decoded.At(15, 0).RGBA()
+	assert.Equal(t, [3]uint32{0xffff, 0xffff, 0xffff}, [3]uint32{r, g, b})
+}
+
+func TestGIFRecorder(t *testing.T) {
+	sc := TuiScreen{}
+	rec := NewGIFRecorder(&sc, 10)
+	rec.Capture()
+	rec.Capture()
+	sc.words[5] = Word{1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1}
+	rec.Capture()
+	assert.Equal(t, 2, rec.Frames())
+
+	var buf bytes.Buffer
+	require.NoError(t, rec.Encode(&buf))
+	anim, err := gif.DecodeAll(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, []int{20, 10}, anim.Delay)
+	assert.Equal(t, uint8(1), anim.Image[1].ColorIndexAt(80, 0))
+	assert.Equal(t, uint8(0), anim.Image[0].ColorIndexAt(80, 0))
+
+	assert.Equal(t, 1, NewGIFRecorder(&sc, 1000).delay)
+}
@@ -0,0 +1,94 @@
+package computer
+
+import (
+	"io"
+	"sync"
+
+	logic "github.com/kazufusa/nand2tetris/01_Boolean_Logic"
+)
+
+// Serial registers, relative to SERIAL_ADDR.
+const (
+	SERIAL_ADDR = KBD_ADDR + 0x20
+
+	SERIAL_TX_DATA   = 0 // a write sends the low byte
+	SERIAL_TX_STATUS = 1 // 1 when TX_DATA accepts a byte
+	SERIAL_RX_DATA   = 2 // the oldest received byte, 0 when none
+	SERIAL_RX_STATUS = 3 // 1 when RX_DATA holds a byte; a write consumes it
+	SERIAL_SIZE      = 4
+)
+
+// Serial is a serial port for a Bus. Reading a register has no side effect,
+// since the CPU reads M on every instruction, so a program reads RX_DATA and
+// then writes RX_STATUS to take the byte.
+type Serial struct {
+	out io.Writer
+
+	mu sync.Mutex
+	rx []byte
+}
+
+var _ IDevice = (*Serial)(nil)
+
+// NewSerial sends the transmitted bytes to out.
+func NewSerial(out io.Writer) *Serial {
+	return &Serial{out: out}
+}
+
+// Map attaches the serial registers to b at SERIAL_ADDR.
+func (s *Serial) Map(b *Bus) {
+	b.Attach(SERIAL_ADDR, SERIAL_SIZE, s)
+}
+
+// Receive queues bytes for the program to read.
+func (s *Serial) Receive(p []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rx = append(s.rx, p...)
+}
+
+// ReadFrom receives everything read from r until EOF. It is meant to run in
+// its own goroutine.
+func (s *Serial) ReadFrom(r io.Reader) (int64, error) {
+	var n int64
+	buf := make([]byte, 256)
+	for {
+		m, err := r.Read(buf)
+		s.Receive(buf[:m])
+		n += int64(m)
+		if err == io.EOF {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+	}
+}
+
+func (s *Serial) Fetch(in Word, load Bit, reg int) (out Word) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch reg {
+	case SERIAL_TX_STATUS:
+		out = int2Word(1)
+	case SERIAL_RX_DATA:
+		if len(s.rx) > 0 {
+			out = int2Word(int(s.rx[0]))
+		}
+	case SERIAL_RX_STATUS:
+		if len(s.rx) > 0 {
+			out = int2Word(1)
+		}
+	}
+	if load == logic.I {
+		switch reg {
+		case SERIAL_TX_DATA:
+			s.out.Write([]byte{byte(word2Int(in))})
+		case SERIAL_RX_STATUS:
+			if len(s.rx) > 0 {
+				s.rx = s.rx[1:]
+			}
+		}
+	}
+	return out
+}
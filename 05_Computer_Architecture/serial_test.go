@@ -0,0 +1,70 @@
+package computer
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	memory "github.com/kazufusa/nand2tetris/03_Memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// echoSerial copies every received byte back to the serial port.
+var echoSerial = []string{
+	"0110000000100011", // (LOOP) @SERIAL_RX_STATUS
+	"1111110000010000", // D=M
+	"0000000000000000", // @LOOP
+	"1110001100000010", // D;JEQ
+	"0110000000100010", // @SERIAL_RX_DATA
+	"1111110000010000", // D=M
+	"0110000000100000", // @SERIAL_TX_DATA
+	"1110001100001000", // M=D
+	"0110000000100011", // @SERIAL_RX_STATUS
+	"1110111111001000", // M=1
+	"0000000000000000", // @LOOP
+	"1110101010000111", // 0;JMP
+}
+
+func newSerialComputer(out *bytes.Buffer) (*Computer, *Serial) {
+	var inst []Word
+	for _, s := range echoSerial {
+		inst = append(inst, string2Word(s))
+	}
+	clock := memory.Clock(0)
+	ram := NewFastMemory(&TuiScreen{}, &TuiKeyboard{})
+	serial := NewSerial(out)
+	serial.Map(&ram.Bus)
+	rom := VROM32K{}
+	rom.BulkLoad(inst)
+	cpu := NewFastCPU()
+	com := NewComputer(&cpu, &ram, &rom, &clock)
+	return &com, serial
+}
+
+func TestSerial(t *testing.T) {
+	var out bytes.Buffer
+	com, serial := newSerialComputer(&out)
+	com.Run(100)
+	assert.Equal(t, "", out.String())
+	assert.Equal(t, 1, com.RAM(SERIAL_ADDR+SERIAL_TX_STATUS))
+	assert.Equal(t, 0, com.RAM(SERIAL_ADDR+SERIAL_RX_STATUS))
+
+	serial.Receive([]byte("hi\n"))
+	assert.Equal(t, 1, com.RAM(SERIAL_ADDR+SERIAL_RX_STATUS))
+	assert.Equal(t, int('h'), com.RAM(SERIAL_ADDR+SERIAL_RX_DATA))
+	com.Run(100)
+	assert.Equal(t, "hi\n", out.String())
+
+	n, err := serial.ReadFrom(strings.NewReader("more"))
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), n)
+	com.Run(100)
+	assert.Equal(t, "hi\nmore", out.String())
+	assert.Equal(t, 0, com.RAM(SERIAL_ADDR+SERIAL_RX_DATA))
+
+	com.SetRAM(SERIAL_ADDR+SERIAL_TX_DATA, 0x141)
+	assert.Equal(t, "hi\nmoreA", out.String())
+	com.SetRAM(200, 7)
+	assert.Equal(t, 7, com.RAM(200))
+}
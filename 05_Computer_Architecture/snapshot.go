@@ -0,0 +1,145 @@
+package computer
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	memory "github.com/kazufusa/nand2tetris/03_Memory"
+)
+
+const (
+	SNAPSHOT_VERSION = 1
+	// RAM, screen and keyboard
+	SNAPSHOT_RAM_SIZE = KBD_ADDR + 1
+	SNAPSHOT_ROM_SIZE = 32768
+)
+
+var snapshotMagic = [8]byte{'H', 'A', 'C', 'K', 'S', 'N', 'A', 'P'}
+
+// Snapshot is the complete state of a Computer. RAM covers the data memory,
+// the screen (RAM[16384..24575]) and the keyboard register (RAM[24576]).
+type Snapshot struct {
+	Clock uint8
+	A, D  int16
+	PC    int16
+	RAM   [SNAPSHOT_RAM_SIZE]int16
+	ROM   [SNAPSHOT_ROM_SIZE]int16
+}
+
+type snapshotHeader struct {
+	Magic   [8]byte
+	Version uint16
+	Clock   uint8
+	A, D    int16
+	PC      int16
+}
+
+// keyboardSetter is implemented by keyboards whose state can be restored.
+type keyboardSetter interface {
+	SetWord(w Word)
+}
+
+// Snapshot captures the machine state through the inspection API. On the
+// gate-level Memory and ROM32K every word is a full RAM evaluation, taking
+// minutes in all, so the computer command only offers snapshots with -fast.
+func (com *Computer) Snapshot() *Snapshot {
+	s := &Snapshot{
+		Clock: uint8(*com.clock),
+		A:     int16(com.A()),
+		D:     int16(com.D()),
+		PC:    int16(com.PC()),
+	}
+	for i := range s.RAM {
+		s.RAM[i] = int16(com.RAM(i))
+	}
+	for i := range s.ROM {
+		s.ROM[i] = int16(com.ROM(i))
+	}
+	return s
+}
+
+// Restore replaces the machine state with s.
+func (com *Computer) Restore(s *Snapshot) {
+	nROM := len(s.ROM)
+	if x, ok := com.rom.(*VROM32K); ok {
+		// IsFinished treats addresses past the loaded program as the end.
+		x.values = nil
+		for nROM > 0 && s.ROM[nROM-1] == 0 {
+			nROM--
+		}
+	}
+	for i := 0; i < nROM; i++ {
+		com.SetROM(i, int(s.ROM[i]))
+	}
+
+	vm, sparse := com.ram.(*VMemory)
+	if sparse {
+		vm.values = nil
+	}
+	for i := 0; i < KBD_ADDR; i++ {
+		if !sparse || s.RAM[i] != 0 {
+			com.SetRAM(i, int(s.RAM[i]))
+		}
+	}
+	if x, ok := keyboardOf(com.ram).(keyboardSetter); ok {
+		x.SetWord(int2Word(int(s.RAM[KBD_ADDR])))
+	}
+	// SetRAM progresses the clock, so it is restored last.
+	*com.clock = memory.Clock(s.Clock)
+	com.SetD(int(s.D))
+	com.SetPC(int(s.PC))
+	com.SetA(int(s.A))
+}
+
+// keyboardOf finds the keyboard of the classic memory map.
+func keyboardOf(mem IMemory) IKeyboard {
+	switch x := mem.(type) {
+	case *Memory:
+		return x.Keyboard()
+	case *FastMemory:
+		return x.Keyboard()
+	case *Bus:
+		return x.Keyboard()
+	}
+	return nil
+}
+
+func (s *Snapshot) WriteTo(w io.Writer) (int64, error) {
+	h := snapshotHeader{
+		Magic:   snapshotMagic,
+		Version: SNAPSHOT_VERSION,
+		Clock:   s.Clock,
+		A:       s.A,
+		D:       s.D,
+		PC:      s.PC,
+	}
+	for _, v := range []interface{}{h, s.RAM, s.ROM} {
+		if err := binary.Write(w, binary.BigEndian, v); err != nil {
+			return 0, err
+		}
+	}
+	return int64(binary.Size(h) + binary.Size(s.RAM) + binary.Size(s.ROM)), nil
+}
+
+func ReadSnapshot(r io.Reader) (*Snapshot, error) {
+	var h snapshotHeader
+	if err := binary.Read(r, binary.BigEndian, &h); err != nil {
+		return nil, err
+	}
+	if h.Magic != snapshotMagic {
+		return nil, errors.New("not a hack snapshot")
+	}
+	if h.Version != SNAPSHOT_VERSION {
+		return nil, fmt.Errorf("unsupported snapshot version %d", h.Version)
+	}
+	s := &Snapshot{Clock: h.Clock, A: h.A, D: h.D, PC: h.PC}
+	if err := binary.Read(r, binary.BigEndian, &s.RAM); err != nil {
+		return nil, err
+	}
+	if err := binary.Read(r, binary.BigEndian, &s.ROM); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
@@ -0,0 +1,90 @@
+package computer
+
+import (
+	"bytes"
+	"testing"
+
+	logic "github.com/kazufusa/nand2tetris/01_Boolean_Logic"
+	memory "github.com/kazufusa/nand2tetris/03_Memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newFastComputer(inst []Word) (*Computer, *TuiScreen, *TuiKeyboard) {
+	clock := memory.Clock(0)
+	sc := &TuiScreen{}
+	kb := &TuiKeyboard{}
+	ram := NewFastMemory(sc, kb)
+	rom := VROM32K{}
+	rom.BulkLoad(inst)
+	cpu := NewFastCPU()
+	com := NewComputer(&cpu, &ram, &rom, &clock)
+	return &com, sc, kb
+}
+
+func TestSnapshot(t *testing.T) {
+	com, _, kb := newFastComputer(maxInstructions)
+	com.SetRAM(0, 6)
+	com.SetRAM(1, 10)
+	com.SetRAM(SCREEN_ADDR+100, -1)
+	kb.SetWord(int2Word(65))
+	for i := 0; i < 9; i++ {
+		com.FetchAndExecute(logic.O)
+	}
+
+	var buf bytes.Buffer
+	_, err := com.Snapshot().WriteTo(&buf)
+	require.NoError(t, err)
+	snap, err := ReadSnapshot(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, com.Snapshot(), snap)
+
+	restored, sc, rkb := newFastComputer(nil)
+	restored.Restore(snap)
+	assert.Equal(t, snap, restored.Snapshot())
+	assert.Equal(t, int2Word(-1), sc.words[100])
+	assert.Equal(t, int2Word(65), rkb.Fetch())
+	assert.False(t, restored.IsFinished())
+
+	for i := 0; i < 10; i++ {
+		com.FetchAndExecute(logic.O)
+		restored.FetchAndExecute(logic.O)
+	}
+	assert.Equal(t, 10, restored.RAM(2))
+	assert.Equal(t, com.Snapshot(), restored.Snapshot())
+}
+
+func TestReadSnapshotErrors(t *testing.T) {
+	com, _, _ := newFastComputer(maxInstructions)
+	var buf bytes.Buffer
+	_, err := com.Snapshot().WriteTo(&buf)
+	require.NoError(t, err)
+	b := buf.Bytes()
+
+	_, err = ReadSnapshot(bytes.NewReader(append([]byte("NOTASNAP"), b[8:]...)))
+	assert.EqualError(t, err, "not a hack snapshot")
+
+	bad := append([]byte(nil), b...)
+	bad[9] = 2
+	_, err = ReadSnapshot(bytes.NewReader(bad))
+	assert.EqualError(t, err, "unsupported snapshot version 2")
+
+	_, err = ReadSnapshot(bytes.NewReader(b[:100]))
+	assert.Error(t, err)
+}
+
+func TestSnapshotSparseClock(t *testing.T) {
+	com := NewEmulator(maxInstructions)
+	com.SetRAM(0, 6)
+	com.SetRAM(1, 10)
+	com.SetRAM(100, 7)
+	for i := 0; i < 5; i++ {
+		com.FetchAndExecute(logic.O)
+	}
+	snap := com.Snapshot()
+
+	restored := NewEmulator(maxInstructions)
+	restored.Restore(snap)
+	assert.Equal(t, snap.Clock, restored.Snapshot().Clock, "three RAM words must not advance the clock")
+	assert.Equal(t, 7, restored.RAM(100))
+}
@@ -0,0 +1,167 @@
+package computer
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+
+	logic "github.com/kazufusa/nand2tetris/01_Boolean_Logic"
+)
+
+// Storage registers, relative to STORAGE_ADDR, and the sector buffer.
+const (
+	STORAGE_ADDR = KBD_ADDR + 0x40
+
+	STORAGE_SECTOR  = 0 // sector number used by the next command
+	STORAGE_COMMAND = 1 // write STORAGE_CMD_READ or STORAGE_CMD_WRITE
+	STORAGE_STATUS  = 2 // STORAGE_OK or STORAGE_ERROR after each command
+	STORAGE_SECTORS = 3 // capacity of the image in sectors, read-only
+	STORAGE_SIZE    = 4
+
+	STORAGE_CMD_READ  = 1
+	STORAGE_CMD_WRITE = 2
+
+	STORAGE_OK    = 0
+	STORAGE_ERROR = 1
+
+	// STORAGE_BUFFER is the first word of the sector buffer which commands
+	// copy from and to the image. Words are stored little-endian on disk.
+	STORAGE_BUFFER       = KBD_ADDR + 0x100
+	STORAGE_SECTOR_WORDS = 256
+	STORAGE_SECTOR_BYTES = 2 * STORAGE_SECTOR_WORDS
+	STORAGE_MAX_SECTORS  = 1<<15 - 1
+)
+
+// IDiskImage is the host file behind a Storage.
+type IDiskImage interface {
+	io.ReaderAt
+	io.WriterAt
+}
+
+// Storage is a block device for a Bus. Commands complete synchronously, so a program writes the sector number and
+// the command and then checks the status.
+type Storage struct {
+	image   IDiskImage
+	sectors int
+
+	sector uint16
+	status uint16
+	buffer [STORAGE_SECTOR_WORDS]uint16
+}
+
+var _ IDevice = (*Storage)(nil)
+
+func NewStorage(image IDiskImage, sectors int) *Storage {
+	return &Storage{image: image, sectors: sectors}
+}
+
+// Map attaches the registers to b at STORAGE_ADDR and the buffer at
+// STORAGE_BUFFER.
+func (s *Storage) Map(b *Bus) {
+	b.Attach(STORAGE_ADDR, STORAGE_SIZE, s)
+	b.Attach(STORAGE_BUFFER, STORAGE_SECTOR_WORDS, storageBuffer{s})
+}
+
+// storageBuffer is the sector buffer of a Storage on a Bus.
+type storageBuffer struct {
+	s *Storage
+}
+
+func (b storageBuffer) Fetch(in Word, load Bit, i int) Word {
+	return b.s.fetchBuffer(in, load, i)
+}
+
+// OpenStorage uses the disk image at path. A missing image is created with
+// the given number of sectors; an existing one keeps its size.
+func OpenStorage(path string, sectors int) (*Storage, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if fi.Size() == 0 {
+		err = f.Truncate(int64(sectors) * STORAGE_SECTOR_BYTES)
+	} else {
+		sectors = int(fi.Size() / STORAGE_SECTOR_BYTES)
+	}
+	if err == nil && (sectors < 1 || sectors > STORAGE_MAX_SECTORS) {
+		err = fmt.Errorf("%s: image must hold 1 to %d sectors of %d bytes", path, STORAGE_MAX_SECTORS, STORAGE_SECTOR_BYTES)
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return NewStorage(f, sectors), nil
+}
+
+// Close closes the disk image if it is a file.
+func (s *Storage) Close() error {
+	if c, ok := s.image.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+func (s *Storage) fetchBuffer(in Word, load Bit, i int) (out Word) {
+	out = int2Word(int(int16(s.buffer[i])))
+	if load == logic.I {
+		s.buffer[i] = uint16(word2Int(in))
+	}
+	return out
+}
+
+func (s *Storage) Fetch(in Word, load Bit, reg int) (out Word) {
+	switch reg {
+	case STORAGE_SECTOR:
+		out = int2Word(int(int16(s.sector)))
+	case STORAGE_STATUS:
+		out = int2Word(int(s.status))
+	case STORAGE_SECTORS:
+		out = int2Word(int(int16(s.sectors)))
+	}
+	if load == logic.I {
+		v := uint16(word2Int(in))
+		switch reg {
+		case STORAGE_SECTOR:
+			s.sector = v
+		case STORAGE_COMMAND:
+			s.status = STORAGE_OK
+			if err := s.command(v); err != nil {
+				s.status = STORAGE_ERROR
+			}
+		}
+	}
+	return out
+}
+
+func (s *Storage) command(cmd uint16) error {
+	if int(s.sector) >= s.sectors {
+		return fmt.Errorf("sector %d out of range", s.sector)
+	}
+	off := int64(s.sector) * STORAGE_SECTOR_BYTES
+	var b [STORAGE_SECTOR_BYTES]byte
+	switch cmd {
+	case STORAGE_CMD_READ:
+		// The image may end before the sector does; the rest reads as 0.
+		if _, err := s.image.ReadAt(b[:], off); err != nil && err != io.EOF {
+			return err
+		}
+		for i := range s.buffer {
+			s.buffer[i] = binary.LittleEndian.Uint16(b[2*i:])
+		}
+	case STORAGE_CMD_WRITE:
+		for i, v := range s.buffer {
+			binary.LittleEndian.PutUint16(b[2*i:], v)
+		}
+		_, err := s.image.WriteAt(b[:], off)
+		return err
+	default:
+		return fmt.Errorf("unknown command %d", cmd)
+	}
+	return nil
+}
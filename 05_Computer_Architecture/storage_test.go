@@ -0,0 +1,109 @@
+package computer
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	memory "github.com/kazufusa/nand2tetris/03_Memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memImage is an in-memory disk image.
+type memImage []byte
+
+func (m memImage) ReadAt(p []byte, off int64) (int, error) {
+	if off >= int64(len(m)) {
+		return 0, io.EOF
+	}
+	n := copy(p, m[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (m memImage) WriteAt(p []byte, off int64) (int, error) {
+	return copy(m[off:], p), nil
+}
+
+func newStorageComputer(image IDiskImage, sectors int) *Computer {
+	clock := memory.Clock(0)
+	ram := NewFastMemory(&TuiScreen{}, &TuiKeyboard{})
+	rom := VROM32K{}
+	cpu := NewFastCPU()
+	NewStorage(image, sectors).Map(&ram.Bus)
+	com := NewComputer(&cpu, &ram, &rom, &clock)
+	return &com
+}
+
+func TestStorage(t *testing.T) {
+	image := make(memImage, 4*STORAGE_SECTOR_BYTES)
+	image[2*STORAGE_SECTOR_BYTES] = 0x34
+	image[2*STORAGE_SECTOR_BYTES+1] = 0x12
+	com := newStorageComputer(image, 4)
+	assert.Equal(t, 4, com.RAM(STORAGE_ADDR+STORAGE_SECTORS))
+
+	com.SetRAM(STORAGE_ADDR+STORAGE_SECTOR, 2)
+	com.SetRAM(STORAGE_ADDR+STORAGE_COMMAND, STORAGE_CMD_READ)
+	assert.Equal(t, STORAGE_OK, com.RAM(STORAGE_ADDR+STORAGE_STATUS))
+	assert.Equal(t, 0x1234, com.RAM(STORAGE_BUFFER))
+	assert.Equal(t, 0, com.RAM(STORAGE_BUFFER+1))
+
+	com.SetRAM(STORAGE_BUFFER, -1)
+	com.SetRAM(STORAGE_BUFFER+STORAGE_SECTOR_WORDS-1, 0x0102)
+	com.SetRAM(STORAGE_ADDR+STORAGE_SECTOR, 3)
+	com.SetRAM(STORAGE_ADDR+STORAGE_COMMAND, STORAGE_CMD_WRITE)
+	assert.Equal(t, STORAGE_OK, com.RAM(STORAGE_ADDR+STORAGE_STATUS))
+	sector := image[3*STORAGE_SECTOR_BYTES:]
+	assert.Equal(t, []byte{0xff, 0xff}, []byte(sector[:2]))
+	assert.Equal(t, []byte{0x02, 0x01}, []byte(sector[STORAGE_SECTOR_BYTES-2:]))
+
+	com.SetRAM(STORAGE_ADDR+STORAGE_SECTOR, 4)
+	com.SetRAM(STORAGE_ADDR+STORAGE_COMMAND, STORAGE_CMD_READ)
+	assert.Equal(t, STORAGE_ERROR, com.RAM(STORAGE_ADDR+STORAGE_STATUS))
+	com.SetRAM(STORAGE_ADDR+STORAGE_SECTOR, 0)
+	com.SetRAM(STORAGE_ADDR+STORAGE_COMMAND, 7)
+	assert.Equal(t, STORAGE_ERROR, com.RAM(STORAGE_ADDR+STORAGE_STATUS))
+
+	// Addresses outside the device still reach the RAM and the keyboard.
+	com.SetRAM(100, 42)
+	assert.Equal(t, 42, com.RAM(100))
+	assert.Equal(t, 0, com.RAM(STORAGE_ADDR+STORAGE_SIZE))
+	assert.Equal(t, 0, com.RAM(STORAGE_BUFFER+STORAGE_SECTOR_WORDS))
+}
+
+func TestOpenStorage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "storage")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "disk.img")
+
+	s, err := OpenStorage(path, 8)
+	require.NoError(t, err)
+	s.fetchBuffer(int2Word(0x55aa), 1, 1)
+	s.Fetch(int2Word(7), 1, STORAGE_SECTOR)
+	s.Fetch(int2Word(STORAGE_CMD_WRITE), 1, STORAGE_COMMAND)
+	require.NoError(t, s.Close())
+
+	b, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	assert.Len(t, b, 8*STORAGE_SECTOR_BYTES)
+	assert.Equal(t, []byte{0xaa, 0x55}, b[7*STORAGE_SECTOR_BYTES+2:7*STORAGE_SECTOR_BYTES+4])
+
+	// An existing image keeps its size and contents.
+	s, err = OpenStorage(path, 100)
+	require.NoError(t, err)
+	defer s.Close()
+	assert.Equal(t, 8, word2Int(s.Fetch(Word{}, 0, STORAGE_SECTORS)))
+	s.Fetch(int2Word(7), 1, STORAGE_SECTOR)
+	s.Fetch(int2Word(STORAGE_CMD_READ), 1, STORAGE_COMMAND)
+	assert.Equal(t, 0x55aa, word2Int(s.fetchBuffer(Word{}, 0, 1)))
+
+	require.NoError(t, ioutil.WriteFile(path, []byte{1}, 0644))
+	_, err = OpenStorage(path, 8)
+	assert.Error(t, err)
+}
@@ -0,0 +1,198 @@
+package computer
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// THROTTLE_SLICE is the wall-clock time covered by one Batch.
+	THROTTLE_SLICE = time.Millisecond
+	// THROTTLE_MAX_LAG is how far execution may fall behind the target
+	// before the schedule is reset instead of catching up in a burst.
+	THROTTLE_MAX_LAG = 100 * time.Millisecond
+	// THROTTLE_WINDOW is the period over which Rate is measured.
+	THROTTLE_WINDOW = 500 * time.Millisecond
+	// THROTTLE_CHECKS is about how often Run reads the clock per batch.
+	THROTTLE_CHECKS = 8
+)
+
+// Throttle paces execution to a target clock frequency. The run loop executes
+// a batch of instructions with Run and then calls Wait with the number it
+// executed.
+// The schedule is kept in total cycles rather than per batch, so rounding
+// and sleep overshoot do not accumulate.
+type Throttle struct {
+	mu         sync.Mutex
+	resumed    *sync.Cond
+	hz         float64
+	multiplier float64
+	paused     bool
+	// check is the number of instructions Run executes between reading
+	// the clock, sized from the previous batch.
+	check int
+
+	base   time.Time
+	cycles uint64
+
+	windowStart  time.Time
+	windowCycles uint64
+	rate         float64
+
+	now   func() time.Time
+	sleep func(time.Duration)
+}
+
+// NewThrottle runs at hz instructions per second. 0 means unlimited.
+func NewThrottle(hz float64) *Throttle {
+	t := &Throttle{hz: hz, multiplier: 1, check: 1, now: time.Now, sleep: time.Sleep}
+	t.resumed = sync.NewCond(&t.mu)
+	t.rebase()
+	t.windowStart = t.base
+	return t
+}
+
+// rebase restarts the schedule from now.
+func (t *Throttle) rebase() {
+	t.base = t.now()
+	t.cycles = 0
+}
+
+func (t *Throttle) target() float64 {
+	return t.hz * t.multiplier
+}
+
+// Batch returns how many instructions to execute between calls to Wait, or
+// 0 when unlimited.
+func (t *Throttle) Batch() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.target() <= 0 {
+		return 0
+	}
+	n := int(t.target() * THROTTLE_SLICE.Seconds())
+	if n < 1 {
+		return 1
+	}
+	return n
+}
+
+// Run calls step for a batch and returns the number of calls. A batch ends
+// after Batch instructions or THROTTLE_SLICE, whichever comes first, so that
+// a slow CPU still yields to the UI, and runs at least one instruction.
+// The clock is read about THROTTLE_CHECKS times per batch rather than after
+// every instruction.
+func (t *Throttle) Run(step func()) int {
+	max := t.Batch()
+	t.mu.Lock()
+	end := t.now().Add(THROTTLE_SLICE)
+	check := t.check
+	t.mu.Unlock()
+	n := 0
+	for max == 0 || n < max {
+		step()
+		n++
+		if n%check == 0 && !t.now().Before(end) {
+			break
+		}
+	}
+
+	t.mu.Lock()
+	t.check = n / THROTTLE_CHECKS
+	if t.check < 1 {
+		t.check = 1
+	}
+	t.mu.Unlock()
+	return n
+}
+
+// Wait accounts for n executed instructions and sleeps until the schedule
+// allows more. It blocks while the throttle is paused.
+func (t *Throttle) Wait(n int) {
+	t.mu.Lock()
+	for t.paused {
+		t.resumed.Wait()
+	}
+	t.cycles += uint64(n)
+	var d time.Duration
+	if t.target() > 0 {
+		due := t.base.Add(time.Duration(float64(t.cycles) * float64(time.Second) / t.target()))
+		d = due.Sub(t.now())
+		if d < -THROTTLE_MAX_LAG {
+			t.rebase()
+		}
+	}
+	t.mu.Unlock()
+	if d > 0 {
+		t.sleep(d)
+	}
+
+	t.mu.Lock()
+	t.measure(n)
+	t.mu.Unlock()
+}
+
+func (t *Throttle) measure(n int) {
+	t.windowCycles += uint64(n)
+	now := t.now()
+	if elapsed := now.Sub(t.windowStart); elapsed >= THROTTLE_WINDOW {
+		t.rate = float64(t.windowCycles) / elapsed.Seconds()
+		t.windowStart = now
+		t.windowCycles = 0
+	}
+}
+
+// Rate returns the achieved instructions per second over the last window.
+func (t *Throttle) Rate() float64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.paused {
+		return 0
+	}
+	return t.rate
+}
+
+// Target returns the target frequency including the multiplier.
+func (t *Throttle) Target() float64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.target()
+}
+
+func (t *Throttle) Multiplier() float64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.multiplier
+}
+
+func (t *Throttle) SetMultiplier(m float64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.multiplier = m
+	t.rebase()
+}
+
+func (t *Throttle) Paused() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.paused
+}
+
+func (t *Throttle) Pause() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.paused = true
+}
+
+func (t *Throttle) Resume() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.paused {
+		return
+	}
+	t.paused = false
+	t.rebase()
+	t.windowStart = t.base
+	t.windowCycles = 0
+	t.resumed.Broadcast()
+}
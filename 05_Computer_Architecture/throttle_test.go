@@ -0,0 +1,124 @@
+package computer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeClock advances only when the throttle sleeps or the test says so.
+type fakeClock struct {
+	t     time.Time
+	slept time.Duration
+}
+
+func (c *fakeClock) now() time.Time { return c.t }
+
+func (c *fakeClock) sleep(d time.Duration) {
+	c.t = c.t.Add(d)
+	c.slept += d
+}
+
+func newFakeThrottle(hz float64) (*Throttle, *fakeClock) {
+	c := &fakeClock{t: time.Unix(0, 0)}
+	th := NewThrottle(hz)
+	th.now, th.sleep = c.now, c.sleep
+	th.rebase()
+	th.windowStart = th.base
+	return th, c
+}
+
+func TestThrottlePacing(t *testing.T) {
+	th, c := newFakeThrottle(1e6)
+	assert.Equal(t, 1000, th.Batch())
+	for i := 0; i < 1000; i++ {
+		th.Wait(th.Batch())
+	}
+	assert.Equal(t, time.Second, c.slept, "1M instructions at 1MHz")
+	assert.InDelta(t, 1e6, th.Rate(), 1)
+
+	// Time spent executing counts against the schedule.
+	c.t = c.t.Add(400 * time.Microsecond)
+	c.slept = 0
+	th.Wait(1000)
+	assert.Equal(t, 600*time.Microsecond, c.slept)
+
+	th.SetMultiplier(2)
+	assert.Equal(t, 2000, th.Batch())
+	assert.Equal(t, 2e6, th.Target())
+	c.slept = 0
+	th.Wait(2000)
+	assert.Equal(t, time.Millisecond, c.slept)
+}
+
+func TestThrottleLag(t *testing.T) {
+	th, c := newFakeThrottle(1000)
+	assert.Equal(t, 1, th.Batch())
+	c.t = c.t.Add(time.Second)
+	th.Wait(1)
+	// The schedule was reset, so the next instruction waits a full period
+	// instead of running the missed second in a burst.
+	c.slept = 0
+	th.Wait(1)
+	assert.Equal(t, time.Millisecond, c.slept)
+}
+
+func TestThrottleUnlimited(t *testing.T) {
+	th, c := newFakeThrottle(0)
+	assert.Equal(t, 0, th.Batch())
+	n := th.Run(func() { c.t = c.t.Add(100 * time.Microsecond) })
+	assert.Equal(t, 10, n, "the batch lasts THROTTLE_SLICE")
+	th.Wait(n)
+	assert.Equal(t, time.Duration(0), c.slept)
+}
+
+func TestThrottleRun(t *testing.T) {
+	th, c := newFakeThrottle(1e6)
+	// A slow CPU ends the batch early, but runs one instruction at least.
+	assert.Equal(t, 1, th.Run(func() { c.t = c.t.Add(18 * time.Millisecond) }))
+	assert.Equal(t, 1000, th.Run(func() {}))
+	// After a full batch the clock is read every 1000/THROTTLE_CHECKS
+	// instructions, which bounds the overrun of a CPU turning slow.
+	assert.Equal(t, 125, th.Run(func() { c.t = c.t.Add(18 * time.Millisecond) }))
+}
+
+func TestThrottleRunReadsClockPerChunk(t *testing.T) {
+	th, c := newFakeThrottle(0)
+	reads := 0
+	th.now = func() time.Time {
+		reads++
+		return c.now()
+	}
+	step := func() { c.t = c.t.Add(time.Microsecond) }
+
+	assert.Equal(t, 1000, th.Run(step), "the first batch reads the clock every instruction")
+	reads = 0
+	assert.Equal(t, 1000, th.Run(step))
+	assert.LessOrEqual(t, reads, THROTTLE_CHECKS+1)
+}
+
+func TestThrottlePause(t *testing.T) {
+	th, _ := newFakeThrottle(0)
+	th.Pause()
+	assert.True(t, th.Paused())
+	assert.Equal(t, 0.0, th.Rate())
+
+	done := make(chan struct{})
+	go func() {
+		th.Wait(1)
+		close(done)
+	}()
+	select {
+	case <-done:
+		t.Fatal("Wait returned while paused")
+	case <-time.After(20 * time.Millisecond):
+	}
+	th.Resume()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Wait did not return after Resume")
+	}
+	assert.False(t, th.Paused())
+}
@@ -0,0 +1,142 @@
+package computer
+
+import (
+	logic "github.com/kazufusa/nand2tetris/01_Boolean_Logic"
+)
+
+// Timer registers, relative to TIMER_ADDR.
+const (
+	TIMER_ADDR = KBD_ADDR + 16
+
+	TIMER_CYCLES_LO = 0 // instruction counter, low word; a write resets it
+	TIMER_CYCLES_HI = 1 // instruction counter, high word
+	TIMER_COUNT     = 2 // countdown, decremented every instruction while > 0
+	TIMER_RELOAD    = 3 // value loaded into COUNT when it expires; 0 stops
+	TIMER_CONTROL   = 4 // bit 0 enables the interrupt
+	TIMER_STATUS    = 5 // bit 0 is set when COUNT expires; a write clears it
+	TIMER_IRET      = 6 // a write returns from the interrupt; reads the saved PC
+	TIMER_SIZE      = 7
+
+	// INTERRUPT_VECTOR is the ROM address the timer interrupt jumps to, so a
+	// program using it starts with "@MAIN 0;JMP" followed by its handler.
+	INTERRUPT_VECTOR = 2
+)
+
+// Timer is a cycle counter and countdown timer for a Bus. Once attached to
+// a Computer, an expired countdown with the interrupt enabled saves PC, A
+// and D and jumps to INTERRUPT_VECTOR; a write to TIMER_IRET restores them.
+// Without a Timer the memory map is unchanged.
+type Timer struct {
+	com *Computer
+
+	cycles  uint32
+	count   uint16
+	reload  uint16
+	enabled bool
+	expired bool
+
+	inHandler bool
+	iret      bool
+	savedPC   int
+	savedA    int
+	savedD    int
+}
+
+var (
+	_ IDevice   = (*Timer)(nil)
+	_ IObserver = (*Timer)(nil)
+)
+
+func NewTimer() *Timer {
+	return &Timer{}
+}
+
+// Map attaches the timer registers to b at TIMER_ADDR.
+func (t *Timer) Map(b *Bus) {
+	b.Attach(TIMER_ADDR, TIMER_SIZE, t)
+}
+
+// Attach makes the timer count the instructions of com and interrupt it.
+func (t *Timer) Attach(com *Computer) {
+	t.com = com
+	com.AddObserver(t)
+}
+
+func (t *Timer) Fetch(in Word, load Bit, reg int) (out Word) {
+	out = int2Word(t.read(reg))
+	if load == logic.I {
+		t.write(reg, uint16(word2Int(in)))
+	}
+	return out
+}
+
+func (t *Timer) read(reg int) int {
+	switch reg {
+	case TIMER_CYCLES_LO:
+		return int(int16(t.cycles))
+	case TIMER_CYCLES_HI:
+		return int(int16(t.cycles >> 16))
+	case TIMER_COUNT:
+		return int(int16(t.count))
+	case TIMER_RELOAD:
+		return int(int16(t.reload))
+	case TIMER_CONTROL:
+		if t.enabled {
+			return 1
+		}
+	case TIMER_STATUS:
+		if t.expired {
+			return 1
+		}
+	case TIMER_IRET:
+		return t.savedPC
+	}
+	return 0
+}
+
+func (t *Timer) write(reg int, v uint16) {
+	switch reg {
+	case TIMER_CYCLES_LO, TIMER_CYCLES_HI:
+		t.cycles = 0
+	case TIMER_COUNT:
+		t.count = v
+	case TIMER_RELOAD:
+		t.reload = v
+	case TIMER_CONTROL:
+		t.enabled = v&1 != 0
+	case TIMER_STATUS:
+		t.expired = false
+	case TIMER_IRET:
+		t.iret = true
+	}
+}
+
+// Observe advances the timer by one instruction and dispatches interrupts
+// between instructions.
+func (t *Timer) Observe(e *Event) {
+	t.cycles++
+	if t.count > 0 {
+		t.count--
+		if t.count == 0 {
+			t.expired = true
+			t.count = t.reload
+		}
+	}
+
+	if t.iret {
+		t.iret = false
+		if t.inHandler {
+			t.inHandler = false
+			t.com.SetD(t.savedD)
+			t.com.SetPC(t.savedPC)
+			t.com.SetA(t.savedA)
+		}
+		return
+	}
+	if t.enabled && t.expired && !t.inHandler && t.com != nil {
+		t.expired = false
+		t.inHandler = true
+		t.savedPC, t.savedA, t.savedD = e.NextPC, e.A, e.D
+		t.com.SetPC(INTERRUPT_VECTOR)
+	}
+}
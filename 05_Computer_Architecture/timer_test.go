@@ -0,0 +1,133 @@
+package computer
+
+import (
+	"testing"
+
+	memory "github.com/kazufusa/nand2tetris/03_Memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// timerProgram counts interrupts in RAM[0] from a handler which clobbers A
+// and D, while the main loop keeps incrementing D and storing it in RAM[1].
+var timerProgram = []string{
+	"0000000000000111", // @MAIN
+	"1110101010000111", // 0;JMP
+	"0000000000000000", // @0 (INTERRUPT_VECTOR)
+	"1111110111010000", // D=M+1
+	"1110001100001000", // M=D
+	"0110000000010110", // @TIMER_IRET
+	"1110111111001000", // M=1
+	"0000000000010100", // (MAIN) @20
+	"1110110000010000", // D=A
+	"0110000000010011", // @TIMER_RELOAD
+	"1110001100001000", // M=D
+	"0110000000010010", // @TIMER_COUNT
+	"1110001100001000", // M=D
+	"0110000000010100", // @TIMER_CONTROL
+	"1110111111001000", // M=1
+	"1110011111010000", // (LOOP) D=D+1
+	"0000000000000001", // @1
+	"1110001100001000", // M=D
+	"0000000000001111", // @LOOP
+	"1110101010000111", // 0;JMP
+}
+
+func newTimerComputer(t *testing.T) (*Computer, *Timer) {
+	var inst []Word
+	for _, s := range timerProgram {
+		inst = append(inst, string2Word(s))
+	}
+	clock := memory.Clock(0)
+	ram := NewFastMemory(&TuiScreen{}, &TuiKeyboard{})
+	timer := NewTimer()
+	timer.Map(&ram.Bus)
+	rom := VROM32K{}
+	rom.BulkLoad(inst)
+	cpu := NewFastCPU()
+	com := NewComputer(&cpu, &ram, &rom, &clock)
+	require.Equal(t, TIMER_ADDR+TIMER_IRET, 24598)
+	return &com, timer
+}
+
+// loopChecker verifies that every store of the main loop sees the D it
+// would have without interrupts.
+type loopChecker struct {
+	t     *testing.T
+	d     int
+	loops int
+}
+
+func (c *loopChecker) Observe(e *Event) {
+	switch e.PC {
+	case 15:
+		c.d++
+	case 17:
+		c.loops++
+		assert.Equal(c.t, 1, e.AddressM)
+		assert.Equal(c.t, 20+c.d, e.OutM)
+	}
+}
+
+func TestTimerInterrupt(t *testing.T) {
+	com, timer := newTimerComputer(t)
+	timer.Attach(com)
+	checker := &loopChecker{t: t}
+	com.AddObserver(checker)
+
+	var vectored int
+	com.AddObserver(observerFunc(func(e *Event) {
+		if e.PC == INTERRUPT_VECTOR {
+			vectored++
+		}
+	}))
+	com.Run(1000)
+
+	assert.Equal(t, 1000, com.RAM(TIMER_ADDR+TIMER_CYCLES_LO))
+	assert.Equal(t, 0, com.RAM(TIMER_ADDR+TIMER_CYCLES_HI))
+	assert.Equal(t, 20, com.RAM(TIMER_ADDR+TIMER_RELOAD))
+	assert.Equal(t, 1, com.RAM(TIMER_ADDR+TIMER_CONTROL))
+	assert.Greater(t, vectored, 40)
+	assert.Equal(t, vectored, com.RAM(0))
+	assert.Greater(t, checker.loops, 100)
+
+	com.SetRAM(TIMER_ADDR+TIMER_CYCLES_LO, 0)
+	assert.Equal(t, 0, com.RAM(TIMER_ADDR+TIMER_CYCLES_LO))
+}
+
+type observerFunc func(e *Event)
+
+func (f observerFunc) Observe(e *Event) { f(e) }
+
+func TestTimerPolling(t *testing.T) {
+	com, timer := newTimerComputer(t)
+	com.AddObserver(timer)
+	// Without Attach the timer counts but never interrupts.
+	com.Run(40)
+	assert.Equal(t, 0, com.RAM(0))
+	assert.Equal(t, 1, com.RAM(TIMER_ADDR+TIMER_STATUS))
+	com.SetRAM(TIMER_ADDR+TIMER_STATUS, 0)
+	assert.Equal(t, 0, com.RAM(TIMER_ADDR+TIMER_STATUS))
+
+	com.SetRAM(TIMER_ADDR+TIMER_RELOAD, 0)
+	com.SetRAM(TIMER_ADDR+TIMER_COUNT, 3)
+	com.Run(3)
+	assert.Equal(t, 1, com.RAM(TIMER_ADDR+TIMER_STATUS))
+	assert.Equal(t, 0, com.RAM(TIMER_ADDR+TIMER_COUNT))
+}
+
+func TestTimerMemoryMap(t *testing.T) {
+	kb := &TuiKeyboard{}
+	kb.SetWord(int2Word(65))
+	ram := NewFastMemory(&TuiScreen{}, kb)
+	assert.Equal(t, 65, word2Int(ram.Fetch(Word{}, 0, int2Addr(TIMER_ADDR))))
+	timer := NewTimer()
+	timer.Map(&ram.Bus)
+	timer.cycles = 0x12345
+
+	assert.Equal(t, 65, word2Int(ram.Fetch(Word{}, 0, int2Addr(KBD_ADDR))))
+	assert.Equal(t, 0x2345, word2Int(ram.Fetch(Word{}, 0, int2Addr(TIMER_ADDR))))
+	assert.Equal(t, 1, word2Int(ram.Fetch(Word{}, 0, int2Addr(TIMER_ADDR+1))))
+	// Outside the timer the unused range still reads the keyboard.
+	assert.Equal(t, 65, word2Int(ram.Fetch(Word{}, 0, int2Addr(TIMER_ADDR+TIMER_SIZE))))
+}
@@ -0,0 +1,149 @@
+package computer
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+)
+
+type TraceFormat int
+
+const (
+	// TRACE_TEXT writes one line per instruction.
+	TRACE_TEXT TraceFormat = iota
+	// TRACE_BINARY writes one TraceRecord per instruction.
+	TRACE_BINARY
+)
+
+const (
+	TRACE_READ_M  = 1 << iota // the instruction read M
+	TRACE_WRITE_M             // the instruction wrote M
+	TRACE_JUMP                // the jump was taken
+	TRACE_RESET               // reset was asserted
+)
+
+// TraceRecord is the big-endian binary form of a traced instruction.
+// InAddr and InM are the address and value of the M read, if TRACE_READ_M,
+// and OutAddr and OutM those of the M write, if TRACE_WRITE_M. An
+// instruction such as M=M+1 sets both.
+type TraceRecord struct {
+	Cycle   uint32
+	PC      uint16
+	Inst    uint16
+	A, D    uint16
+	InAddr  uint16
+	InM     uint16
+	OutAddr uint16
+	OutM    uint16
+	Flags   uint8
+}
+
+// Tracer writes every executed instruction. Register it with
+// Computer.AddObserver and call Flush when done.
+type Tracer struct {
+	w      *bufio.Writer
+	format TraceFormat
+
+	from, to int
+	limit    int
+	cycle    int
+	err      error
+}
+
+func NewTracer(w io.Writer, format TraceFormat) *Tracer {
+	return &Tracer{w: bufio.NewWriter(w), format: format, to: SNAPSHOT_ROM_SIZE - 1}
+}
+
+// SetRange restricts the trace to instructions at ROM addresses from..to
+// inclusive.
+func (t *Tracer) SetRange(from, to int) {
+	t.from, t.to = from, to
+}
+
+// SetLimit stops the trace after the first n cycles. 0 means no limit.
+func (t *Tracer) SetLimit(n int) {
+	t.limit = n
+}
+
+func (t *Tracer) Observe(e *Event) {
+	cycle := t.cycle
+	t.cycle++
+	if t.err != nil || (t.limit > 0 && cycle >= t.limit) || e.PC < t.from || e.PC > t.to {
+		return
+	}
+
+	r := TraceRecord{
+		Cycle: uint32(cycle),
+		PC:    uint16(e.PC),
+		Inst:  uint16(e.Inst),
+		A:     uint16(e.A),
+		D:     uint16(e.D),
+	}
+	if readsM(e.Inst) {
+		r.Flags |= TRACE_READ_M
+		r.InAddr, r.InM = uint16(e.InAddressM), uint16(e.InM)
+	}
+	if e.WriteM {
+		r.Flags |= TRACE_WRITE_M
+		r.OutAddr, r.OutM = uint16(e.AddressM), uint16(e.OutM)
+	}
+	if jumps(e.Inst, e.OutM) {
+		r.Flags |= TRACE_JUMP
+	}
+	if e.Reset {
+		r.Flags |= TRACE_RESET
+	}
+
+	if t.format == TRACE_BINARY {
+		t.err = binary.Write(t.w, binary.BigEndian, r)
+		return
+	}
+	_, t.err = fmt.Fprintln(t.w, formatTrace(e, r))
+}
+
+// formatTrace renders r as "cycle pc asm A D M-accesses flags". M reads are
+// shown as "M[addr]->value" and writes as "M[addr]=value".
+func formatTrace(e *Event, r TraceRecord) string {
+	s := fmt.Sprintf("%8d %5d  %-12s A=%-6d D=%-6d", r.Cycle, r.PC, Disassemble(e.Inst), e.A, e.D)
+	switch {
+	case r.Flags&TRACE_WRITE_M != 0 && r.Flags&TRACE_READ_M != 0:
+		s += fmt.Sprintf(" M[%d]->%d M[%d]=%d", e.InAddressM, e.InM, e.AddressM, e.OutM)
+	case r.Flags&TRACE_WRITE_M != 0:
+		s += fmt.Sprintf(" M[%d]=%d", e.AddressM, e.OutM)
+	case r.Flags&TRACE_READ_M != 0:
+		s += fmt.Sprintf(" M[%d]->%d", e.InAddressM, e.InM)
+	}
+	if r.Flags&TRACE_JUMP != 0 {
+		s += fmt.Sprintf(" jump %d", e.NextPC)
+	}
+	if r.Flags&TRACE_RESET != 0 {
+		s += " reset"
+	}
+	return strings.TrimRight(s, " ")
+}
+
+// Flush writes buffered records and returns the first write error.
+func (t *Tracer) Flush() error {
+	if t.err != nil {
+		return t.err
+	}
+	return t.w.Flush()
+}
+
+// ReadTrace reads the records of a binary trace until EOF.
+func ReadTrace(r io.Reader) ([]TraceRecord, error) {
+	var records []TraceRecord
+	for {
+		var rec TraceRecord
+		err := binary.Read(r, binary.BigEndian, &rec)
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return records, err
+		}
+		records = append(records, rec)
+	}
+}
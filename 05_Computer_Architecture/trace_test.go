@@ -0,0 +1,74 @@
+package computer
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	logic "github.com/kazufusa/nand2tetris/01_Boolean_Logic"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTracer(t *testing.T) {
+	com, _, _ := newFastComputer(maxInstructions)
+	com.SetRAM(0, 6)
+	com.SetRAM(1, 10)
+	var buf bytes.Buffer
+	tr := NewTracer(&buf, TRACE_TEXT)
+	com.AddObserver(tr)
+	com.Run(1000)
+	require.NoError(t, tr.Flush())
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	require.Len(t, lines, 12)
+	assert.Equal(t, "       0     0  @0           A=0      D=0", lines[0])
+	assert.Equal(t, "       1     1  D=M          A=0      D=6      M[0]->6", lines[1])
+	assert.Equal(t, "       5     5  D;JGT        A=10     D=-4", lines[5])
+	assert.Equal(t, "       9     9  0;JMP        A=12     D=10     jump 12", lines[9])
+	assert.Equal(t, "      11    13  M=D          A=2      D=10     M[2]=10", lines[11])
+}
+
+func TestTracerFilters(t *testing.T) {
+	com, _, _ := newFastComputer(maxInstructions)
+	com.SetRAM(0, 6)
+	com.SetRAM(1, 10)
+	var buf bytes.Buffer
+	tr := NewTracer(&buf, TRACE_BINARY)
+	tr.SetRange(6, 13)
+	tr.SetLimit(11)
+	com.AddObserver(tr)
+	com.Run(1000)
+	require.NoError(t, tr.Flush())
+
+	records, err := ReadTrace(&buf)
+	require.NoError(t, err)
+	require.Len(t, records, 5)
+	assert.Equal(t, TraceRecord{Cycle: 6, PC: 6, Inst: 1, A: 1, D: uint16(0xfffc)}, records[0])
+	assert.Equal(t, TraceRecord{Cycle: 7, PC: 7, Inst: 0xfc10, A: 1, D: 10, InAddr: 1, InM: 10, Flags: TRACE_READ_M}, records[1])
+	assert.Equal(t, TraceRecord{Cycle: 9, PC: 9, Inst: 0xea87, A: 12, D: 10, Flags: TRACE_JUMP}, records[3])
+	assert.Equal(t, uint16(12), records[4].PC)
+}
+
+func TestTracerReadModifyWrite(t *testing.T) {
+	com, _, _ := newFastComputer([]Word{
+		int2Word(5),                     // @5
+		string2Word("1111110111001000"), // M=M+1
+	})
+	com.SetRAM(5, 41)
+	var buf bytes.Buffer
+	tr := NewTracer(&buf, TRACE_BINARY)
+	com.AddObserver(tr)
+	com.FetchAndExecute(logic.O)
+	com.FetchAndExecute(logic.O)
+	require.NoError(t, tr.Flush())
+
+	records, err := ReadTrace(&buf)
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	assert.Equal(t, TraceRecord{
+		Cycle: 1, PC: 1, Inst: 0xfdc8, A: 5, D: 0,
+		InAddr: 5, InM: 41, OutAddr: 5, OutM: 42,
+		Flags: TRACE_READ_M | TRACE_WRITE_M,
+	}, records[1])
+}
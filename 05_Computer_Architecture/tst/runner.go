@@ -0,0 +1,453 @@
+package tst
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// column is one entry of output-list, e.g. RAM[0]%D2.6.2.
+type column struct {
+	name               string
+	format             byte
+	left, width, right int
+}
+
+// ComparisonError reports the first output line which differs from the
+// compare-to file.
+type ComparisonError struct {
+	Line             int
+	Expected, Actual string
+}
+
+func (e *ComparisonError) Error() string {
+	return fmt.Sprintf("Comparison failure at line %d\nexpected: %s\nactual:   %s", e.Line, e.Expected, e.Actual)
+}
+
+// Runner executes a test script.
+type Runner struct {
+	// Dir is the directory relative to which load, output-file and
+	// compare-to resolve their arguments.
+	Dir string
+	// Output, if set, receives the output table instead of output-file.
+	Output io.Writer
+	// Echo receives the text of echo commands.
+	Echo io.Writer
+
+	target  Target
+	out     io.Writer
+	file    *os.File
+	columns []column
+	cmp     []string
+	lines   int
+	time    int
+	ticked  bool
+}
+
+// RunFile runs the script at p, writing output-file next to it.
+func RunFile(p string) error {
+	r := &Runner{Dir: filepath.Dir(p), Echo: os.Stdout}
+	return r.RunFile(p)
+}
+
+// RunTest runs the script at p and fails t when the output differs from
+// compare-to. The output is kept in memory.
+func RunTest(t testing.TB, p string) {
+	t.Helper()
+	var out bytes.Buffer
+	r := &Runner{Dir: filepath.Dir(p), Output: &out, Echo: ioutil.Discard}
+	if err := r.RunFile(p); err != nil {
+		t.Fatalf("%v\n%s", err, out.String())
+	}
+}
+
+func (r *Runner) RunFile(p string) error {
+	b, err := ioutil.ReadFile(p)
+	if err != nil {
+		return err
+	}
+	stmts, err := Parse(string(b))
+	if err != nil {
+		return fmt.Errorf("%s: %v", p, err)
+	}
+	defer r.close()
+	if err := r.run(stmts); err != nil {
+		return fmt.Errorf("%s: %v", p, err)
+	}
+	if r.lines < len(r.cmp) {
+		err := &ComparisonError{Line: r.lines + 1, Expected: r.cmp[r.lines]}
+		return fmt.Errorf("%s: output ended early: %v", p, err)
+	}
+	return r.close()
+}
+
+func (r *Runner) close() error {
+	if r.file == nil {
+		return nil
+	}
+	f := r.file
+	r.file = nil
+	return f.Close()
+}
+
+func (r *Runner) path(p string) string {
+	if filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(r.Dir, p)
+}
+
+func (r *Runner) run(stmts []Statement) error {
+	for _, stmt := range stmts {
+		switch s := stmt.(type) {
+		case *Command:
+			if err := r.command(s); err != nil {
+				return fmt.Errorf("line %d: %v", s.Line, err)
+			}
+		case *Repeat:
+			for i := 0; s.Count <= 0 || i < s.Count; i++ {
+				if err := r.run(s.Body); err != nil {
+					return err
+				}
+			}
+		case *While:
+			for {
+				ok, err := r.condition(s)
+				if err != nil {
+					return fmt.Errorf("line %d: %v", s.Line, err)
+				}
+				if !ok {
+					break
+				}
+				if err := r.run(s.Body); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (r *Runner) command(c *Command) error {
+	arg := func(n int) error {
+		if len(c.Args) != n {
+			return fmt.Errorf("%s expects %d argument(s)", c.Name, n)
+		}
+		return nil
+	}
+
+	switch c.Name {
+	case "load":
+		if err := arg(1); err != nil {
+			return err
+		}
+		var err error
+		switch filepath.Ext(c.Args[0]) {
+		case ".hack", ".asm":
+			r.target, err = NewComputerTarget(r.path(c.Args[0]))
+		default:
+			r.target, err = NewTarget(c.Args[0])
+		}
+		return err
+	case "ROM32K":
+		if err := arg(2); err != nil {
+			return err
+		}
+		com, ok := r.target.(*ComputerTarget)
+		if !ok || c.Args[0] != "load" {
+			return fmt.Errorf("unknown command %s %s", c.Name, c.Args[0])
+		}
+		return com.Load(r.path(c.Args[1]))
+	case "output-file":
+		if err := arg(1); err != nil {
+			return err
+		}
+		if r.Output != nil {
+			r.out = r.Output
+			return nil
+		}
+		if err := r.close(); err != nil {
+			return err
+		}
+		f, err := os.Create(r.path(c.Args[0]))
+		if err != nil {
+			return err
+		}
+		r.file, r.out = f, f
+		return nil
+	case "compare-to":
+		if err := arg(1); err != nil {
+			return err
+		}
+		b, err := ioutil.ReadFile(r.path(c.Args[0]))
+		if err != nil {
+			return err
+		}
+		r.cmp = nil
+		sc := bufio.NewScanner(bytes.NewReader(b))
+		for sc.Scan() {
+			r.cmp = append(r.cmp, strings.TrimRight(sc.Text(), "\r"))
+		}
+		// Blank lines at the end are not expected output.
+		for len(r.cmp) > 0 && r.cmp[len(r.cmp)-1] == "" {
+			r.cmp = r.cmp[:len(r.cmp)-1]
+		}
+		return sc.Err()
+	case "output-list":
+		r.columns = nil
+		for _, a := range c.Args {
+			col, err := parseColumn(a)
+			if err != nil {
+				return err
+			}
+			r.columns = append(r.columns, col)
+		}
+		return r.emit(r.header())
+	case "output":
+		if err := arg(0); err != nil {
+			return err
+		}
+		line, err := r.row()
+		if err != nil {
+			return err
+		}
+		return r.emit(line)
+	case "set":
+		if err := arg(2); err != nil {
+			return err
+		}
+		if r.target == nil {
+			return fmt.Errorf("nothing is loaded")
+		}
+		v, err := parseValue(c.Args[1])
+		if err != nil {
+			return err
+		}
+		return r.target.Set(c.Args[0], v)
+	case "eval", "tick", "tock", "ticktock":
+		if err := arg(0); err != nil {
+			return err
+		}
+		if r.target == nil {
+			return fmt.Errorf("nothing is loaded")
+		}
+		switch c.Name {
+		case "eval":
+			r.target.Eval()
+		case "tick":
+			r.tick()
+		case "tock":
+			r.tock()
+		case "ticktock":
+			r.tick()
+			r.tock()
+		}
+		return nil
+	case "echo":
+		if r.Echo != nil {
+			fmt.Fprintln(r.Echo, strings.Trim(strings.Join(c.Args, " "), `"`))
+		}
+		return nil
+	case "clear-echo", "breakpoint", "clear-breakpoints":
+		return nil
+	}
+	return fmt.Errorf("unknown command %s", c.Name)
+}
+
+func (r *Runner) tick() {
+	r.target.Tick()
+	r.ticked = true
+}
+
+func (r *Runner) tock() {
+	r.target.Tock()
+	r.time++
+	r.ticked = false
+}
+
+// timeString formats the clock as the simulators do: "3" after a tock and
+// "3+" after a tick.
+func (r *Runner) timeString() string {
+	if r.ticked {
+		return strconv.Itoa(r.time) + "+"
+	}
+	return strconv.Itoa(r.time)
+}
+
+func (r *Runner) condition(w *While) (bool, error) {
+	left, err := r.operand(w.Left)
+	if err != nil {
+		return false, err
+	}
+	right, err := r.operand(w.Right)
+	if err != nil {
+		return false, err
+	}
+	switch w.Op {
+	case "=":
+		return left == right, nil
+	case "<>":
+		return left != right, nil
+	case "<":
+		return left < right, nil
+	case ">":
+		return left > right, nil
+	case "<=":
+		return left <= right, nil
+	case ">=":
+		return left >= right, nil
+	}
+	return false, fmt.Errorf("unknown operator %s", w.Op)
+}
+
+func (r *Runner) operand(s string) (int, error) {
+	if v, err := parseValue(s); err == nil {
+		return v, nil
+	}
+	if r.target == nil {
+		return 0, fmt.Errorf("nothing is loaded")
+	}
+	return r.target.Get(s)
+}
+
+func (r *Runner) header() string {
+	var b strings.Builder
+	b.WriteString("|")
+	for _, c := range r.columns {
+		n := c.left + c.width + c.right
+		name := c.name
+		if len(name) > n {
+			name = name[:n]
+		}
+		l := (n - len(name)) / 2
+		b.WriteString(strings.Repeat(" ", l) + name + strings.Repeat(" ", n-l-len(name)) + "|")
+	}
+	return b.String()
+}
+
+func (r *Runner) row() (string, error) {
+	var b strings.Builder
+	b.WriteString("|")
+	for _, c := range r.columns {
+		var s string
+		if c.name == "time" {
+			s = r.timeString()
+		} else {
+			if r.target == nil {
+				return "", fmt.Errorf("nothing is loaded")
+			}
+			v, err := r.target.Get(c.name)
+			if err != nil {
+				return "", err
+			}
+			s = formatValue(v, c.format)
+		}
+		b.WriteString(strings.Repeat(" ", c.left) + fit(s, c.width, c.format) + strings.Repeat(" ", c.right) + "|")
+	}
+	return b.String(), nil
+}
+
+func (r *Runner) emit(line string) error {
+	r.lines++
+	if r.out != nil {
+		if _, err := fmt.Fprintln(r.out, line); err != nil {
+			return err
+		}
+	}
+	if r.cmp == nil {
+		return nil
+	}
+	expected := ""
+	if r.lines <= len(r.cmp) {
+		expected = r.cmp[r.lines-1]
+	}
+	if expected != line {
+		return &ComparisonError{Line: r.lines, Expected: expected, Actual: line}
+	}
+	return nil
+}
+
+// parseColumn parses NAME%Fl.w.r; without a format %B1.16.1 is used.
+func parseColumn(s string) (column, error) {
+	i := strings.Index(s, "%")
+	if i < 0 {
+		if s == "time" {
+			return column{name: s, format: 'S', left: 1, width: 4, right: 1}, nil
+		}
+		return column{name: s, format: 'B', left: 1, width: 16, right: 1}, nil
+	}
+	c := column{name: s[:i]}
+	spec := s[i+1:]
+	if len(spec) < 2 || !strings.ContainsRune("BDXS", rune(spec[0])) {
+		return c, fmt.Errorf("invalid output format %q", s)
+	}
+	c.format = spec[0]
+	parts := strings.Split(spec[1:], ".")
+	if len(parts) != 3 {
+		return c, fmt.Errorf("invalid output format %q", s)
+	}
+	var ns [3]int
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return c, fmt.Errorf("invalid output format %q", s)
+		}
+		ns[i] = n
+	}
+	c.left, c.width, c.right = ns[0], ns[1], ns[2]
+	return c, nil
+}
+
+// parseValue parses decimal numbers and the %B, %X and %D literals.
+func parseValue(s string) (int, error) {
+	base := 10
+	switch {
+	case strings.HasPrefix(s, "%B"):
+		base, s = 2, s[2:]
+	case strings.HasPrefix(s, "%X"):
+		base, s = 16, s[2:]
+	case strings.HasPrefix(s, "%D"):
+		s = s[2:]
+	}
+	v, err := strconv.ParseInt(s, base, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q", s)
+	}
+	if base != 10 {
+		v = int64(int16(v))
+	}
+	return int(v), nil
+}
+
+func formatValue(v int, format byte) string {
+	switch format {
+	case 'B':
+		return fmt.Sprintf("%016b", uint16(v))
+	case 'X':
+		return fmt.Sprintf("%04X", uint16(v))
+	case 'S':
+		return strconv.Itoa(v)
+	}
+	return strconv.Itoa(int(int16(v)))
+}
+
+// fit right-aligns numbers and left-aligns strings in width characters.
+// Binary and hexadecimal values keep their least significant digits.
+func fit(s string, width int, format byte) string {
+	switch {
+	case len(s) > width && (format == 'B' || format == 'X'):
+		return s[len(s)-width:]
+	case len(s) >= width:
+		return s
+	case format == 'S':
+		return s + strings.Repeat(" ", width-len(s))
+	}
+	return strings.Repeat(" ", width-len(s)) + s
+}
@@ -0,0 +1,122 @@
+package tst
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunTest(t *testing.T) {
+	for _, name := range []string{"Max", "And", "Bit", "RAM8", "CPU", "Memory", "ComputerAdd"} {
+		name := name
+		t.Run(name, func(t *testing.T) {
+			RunTest(t, filepath.Join("testdata", name+".tst"))
+		})
+	}
+}
+
+func TestRunFileWritesOutput(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tst")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	for _, f := range []string{"Max.tst", "Max.asm", "Max.cmp"} {
+		b, err := ioutil.ReadFile(filepath.Join("testdata", f))
+		require.NoError(t, err)
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, f), b, 0644))
+	}
+
+	require.NoError(t, RunFile(filepath.Join(dir, "Max.tst")))
+	out, err := ioutil.ReadFile(filepath.Join(dir, "Max.out"))
+	require.NoError(t, err)
+	assert.Equal(t, "|  RAM[0]  |  RAM[1]  |  RAM[2]  |\n|       3  |       5  |       5  |\n|      23  |       4  |      23  |\n", string(out))
+}
+
+func TestRunnerComparisonFailure(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tst")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "Or.tst"), []byte(`
+load Or.hdl, compare-to Or.cmp, output-list a%B1.1.1 b%B1.1.1 out%B1.1.1;
+set a 1, set b 0, eval, output;
+`), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "Or.cmp"), []byte("| a | b |out|\n| 1 | 0 | 0 |\n"), 0644))
+
+	var out bytes.Buffer
+	r := &Runner{Dir: dir, Output: &out}
+	err = r.RunFile(filepath.Join(dir, "Or.tst"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "line 3: Comparison failure at line 2")
+	assert.Contains(t, err.Error(), "actual:   | 1 | 0 | 1 |")
+}
+
+func TestRunnerMissingOutput(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tst")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "Not.tst"), []byte(`
+load Not.hdl, compare-to Not.cmp, output-list in%B1.1.1 out%B1.1.1;
+set in 0, eval, output;
+`), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "Not.cmp"), []byte("|in |out|\n| 0 | 1 |\n| 1 | 0 |\n\n"), 0644))
+
+	r := &Runner{Dir: dir, Output: ioutil.Discard}
+	err = r.RunFile(filepath.Join(dir, "Not.tst"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "output ended early: Comparison failure at line 3\nexpected: | 1 | 0 |")
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "Not.tst"), []byte(`
+load Not.hdl, compare-to Not.cmp, output-list in%B1.1.1 out%B1.1.1;
+set in 0, eval, output;
+set in 1, eval, output;
+ROM32K load Add.hack;
+`), 0644))
+	r = &Runner{Dir: dir, Output: ioutil.Discard}
+	err = r.RunFile(filepath.Join(dir, "Not.tst"))
+	assert.Contains(t, err.Error(), "line 5: unknown command ROM32K load")
+}
+
+func TestParseColumn(t *testing.T) {
+	var tests = []struct {
+		given    string
+		expected column
+	}{
+		{"RAM[0]%D2.6.2", column{"RAM[0]", 'D', 2, 6, 2}},
+		{"out%B3.1.3", column{"out", 'B', 3, 1, 3}},
+		{"out", column{"out", 'B', 1, 16, 1}},
+		{"time", column{"time", 'S', 1, 4, 1}},
+	}
+	for _, tt := range tests {
+		actual, err := parseColumn(tt.given)
+		require.NoError(t, err)
+		assert.Equal(t, tt.expected, actual)
+	}
+	_, err := parseColumn("out%Q1.1.1")
+	assert.Error(t, err)
+	_, err = parseColumn("out%B1.1")
+	assert.Error(t, err)
+}
+
+func TestFormat(t *testing.T) {
+	assert.Equal(t, "    -1", fit(formatValue(65535, 'D'), 6, 'D'))
+	assert.Equal(t, "1111111111111111", fit(formatValue(-1, 'B'), 16, 'B'))
+	assert.Equal(t, "01", fit(formatValue(5, 'B'), 2, 'B'))
+	assert.Equal(t, "00FF", fit(formatValue(255, 'X'), 4, 'X'))
+	assert.Equal(t, "3+  ", fit("3+", 4, 'S'))
+}
+
+func TestChips(t *testing.T) {
+	for _, name := range Chips() {
+		c, err := NewTarget(name + ".hdl")
+		require.NoError(t, err, name)
+		c.Eval()
+		c.Tick()
+		c.Tock()
+	}
+	_, err := NewTarget("Screen.hdl")
+	assert.EqualError(t, err, "unknown chip Screen")
+}
@@ -0,0 +1,218 @@
+// Package tst runs the .tst test scripts of the nand2tetris simulators
+// against the chips and the computer of this repository.
+package tst
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Command is a simple script command such as "set RAM[0] 3" or "ticktock".
+type Command struct {
+	Name string
+	Args []string
+	Line int
+}
+
+// Repeat runs Body Count times. A Count of 0 or less repeats forever.
+type Repeat struct {
+	Count int
+	Body  []Statement
+	Line  int
+}
+
+// While runs Body while the condition "Left Op Right" holds.
+type While struct {
+	Left, Op, Right string
+	Body            []Statement
+	Line            int
+}
+
+// Statement is one of *Command, *Repeat and *While.
+type Statement interface{}
+
+type token struct {
+	text string
+	line int
+}
+
+// Parse parses a test script.
+func Parse(src string) ([]Statement, error) {
+	toks, err := tokenize(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{toks: toks}
+	stmts, err := p.block(false)
+	if err != nil {
+		return nil, err
+	}
+	return stmts, nil
+}
+
+func tokenize(src string) ([]token, error) {
+	var toks []token
+	line := 1
+	rs := []rune(src)
+	for i := 0; i < len(rs); {
+		r := rs[i]
+		switch {
+		case r == '\n':
+			line++
+			i++
+		case unicode.IsSpace(r):
+			i++
+		case r == '/' && i+1 < len(rs) && rs[i+1] == '/':
+			for i < len(rs) && rs[i] != '\n' {
+				i++
+			}
+		case r == '/' && i+1 < len(rs) && rs[i+1] == '*':
+			j := i + 2
+			for ; j+1 < len(rs) && !(rs[j] == '*' && rs[j+1] == '/'); j++ {
+				if rs[j] == '\n' {
+					line++
+				}
+			}
+			if j+1 >= len(rs) {
+				return nil, fmt.Errorf("line %d: unterminated comment", line)
+			}
+			i = j + 2
+		case r == '"':
+			j := i + 1
+			for j < len(rs) && rs[j] != '"' && rs[j] != '\n' {
+				j++
+			}
+			if j == len(rs) || rs[j] != '"' {
+				return nil, fmt.Errorf("line %d: unterminated string", line)
+			}
+			toks = append(toks, token{string(rs[i : j+1]), line})
+			i = j + 1
+		case strings.ContainsRune(",;!{}", r):
+			toks = append(toks, token{string(r), line})
+			i++
+		default:
+			j := i
+			for j < len(rs) && !unicode.IsSpace(rs[j]) && !strings.ContainsRune(",;!{}\"", rs[j]) &&
+				!(rs[j] == '/' && j+1 < len(rs) && (rs[j+1] == '/' || rs[j+1] == '*')) {
+				j++
+			}
+			toks = append(toks, token{string(rs[i:j]), line})
+			i = j
+		}
+	}
+	return toks, nil
+}
+
+type parser struct {
+	toks []token
+	pos  int
+}
+
+func (p *parser) peek() (token, bool) {
+	if p.pos < len(p.toks) {
+		return p.toks[p.pos], true
+	}
+	return token{}, false
+}
+
+func (p *parser) next() (token, bool) {
+	t, ok := p.peek()
+	if ok {
+		p.pos++
+	}
+	return t, ok
+}
+
+func (p *parser) block(nested bool) ([]Statement, error) {
+	var stmts []Statement
+	for {
+		t, ok := p.next()
+		if !ok {
+			if nested {
+				return nil, fmt.Errorf("missing '}'")
+			}
+			return stmts, nil
+		}
+		switch t.text {
+		case "}":
+			if !nested {
+				return nil, fmt.Errorf("line %d: unexpected '}'", t.line)
+			}
+			return stmts, nil
+		case ",", ";", "!":
+			continue
+		case "repeat":
+			stmt, err := p.repeat(t)
+			if err != nil {
+				return nil, err
+			}
+			stmts = append(stmts, stmt)
+		case "while":
+			stmt, err := p.while(t)
+			if err != nil {
+				return nil, err
+			}
+			stmts = append(stmts, stmt)
+		default:
+			cmd := &Command{Name: t.text, Line: t.line}
+			for {
+				a, ok := p.peek()
+				if !ok || strings.Contains(",;!{}", a.text) {
+					break
+				}
+				p.pos++
+				cmd.Args = append(cmd.Args, a.text)
+			}
+			if a, ok := p.next(); !ok || !strings.Contains(",;!", a.text) {
+				return nil, fmt.Errorf("line %d: %s must end with ',', ';' or '!'", t.line, t.text)
+			}
+			stmts = append(stmts, cmd)
+		}
+	}
+}
+
+func (p *parser) repeat(t token) (Statement, error) {
+	r := &Repeat{Line: t.line}
+	a, ok := p.next()
+	if ok && a.text != "{" {
+		n, err := strconv.Atoi(a.text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid repeat count %q", a.line, a.text)
+		}
+		r.Count = n
+		a, ok = p.next()
+	}
+	if !ok || a.text != "{" {
+		return nil, fmt.Errorf("line %d: repeat needs '{'", t.line)
+	}
+	body, err := p.block(true)
+	if err != nil {
+		return nil, err
+	}
+	r.Body = body
+	return r, nil
+}
+
+func (p *parser) while(t token) (Statement, error) {
+	var cond []string
+	for {
+		a, ok := p.next()
+		if !ok {
+			return nil, fmt.Errorf("line %d: while needs '{'", t.line)
+		}
+		if a.text == "{" {
+			break
+		}
+		cond = append(cond, a.text)
+	}
+	if len(cond) != 3 {
+		return nil, fmt.Errorf("line %d: while needs a condition \"X op Y\"", t.line)
+	}
+	body, err := p.block(true)
+	if err != nil {
+		return nil, err
+	}
+	return &While{Left: cond[0], Op: cond[1], Right: cond[2], Body: body, Line: t.line}, nil
+}
@@ -0,0 +1,49 @@
+package tst
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	stmts, err := Parse(`// comment
+load Max.hack, /* block
+comment */ output-list RAM[0]%D2.6.2 RAM[1]%D2.6.2;
+repeat 2 {
+  ticktock;
+}
+while RAM[0] <> 0 { tick, tock; }
+echo "hello world";
+`)
+	require.NoError(t, err)
+	assert.Equal(t, []Statement{
+		&Command{Name: "load", Args: []string{"Max.hack"}, Line: 2},
+		&Command{Name: "output-list", Args: []string{"RAM[0]%D2.6.2", "RAM[1]%D2.6.2"}, Line: 3},
+		&Repeat{Count: 2, Body: []Statement{&Command{Name: "ticktock", Line: 5}}, Line: 4},
+		&While{Left: "RAM[0]", Op: "<>", Right: "0", Body: []Statement{
+			&Command{Name: "tick", Line: 7},
+			&Command{Name: "tock", Line: 7},
+		}, Line: 7},
+		&Command{Name: "echo", Args: []string{`"hello world"`}, Line: 8},
+	}, stmts)
+}
+
+func TestParseErrors(t *testing.T) {
+	var tests = []struct {
+		given    string
+		expected string
+	}{
+		{"load And.hdl", "line 1: load must end with ',', ';' or '!'"},
+		{"repeat 3 { tick;", "missing '}'"},
+		{"repeat x { tick; }", `line 1: invalid repeat count "x"`},
+		{"tick; }", "line 1: unexpected '}'"},
+		{"while a { tick; }", `line 1: while needs a condition "X op Y"`},
+		{"/* open", "line 1: unterminated comment"},
+	}
+	for _, tt := range tests {
+		_, err := Parse(tt.given)
+		assert.EqualError(t, err, tt.expected, tt.given)
+	}
+}
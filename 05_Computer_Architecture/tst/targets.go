@@ -0,0 +1,592 @@
+package tst
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	logic "github.com/kazufusa/nand2tetris/01_Boolean_Logic"
+	arithmetic "github.com/kazufusa/nand2tetris/02_Boolean_Arithmetic"
+	memory "github.com/kazufusa/nand2tetris/03_Memory"
+	computer "github.com/kazufusa/nand2tetris/05_Computer_Architecture"
+	assembler "github.com/kazufusa/nand2tetris/06_Assembler"
+)
+
+type Bit = logic.Bit
+
+type Word = [16]Bit
+
+// Target is the device a script is driving.
+type Target interface {
+	Get(name string) (int, error)
+	Set(name string, v int) error
+	Eval()
+	Tick()
+	Tock()
+}
+
+var reIndexed = regexp.MustCompile(`^(\w+)\[(\d+)\]$`)
+
+// ComputerTarget runs a Hack program like the CPU Emulator does, or drives
+// the Computer chip with its reset pin.
+type ComputerTarget struct {
+	com   *computer.Computer
+	reset Bit
+	// size is the length of the loaded program.
+	size int
+}
+
+var _ Target = (*ComputerTarget)(nil)
+
+// NewComputerTarget loads a .hack or .asm program into a word-level Computer.
+func NewComputerTarget(p string) (*ComputerTarget, error) {
+	clock := memory.Clock(0)
+	sc := computer.TuiScreen{}
+	kb := computer.TuiKeyboard{}
+	ram := computer.NewFastMemory(&sc, &kb)
+	rom := computer.VROM32K{}
+	cpu := computer.NewFastCPU()
+	com := computer.NewComputer(&cpu, &ram, &rom, &clock)
+	c := &ComputerTarget{com: &com}
+	if err := c.Load(p); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// NewComputerChipTarget returns the gate-level Computer of "load
+// Computer.hdl", whose program is loaded by "ROM32K load FILE".
+func NewComputerChipTarget() *ComputerTarget {
+	clock := memory.Clock(0)
+	sc := computer.TuiScreen{}
+	kb := computer.TuiKeyboard{}
+	ram := computer.NewMemory(&clock, &sc, &kb)
+	rom := computer.NewROM32K()
+	cpu := computer.NewCPU()
+	com := computer.NewComputer(&cpu, &ram, &rom, &clock)
+	return &ComputerTarget{com: &com}
+}
+
+// Load replaces the program in ROM with a .hack or .asm file.
+func (c *ComputerTarget) Load(p string) error {
+	var hack string
+	switch filepath.Ext(p) {
+	case ".asm":
+		asm, err := assembler.NewAssembler(p)
+		if err != nil {
+			return err
+		}
+		if hack, err = asm.Assemble(); err != nil {
+			return err
+		}
+	case ".hack":
+		b, err := ioutil.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		hack = strings.ReplaceAll(string(b), "\r", "")
+	default:
+		return fmt.Errorf("cannot load %s into the computer", p)
+	}
+
+	var prog []int
+	for i, l := range strings.Split(strings.TrimSpace(hack), "\n") {
+		v, err := strconv.ParseUint(l, 2, 16)
+		if err != nil {
+			return fmt.Errorf("%s:%d: %v", p, i+1, err)
+		}
+		prog = append(prog, int(int16(v)))
+	}
+	for i := len(prog); i < c.size; i++ {
+		c.com.SetROM(i, 0)
+	}
+	for i, v := range prog {
+		c.com.SetROM(i, v)
+	}
+	c.size = len(prog)
+	return nil
+}
+
+// trimRegister drops the "[]" or "[0]" with which scripts name the internal
+// registers of a chip, as in DRegister[] or ARegister[0].
+func trimRegister(name string) string {
+	return strings.TrimSuffix(strings.TrimSuffix(name, "[]"), "[0]")
+}
+
+// register maps the names of the registers to A, D and PC.
+func register(name string) string {
+	switch trimRegister(name) {
+	case "A", "ARegister":
+		return "A"
+	case "D", "DRegister":
+		return "D"
+	case "PC":
+		return "PC"
+	}
+	return ""
+}
+
+func (c *ComputerTarget) Get(name string) (int, error) {
+	switch register(name) {
+	case "A":
+		return c.com.A(), nil
+	case "D":
+		return c.com.D(), nil
+	case "PC":
+		return c.com.PC(), nil
+	}
+	if name == "reset" {
+		return int(c.reset), nil
+	}
+	if m := reIndexed.FindStringSubmatch(name); m != nil {
+		i, _ := strconv.Atoi(m[2])
+		switch m[1] {
+		case "RAM", "RAM16K":
+			return c.com.RAM(i), nil
+		case "ROM", "ROM32K":
+			return c.com.ROM(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown variable %s", name)
+}
+
+func (c *ComputerTarget) Set(name string, v int) error {
+	switch register(name) {
+	case "A":
+		c.com.SetA(v)
+		return nil
+	case "D":
+		c.com.SetD(v)
+		return nil
+	case "PC":
+		c.com.SetPC(v)
+		return nil
+	}
+	if name == "reset" {
+		c.reset = bit(v)
+		return nil
+	}
+	if m := reIndexed.FindStringSubmatch(name); m != nil {
+		i, _ := strconv.Atoi(m[2])
+		switch m[1] {
+		case "RAM", "RAM16K":
+			c.com.SetRAM(i, v)
+			return nil
+		case "ROM", "ROM32K":
+			c.com.SetROM(i, v)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown variable %s", name)
+}
+
+func (c *ComputerTarget) Eval() {}
+
+func (c *ComputerTarget) Tick() {}
+
+// Tock executes one instruction, so ticktock is one CPU cycle.
+func (c *ComputerTarget) Tock() {
+	c.com.FetchAndExecute(c.reset)
+}
+
+// pins holds the values of the input and output pins of a chip.
+type pins map[string]int
+
+// ChipTarget drives one of the chips of chapters 1 to 3, the Memory or the CPU.
+type ChipTarget struct {
+	name  string
+	pins  pins
+	width map[string]int
+	// eval recomputes the outputs. When write is false the loads of a
+	// sequential chip are masked so that it is only read.
+	eval       func(p pins, write bool)
+	clock      *memory.Clock
+	sequential bool
+	// ticked holds the inputs sampled by tick until the following tock.
+	ticked pins
+}
+
+var _ Target = (*ChipTarget)(nil)
+
+type chipSpec struct {
+	pins       map[string]int
+	sequential bool
+	// build returns the evaluation function bound to the chip state.
+	build func(clock *memory.Clock) func(p pins, write bool)
+}
+
+// COMPUTER_CHIP is the chip which NewTarget loads as a ComputerTarget.
+const COMPUTER_CHIP = "Computer"
+
+// NewTarget returns the target loaded by "load NAME.hdl".
+func NewTarget(name string) (Target, error) {
+	if chipName(name) == COMPUTER_CHIP {
+		return NewComputerChipTarget(), nil
+	}
+	return NewChipTarget(name)
+}
+
+func chipName(name string) string {
+	return strings.TrimSuffix(filepath.Base(name), ".hdl")
+}
+
+// NewChipTarget returns the chip loaded by "load NAME.hdl".
+func NewChipTarget(name string) (*ChipTarget, error) {
+	name = chipName(name)
+	spec, ok := chips[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown chip %s", name)
+	}
+	clock := memory.NewClock()
+	c := &ChipTarget{
+		name:       name,
+		pins:       pins{},
+		width:      spec.pins,
+		eval:       spec.build(clock),
+		clock:      clock,
+		sequential: spec.sequential,
+	}
+	for pin := range spec.pins {
+		c.pins[pin] = 0
+	}
+	c.eval(c.pins, false)
+	return c, nil
+}
+
+// Chips returns the names of the chips which can be loaded.
+func Chips() []string {
+	ret := []string{COMPUTER_CHIP}
+	for name := range chips {
+		ret = append(ret, name)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+func (c *ChipTarget) Get(name string) (int, error) {
+	name = trimRegister(name)
+	v, ok := c.pins[name]
+	if !ok {
+		return 0, fmt.Errorf("%s has no pin %s", c.name, name)
+	}
+	return v, nil
+}
+
+func (c *ChipTarget) Set(name string, v int) error {
+	w, ok := c.width[name]
+	if !ok {
+		return fmt.Errorf("%s has no pin %s", c.name, name)
+	}
+	c.pins[name] = v & (1<<uint(w) - 1)
+	return nil
+}
+
+func (c *ChipTarget) Eval() {
+	c.eval(c.pins, false)
+	if c.ticked != nil {
+		// Reading overwrote the pending DFF inputs; schedule them again.
+		c.eval(c.copyPins(c.ticked), true)
+	}
+}
+
+func (c *ChipTarget) Tick() {
+	c.eval(c.pins, true)
+	if c.sequential {
+		c.ticked = c.copyPins(c.pins)
+	}
+}
+
+func (c *ChipTarget) Tock() {
+	if c.sequential {
+		c.clock.Progress()
+		c.ticked = nil
+	}
+	c.eval(c.pins, false)
+}
+
+func (c *ChipTarget) copyPins(p pins) pins {
+	ret := pins{}
+	for k, v := range p {
+		ret[k] = v
+	}
+	return ret
+}
+
+func bits(v, n int) []Bit {
+	ret := make([]Bit, n)
+	for i := range ret {
+		ret[i] = Bit(v >> uint(i) & 1)
+	}
+	return ret
+}
+
+func word(v int) (w Word) {
+	copy(w[:], bits(v, 16))
+	return w
+}
+
+// unsigned converts bits to an integer without sign extension.
+func unsigned(bs []Bit) int {
+	ret := 0
+	for i := len(bs) - 1; i >= 0; i-- {
+		ret = ret<<1 | int(bs[i])
+	}
+	return ret
+}
+
+func wordValue(w Word) int {
+	return unsigned(w[:])
+}
+
+func bit(v int) Bit {
+	return Bit(v & 1)
+}
+
+// combinational wraps a chip without state.
+func combinational(widths map[string]int, f func(p pins)) chipSpec {
+	return chipSpec{
+		pins: widths,
+		build: func(*memory.Clock) func(pins, bool) {
+			return func(p pins, _ bool) { f(p) }
+		},
+	}
+}
+
+// ram wraps a RAM chip whose address has n bits.
+func ram(n int, build func(clock *memory.Clock) func(in Word, load Bit, addr []Bit) Word) chipSpec {
+	return chipSpec{
+		pins:       map[string]int{"in": 16, "load": 1, "address": n, "out": 16},
+		sequential: true,
+		build: func(clock *memory.Clock) func(pins, bool) {
+			apply := build(clock)
+			return func(p pins, write bool) {
+				load := bit(p["load"])
+				if !write {
+					load = logic.O
+				}
+				p["out"] = wordValue(apply(word(p["in"]), load, bits(p["address"], n)))
+			}
+		},
+	}
+}
+
+var chips = map[string]chipSpec{
+	"Nand": combinational(map[string]int{"a": 1, "b": 1, "out": 1}, func(p pins) {
+		p["out"] = int(logic.Nand(bit(p["a"]), bit(p["b"])))
+	}),
+	"Not": combinational(map[string]int{"in": 1, "out": 1}, func(p pins) {
+		p["out"] = int(logic.Not(bit(p["in"])))
+	}),
+	"And": combinational(map[string]int{"a": 1, "b": 1, "out": 1}, func(p pins) {
+		p["out"] = int(logic.And(bit(p["a"]), bit(p["b"])))
+	}),
+	"Or": combinational(map[string]int{"a": 1, "b": 1, "out": 1}, func(p pins) {
+		p["out"] = int(logic.Or(bit(p["a"]), bit(p["b"])))
+	}),
+	"Xor": combinational(map[string]int{"a": 1, "b": 1, "out": 1}, func(p pins) {
+		p["out"] = int(logic.Xor(bit(p["a"]), bit(p["b"])))
+	}),
+	"Mux": combinational(map[string]int{"a": 1, "b": 1, "sel": 1, "out": 1}, func(p pins) {
+		p["out"] = int(logic.Mux(bit(p["a"]), bit(p["b"]), bit(p["sel"])))
+	}),
+	"DMux": combinational(map[string]int{"in": 1, "sel": 1, "a": 1, "b": 1}, func(p pins) {
+		out := logic.DMux(bit(p["in"]), bit(p["sel"]))
+		p["a"], p["b"] = int(out[0]), int(out[1])
+	}),
+	"Not16": combinational(map[string]int{"in": 16, "out": 16}, func(p pins) {
+		p["out"] = wordValue(logic.Not16(word(p["in"])))
+	}),
+	"And16": combinational(map[string]int{"a": 16, "b": 16, "out": 16}, func(p pins) {
+		p["out"] = wordValue(logic.And16(word(p["a"]), word(p["b"])))
+	}),
+	"Or16": combinational(map[string]int{"a": 16, "b": 16, "out": 16}, func(p pins) {
+		p["out"] = wordValue(logic.Or16(word(p["a"]), word(p["b"])))
+	}),
+	"Mux16": combinational(map[string]int{"a": 16, "b": 16, "sel": 1, "out": 16}, func(p pins) {
+		p["out"] = wordValue(logic.Mux16(word(p["a"]), word(p["b"]), bit(p["sel"])))
+	}),
+	"Or8Way": combinational(map[string]int{"in": 8, "out": 1}, func(p pins) {
+		var in [8]Bit
+		copy(in[:], bits(p["in"], 8))
+		p["out"] = int(logic.Or8Way(in))
+	}),
+	"Mux4Way16": combinational(map[string]int{"a": 16, "b": 16, "c": 16, "d": 16, "sel": 2, "out": 16}, func(p pins) {
+		var sel [2]Bit
+		copy(sel[:], bits(p["sel"], 2))
+		p["out"] = wordValue(logic.Mux4Way16(word(p["a"]), word(p["b"]), word(p["c"]), word(p["d"]), sel))
+	}),
+	"Mux8Way16": combinational(map[string]int{
+		"a": 16, "b": 16, "c": 16, "d": 16, "e": 16, "f": 16, "g": 16, "h": 16, "sel": 3, "out": 16,
+	}, func(p pins) {
+		var sel [3]Bit
+		copy(sel[:], bits(p["sel"], 3))
+		p["out"] = wordValue(logic.Mux8Way16(
+			word(p["a"]), word(p["b"]), word(p["c"]), word(p["d"]),
+			word(p["e"]), word(p["f"]), word(p["g"]), word(p["h"]),
+			sel,
+		))
+	}),
+	"DMux4Way": combinational(map[string]int{"in": 1, "sel": 2, "a": 1, "b": 1, "c": 1, "d": 1}, func(p pins) {
+		var sel [2]Bit
+		copy(sel[:], bits(p["sel"], 2))
+		out := logic.Dmux4Way(bit(p["in"]), sel)
+		for i, pin := range []string{"a", "b", "c", "d"} {
+			p[pin] = int(out[i])
+		}
+	}),
+	"DMux8Way": combinational(map[string]int{
+		"in": 1, "sel": 3, "a": 1, "b": 1, "c": 1, "d": 1, "e": 1, "f": 1, "g": 1, "h": 1,
+	}, func(p pins) {
+		var sel [3]Bit
+		copy(sel[:], bits(p["sel"], 3))
+		out := logic.Dmux8Way(bit(p["in"]), sel)
+		for i, pin := range []string{"a", "b", "c", "d", "e", "f", "g", "h"} {
+			p[pin] = int(out[i])
+		}
+	}),
+	"Add16": combinational(map[string]int{"a": 16, "b": 16, "out": 16}, func(p pins) {
+		p["out"] = wordValue(arithmetic.Adder(word(p["a"]), word(p["b"])))
+	}),
+	"Inc16": combinational(map[string]int{"in": 16, "out": 16}, func(p pins) {
+		p["out"] = wordValue(arithmetic.Inc16(word(p["in"])))
+	}),
+	"ALU": combinational(map[string]int{
+		"x": 16, "y": 16, "zx": 1, "nx": 1, "zy": 1, "ny": 1, "f": 1, "no": 1, "out": 16, "zr": 1, "ng": 1,
+	}, func(p pins) {
+		out, zr, ng := arithmetic.ALU(
+			word(p["x"]), word(p["y"]),
+			bit(p["zx"]), bit(p["nx"]), bit(p["zy"]), bit(p["ny"]), bit(p["f"]), bit(p["no"]),
+		)
+		p["out"], p["zr"], p["ng"] = wordValue(out), int(zr), int(ng)
+	}),
+
+	"Bit": {
+		pins:       map[string]int{"in": 1, "load": 1, "out": 1},
+		sequential: true,
+		build: func(clock *memory.Clock) func(pins, bool) {
+			b := memory.NewBit(clock)
+			return func(p pins, write bool) {
+				load := bit(p["load"])
+				if !write {
+					load = logic.O
+				}
+				p["out"] = int(b.Apply(load, bit(p["in"])))
+			}
+		},
+	},
+	"Register": {
+		pins:       map[string]int{"in": 16, "load": 1, "out": 16},
+		sequential: true,
+		build: func(clock *memory.Clock) func(pins, bool) {
+			r := memory.NewRegister(clock)
+			return func(p pins, write bool) {
+				load := bit(p["load"])
+				if !write {
+					load = logic.O
+				}
+				p["out"] = wordValue(r.Apply(load, word(p["in"])))
+			}
+		},
+	},
+	"PC": {
+		pins:       map[string]int{"in": 16, "load": 1, "inc": 1, "reset": 1, "out": 16},
+		sequential: true,
+		build: func(clock *memory.Clock) func(pins, bool) {
+			pc := memory.NewPC(clock)
+			return func(p pins, write bool) {
+				load, inc, reset := bit(p["load"]), bit(p["inc"]), bit(p["reset"])
+				if !write {
+					load, inc, reset = logic.O, logic.O, logic.O
+				}
+				p["out"] = wordValue(pc.Apply(word(p["in"]), load, inc, reset))
+			}
+		},
+	},
+	"RAM8": ram(3, func(clock *memory.Clock) func(Word, Bit, []Bit) Word {
+		r := memory.NewRAM8(clock)
+		return func(in Word, load Bit, addr []Bit) Word {
+			var a [3]Bit
+			copy(a[:], addr)
+			return r.Apply(in, load, a)
+		}
+	}),
+	"RAM64": ram(6, func(clock *memory.Clock) func(Word, Bit, []Bit) Word {
+		r := memory.NewRAM64(clock)
+		return func(in Word, load Bit, addr []Bit) Word {
+			var a [6]Bit
+			copy(a[:], addr)
+			return r.Apply(in, load, a)
+		}
+	}),
+	"RAM512": ram(9, func(clock *memory.Clock) func(Word, Bit, []Bit) Word {
+		r := memory.NewRAM512(clock)
+		return func(in Word, load Bit, addr []Bit) Word {
+			var a [9]Bit
+			copy(a[:], addr)
+			return r.Apply(in, load, a)
+		}
+	}),
+	"RAM4K": ram(12, func(clock *memory.Clock) func(Word, Bit, []Bit) Word {
+		r := memory.NewRAM4096(clock)
+		return func(in Word, load Bit, addr []Bit) Word {
+			var a [12]Bit
+			copy(a[:], addr)
+			return r.Apply(in, load, a)
+		}
+	}),
+	"RAM16K": ram(14, func(clock *memory.Clock) func(Word, Bit, []Bit) Word {
+		r := memory.NewRAM16384(clock)
+		return func(in Word, load Bit, addr []Bit) Word {
+			var a [14]Bit
+			copy(a[:], addr)
+			return r.Apply(in, load, a)
+		}
+	}),
+
+	"Memory": ram(15, func(clock *memory.Clock) func(Word, Bit, []Bit) Word {
+		m := computer.NewMemory(clock, &computer.TuiScreen{}, &computer.TuiKeyboard{})
+		return func(in Word, load Bit, addr []Bit) Word {
+			var a [15]Bit
+			copy(a[:], addr)
+			return m.Fetch(in, load, a)
+		}
+	}),
+	"CPU": {
+		pins: map[string]int{
+			"inM": 16, "instruction": 16, "reset": 1,
+			"outM": 16, "writeM": 1, "addressM": 15, "pc": 15, "DRegister": 16,
+		},
+		sequential: true,
+		build:      cpuChip,
+	},
+}
+
+// cpuChip wraps the CPU, which executes a whole instruction at once: it runs
+// on the first tick of a cycle, and until the tock the registers keep
+// showing their old values. outM and writeM are those of the last tick.
+func cpuChip(clock *memory.Clock) func(pins, bool) {
+	cpu := computer.NewCPU()
+	var outM Word
+	var writeM Bit
+	var a, d Word
+	var pc computer.Address
+	ticked, at := false, clock.Now()
+	return func(p pins, write bool) {
+		if ticked && at != clock.Now() {
+			ticked = false
+		}
+		if !ticked {
+			a, d, pc = cpu.A(), cpu.D(), cpu.PC()
+		}
+		if write && !ticked {
+			outM, writeM, _, _ = cpu.Fetch(word(p["inM"]), word(p["instruction"]), bit(p["reset"]))
+			ticked, at = true, clock.Now()
+		}
+		p["outM"], p["writeM"] = wordValue(outM), int(writeM)
+		p["addressM"], p["pc"], p["DRegister"] = unsigned(a[:15]), unsigned(pc[:]), wordValue(d)
+	}
+}
@@ -0,0 +1,9 @@
+/* Bit keeps its output until the tock after a load. */
+load Bit.hdl,
+output-file Bit.out,
+compare-to Bit.cmp,
+output-list time%S1.4.1 in%B2.1.2 load%B2.1.2 out%B2.1.2;
+
+set in 0, set load 0, tick, output; tock, output;
+set in 1, set load 1, tick, output; tock, output;
+set in 0, set load 0, tick, output; tock, output;
@@ -0,0 +1,21 @@
+// Add.hack computes RAM[0] = 2 + 3 on the gate-level Computer.
+load Computer.hdl,
+output-file ComputerAdd.out,
+compare-to ComputerAdd.cmp,
+output-list time%S1.4.1 reset%B2.1.2 ARegister[0]%D1.7.1 DRegister[0]%D1.7.1 PC[]%D0.4.0 RAM16K[0]%D1.7.1;
+
+ROM32K load Add.hack,
+output;
+
+repeat 6 {
+    tick, tock, output;
+}
+
+// Run again after a reset.
+set reset 1, set RAM16K[0] 0,
+tick, tock, output;
+
+set reset 0,
+repeat 6 {
+    tick, tock, output;
+}
@@ -0,0 +1,22 @@
+// Runs Max.asm on the CPU emulator.
+load Max.asm,
+output-file Max.out,
+compare-to Max.cmp,
+output-list RAM[0]%D2.6.2 RAM[1]%D2.6.2 RAM[2]%D2.6.2;
+
+set RAM[0] 3,   // Set test arguments
+set RAM[1] 5,
+set RAM[2] 0;
+repeat 14 {
+  ticktock;
+}
+output;
+
+set PC 0,
+set RAM[0] 23,
+set RAM[1] %X4,
+set RAM[2] 0;
+while PC <> 14 {
+  ticktock;
+}
+output;
@@ -0,0 +1,17 @@
+load Memory.hdl,
+output-file Memory.out,
+compare-to Memory.cmp,
+output-list time%S1.4.1 in%D1.6.1 load%B2.1.2 address%D1.5.1 out%D1.6.1;
+
+set in 12345, set load 1, set address 8192, tick, output; tock, output;
+set in -1, set address 16384, tick, output; tock, output;
+set in 4321, set address 24575, tick, output; tock, output;
+
+// The keyboard cannot be written.
+set in 99, set address 24576, tick, output; tock, output;
+
+// RAM keeps its word while the screen is accessed.
+set load 0, set address 8192, eval, output;
+set address 16384, eval, output;
+set address 24575, eval, output;
+set address 0, eval, output;
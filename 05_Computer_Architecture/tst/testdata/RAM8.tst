@@ -0,0 +1,13 @@
+load RAM8.hdl,
+output-file RAM8.out,
+compare-to RAM8.cmp,
+output-list time%S1.4.1 in%D1.6.1 load%B2.1.2 address%D3.1.3 out%D1.6.1;
+
+set in -1, set load 1, set address 3, tick, output; tock, output;
+set in 0, set load 0, set address 0, eval, output;
+set address 3, eval, output;
+
+// The address sampled by tick is written, even if it changes before tock.
+set in %B0000000000001111, set load 1, set address 7, tick, output;
+set load 0, set address 0, eval, output; tock, output;
+set address 7, eval, output;
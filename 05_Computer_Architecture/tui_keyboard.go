@@ -72,3 +72,10 @@ func (kb *TuiKeyboard) Set(key *tcell.EventKey) {
 		code & 128 >> 7,
 	}
 }
+
+// SetWord overwrites the keyboard register, e.g. when restoring a snapshot.
+func (kb *TuiKeyboard) SetWord(w Word) {
+	kb.mu.Lock()
+	defer kb.mu.Unlock()
+	kb.word = w
+}
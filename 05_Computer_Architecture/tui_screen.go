@@ -44,18 +44,22 @@ var (
 // Screen[r*32+c/16]
 type TuiScreen struct {
 	words [NROW * NCOL / 16]Word
-	mu    sync.RWMutex
+	// changes counts the writes which changed each pixel row, so that a
+	// ScreenBuffer can copy only the rows changed since it last looked.
+	changes [NROW]uint32
+	mu      sync.RWMutex
 }
 
-func (s *TuiScreen) Fetch(in Word, load Bit, addr [13]Bit) Word {
+func (s *TuiScreen) Fetch(in Word, load Bit, addr [13]Bit) (out Word) {
+	i := s.addr2index(addr)
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	defer func() {
-		if load == logic.I {
-			s.words[s.addr2index(addr)] = in
-		}
-	}()
-	return s.words[s.addr2index(addr)]
+	out = s.words[i]
+	if load == logic.I && in != out {
+		s.words[i] = in
+		s.changes[i/NCOL_W]++
+	}
+	return out
 }
 
 func (s *TuiScreen) addr2index(addr [13]Bit) int {
@@ -92,17 +96,23 @@ func (s *TuiScreen) Str() string {
 }
 
 func (s *TuiScreen) char(r, c int) rune {
-	w1Index := s.coord2wordsIndex(r*4, c*2)
+	return brailleChar(&s.words, r, c)
+}
+
+// brailleChar returns the braille character for the 2x4 pixels of text
+// row r and column c of words.
+func brailleChar(words *screenWords, r, c int) rune {
+	w1Index := r*4*NCOL_W + c*2/16
 	w2Index := w1Index + 32
 	w3Index := w2Index + 32
 	w4Index := w3Index + 32
 	iw := (8 - c%8) * 2
 
 	var code [8]uint8
-	copy(code[0:2], s.words[w1Index][iw-2:iw])
-	copy(code[2:4], s.words[w2Index][iw-2:iw])
-	copy(code[4:6], s.words[w3Index][iw-2:iw])
-	copy(code[6:8], s.words[w4Index][iw-2:iw])
+	copy(code[0:2], words[w1Index][iw-2:iw])
+	copy(code[2:4], words[w2Index][iw-2:iw])
+	copy(code[4:6], words[w3Index][iw-2:iw])
+	copy(code[6:8], words[w4Index][iw-2:iw])
 	return Brailles[code]
 }
 
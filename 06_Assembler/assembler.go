@@ -2,15 +2,72 @@ package assembler
 
 import (
 	"fmt"
+	"io"
 	"io/ioutil"
 	"math"
+	"path/filepath"
+	"regexp"
+	"sort"
 	"strconv"
+	"strings"
 )
 
+// MAX_CONSTANT is the largest value of an A-instruction.
+const MAX_CONSTANT = 32767
+
+var reSymbol = regexp.MustCompile(`^[A-Za-z_.$:][A-Za-z0-9_.$:]*$`)
+
+// Position is a place in the source, with line and column counted from 1.
+type Position struct {
+	File string
+	Line int
+	Col  int
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("%s:%d:%d", p.File, p.Line, p.Col)
+}
+
+// Error is a diagnostic at a position of the source.
+type Error struct {
+	Pos Position
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Pos, e.Msg)
+}
+
+// ErrorList holds every diagnostic of an Assemble, one per line, in source
+// order.
+type ErrorList []*Error
+
+func (l ErrorList) Error() string {
+	msgs := make([]string, len(l))
+	for i, e := range l {
+		msgs[i] = e.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// assembled is an instruction written to ROM: where it came from, the line
+// of the main file it was expanded from, its text and its binary.
+type assembled struct {
+	pos    Position
+	origin int
+	text   string
+	word   string
+}
+
 type Assembler struct {
+	file   string
+	source string
 	parser *Parser
 	code   *Code
 	st     *SymbolTable
+	// insts holds the instruction of every ROM address assembled.
+	insts []assembled
+	errs  ErrorList
 }
 
 func NewAssembler(f string) (*Assembler, error) {
@@ -18,83 +75,247 @@ func NewAssembler(f string) (*Assembler, error) {
 	if err != nil {
 		return nil, err
 	}
-	parser := NewParser(string(b))
 	code := &Code{}
 	st := NewSymbolTable()
-	return &Assembler{parser: parser, code: code, st: st}, nil
+	return &Assembler{file: f, source: string(b), code: code, st: st}, nil
 }
 
+// Assemble expands the directives of the program and translates it to
+// "0101..." lines. All the errors found are returned together as an
+// ErrorList.
 func (asm *Assembler) Assemble() (string, error) {
-	ret := ""
+	asm.errs = nil
+	pp := newPreprocessor()
+	pp.file(asm.file, asm.source, 0)
+	if len(pp.errs) > 0 {
+		return "", pp.errs
+	}
+	asm.parser = newParser(pp.out)
 	ln := 0
-	for {
-		inst := asm.parser.InstructionType()
+	labels := make(map[string]Position)
+	asm.forEach(func(inst InstructionType) {
 		switch inst {
 		case L_INSTRUCTION:
-			symbol := asm.parser.Symbol()
-			if !isNum(symbol) {
-				asm.st.AddEntry(symbol, ln, true)
+			asm.defineLabel(ln, labels)
+		default:
+			if ln == MAX_CONSTANT+1 {
+				asm.errorf(0, "program exceeds the %d words of ROM", MAX_CONSTANT+1)
 			}
-		}
-
-		if inst == A_INSTRUCTION || inst == C_INSTRUCTION {
 			ln += 1
 		}
+	})
 
-		if asm.parser.HasMoreLines() {
-			asm.parser.Advance()
-		} else {
-			break
+	asm.forEach(func(inst InstructionType) {
+		switch inst {
+		case A_INSTRUCTION:
+			asm.checkA()
+		case C_INSTRUCTION:
+			asm.checkC()
 		}
+	})
+	if len(asm.errs) > 0 {
+		sort.SliceStable(asm.errs, func(i, j int) bool {
+			a, b := asm.errs[i].Pos, asm.errs[j].Pos
+			if a.File != b.File {
+				return a.File < b.File
+			}
+			return a.Line < b.Line || a.Line == b.Line && a.Col < b.Col
+		})
+		return "", asm.errs
 	}
 
-	asm.parser.Reset()
-	for {
-		inst := asm.parser.InstructionType()
+	var ret strings.Builder
+	asm.insts = nil
+	asm.forEach(func(inst InstructionType) {
+		var word string
 		switch inst {
 		case A_INSTRUCTION:
+			// checkA has defined every symbol and evaluated every expression.
 			symbol := asm.parser.Symbol()
-			if !isNum(symbol) {
-				asm.st.AddEntry(symbol, ln, false)
+			addr, _ := asm.st.GetAddress(symbol)
+			if !reSymbol.MatchString(symbol) {
+				addr, _ = asm.operand(symbol)
 			}
+			word = toBinary(fmt.Sprintf("%d", addr))
+		case C_INSTRUCTION:
+			word = "111" + asm.code.Comp(asm.parser.Comp()) +
+				asm.code.Dest(asm.parser.Dest()) +
+				asm.code.Jump(asm.parser.Jump())
+		default:
+			return
 		}
+		asm.insts = append(asm.insts, assembled{
+			pos:    asm.pos(0),
+			origin: asm.parser.origin(),
+			text:   asm.parser.text(),
+			word:   word,
+		})
+		fmt.Fprintf(&ret, "%s\n", word)
+	})
+	return ret.String(), nil
+}
 
-		if asm.parser.HasMoreLines() {
-			asm.parser.Advance()
-		} else {
-			break
+// forEach calls f with every instruction of the program in order.
+func (asm *Assembler) forEach(f func(inst InstructionType)) {
+	asm.parser.Reset()
+	if len(asm.parser.lines) == 0 {
+		return
+	}
+	for {
+		f(asm.parser.InstructionType())
+		if !asm.parser.HasMoreLines() {
+			return
 		}
+		asm.parser.Advance()
 	}
+}
 
-	asm.parser.Reset()
-	for {
-		inst := asm.parser.InstructionType()
-		switch inst {
-		case A_INSTRUCTION:
-			symbol := asm.parser.Symbol()
-			if !isNum(symbol) {
-				_symbol, err := asm.st.GetAddress(symbol)
-				if err != nil {
-					return "", err
-				}
-				symbol = fmt.Sprintf("%d", _symbol)
-			}
-			ret += fmt.Sprintf("%s\n", toBinary(symbol))
-		case C_INSTRUCTION:
-			ret += fmt.Sprintf("111%s%s%s\n",
-				asm.code.Comp(asm.parser.Comp()),
-				asm.code.Dest(asm.parser.Dest()),
-				asm.code.Jump(asm.parser.Jump()),
-			)
+// errorf records an error at byte off of the current instruction.
+func (asm *Assembler) errorf(off int, format string, a ...interface{}) {
+	asm.errs = append(asm.errs, &Error{Pos: asm.pos(off), Msg: fmt.Sprintf(format, a...)})
+}
+
+func (asm *Assembler) pos(off int) Position {
+	return Position{File: asm.parser.File(), Line: asm.parser.LineNumber(), Col: asm.parser.Column(off)}
+}
+
+// defineLabel defines the label of the current instruction as ROM address
+// addr.
+func (asm *Assembler) defineLabel(addr int, labels map[string]Position) {
+	symbol := asm.parser.Symbol()
+	switch prev, ok := labels[symbol]; {
+	case !reL.MatchString(asm.parser.text()):
+		asm.errorf(0, "malformed label %q, expected (SYMBOL)", asm.parser.text())
+	case !reSymbol.MatchString(symbol):
+		asm.errorf(1, "malformed label %q", symbol)
+	case ok:
+		asm.errorf(1, "label %s redefined; previous definition at %s", symbol, prev)
+	case asm.st.Contains(symbol):
+		asm.errorf(1, "label %s redefines a predefined symbol", symbol)
+	default:
+		asm.st.AddEntry(symbol, addr, true)
+		labels[symbol] = asm.pos(1)
+	}
+}
+
+// checkA validates the current A-instruction and allocates its variable.
+// An operand other than a symbol is a constant expression.
+func (asm *Assembler) checkA() {
+	symbol := asm.parser.Symbol()
+	switch {
+	case symbol == "":
+		asm.errorf(0, "missing value after @")
+	case reSymbol.MatchString(symbol):
+		asm.st.AddEntry(symbol, 0, false)
+	default:
+		if _, err := asm.operand(symbol); err != nil {
+			e := err.(*exprError)
+			asm.errorf(1+e.off, "%s", e.msg)
 		}
-		if asm.parser.HasMoreLines() {
-			asm.parser.Advance()
-		} else {
-			break
+	}
+}
+
+// operand evaluates the expression s of an A-instruction. Its symbols must
+// be labels, predefined symbols or variables used before.
+func (asm *Assembler) operand(s string) (int, error) {
+	n, err := evaluate(s, func(name string) (int, bool) {
+		addr, err := asm.st.GetAddress(name)
+		return addr, err == nil
+	})
+	switch {
+	case err != nil:
+		return 0, err
+	case n >= 0 && n <= MAX_CONSTANT:
+		return int(n), nil
+	case isNum(s):
+		return 0, &exprError{msg: fmt.Sprintf("constant %s out of range 0-%d", s, MAX_CONSTANT)}
+	default:
+		return 0, &exprError{msg: fmt.Sprintf("%s evaluates to %d, out of range 0-%d", s, n, MAX_CONSTANT)}
+	}
+}
+
+// checkC validates the mnemonics of the current C-instruction.
+func (asm *Assembler) checkC() {
+	l := asm.parser.text()
+	dest, comp, jump := asm.parser.Dest(), asm.parser.Comp(), asm.parser.Jump()
+	if asm.code.Dest(dest) == "" {
+		asm.errorf(0, "unknown dest %q", dest)
+	}
+	compOff := strings.Index(l, "=") + 1
+	if comp == "" {
+		asm.errorf(compOff, "missing comp")
+	} else if asm.code.Comp(comp) == "" {
+		asm.errorf(compOff, "unknown comp %q", comp)
+	}
+	if asm.code.Jump(jump) == "" {
+		asm.errorf(strings.Index(l, ";")+1, "unknown jump %q", jump)
+	}
+}
+
+// WriteSourceMap writes the source map of the last Assemble, one
+// "hack:LINE asm:LINE" line per instruction, where hack is the file the
+// instructions are written to and asm the file the instruction was read
+// from. Paths are relative to the directory of hack.
+func (asm *Assembler) WriteSourceMap(w io.Writer, hack string) error {
+	for i, inst := range asm.insts {
+		src, err := filepath.Rel(filepath.Dir(hack), inst.pos.File)
+		if err != nil {
+			src = inst.pos.File
+		}
+		if _, err := fmt.Fprintf(w, "%s:%d %s:%d\n", filepath.Base(hack), i+1, filepath.ToSlash(src), inst.pos.Line); err != nil {
+			return err
 		}
 	}
+	return nil
+}
+
+// Symbols returns the labels and then the variables of the last Assemble,
+// each in the order of the source.
+func (asm *Assembler) Symbols() []Symbol {
+	var syms []Symbol
+	for _, name := range asm.st.Labels() {
+		addr, _ := asm.st.GetAddress(name)
+		syms = append(syms, Symbol{Name: name, Addr: addr})
+	}
+	for _, name := range asm.st.Variables() {
+		addr, _ := asm.st.GetAddress(name)
+		syms = append(syms, Symbol{Name: name, Addr: addr, RAM: true})
+	}
+	return syms
+}
 
-	return ret, nil
+// WriteSymbols writes the symbols of the last Assemble, a "NAME ADDRESS"
+// line per label and then a "NAME ADDRESS RAM" line per variable.
+func (asm *Assembler) WriteSymbols(w io.Writer) error {
+	return WriteSymbols(w, asm.Symbols())
+}
+
+// WriteListing writes every line of the main file of the last Assemble next
+// to the ROM address and the binary of its instruction, if any. The
+// instructions a line expands to, through a macro or an .include, follow
+// it marked with "+".
+func (asm *Assembler) WriteListing(w io.Writer) error {
+	expanded := make(map[int][]int)
+	for addr, inst := range asm.insts {
+		expanded[inst.origin] = append(expanded[inst.origin], addr)
+	}
+	for i, l := range strings.Split(strings.TrimSuffix(asm.source, "\n"), "\n") {
+		addrs := expanded[i+1]
+		lines := []string{fmt.Sprintf("%5s  %16s  %5d  %s", "", "", i+1, l)}
+		if len(addrs) == 1 && asm.insts[addrs[0]].pos.File == asm.file && asm.insts[addrs[0]].pos.Line == i+1 {
+			lines[0] = fmt.Sprintf("%5d  %s  %5d  %s", addrs[0], asm.insts[addrs[0]].word, i+1, l)
+		} else {
+			for _, addr := range addrs {
+				lines = append(lines, fmt.Sprintf("%5d  %s  %5s  + %s", addr, asm.insts[addr].word, "", asm.insts[addr].text))
+			}
+		}
+		for _, line := range lines {
+			if _, err := fmt.Fprintln(w, strings.TrimRight(line, " \t\r")); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
 }
 
 func isNum(s string) bool {
@@ -1,11 +1,14 @@
 package assembler
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
-	logic "github.com/kazufusa/nand2tetris/01_Boolean_Logic"
-	computer "github.com/kazufusa/nand2tetris/05_Computer_Architecture"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -20,16 +23,97 @@ func TestAssembler(t *testing.T) {
 	assert.Equal(t, string(expected), ret)
 }
 
-func TestAssemblerWithEmulator(t *testing.T) {
+func TestWriteSourceMap(t *testing.T) {
 	asm, err := NewAssembler("./Max.asm")
 	require.NoError(t, err)
-	ret, err := asm.Assemble()
+	_, err = asm.Assemble()
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.NoError(t, asm.WriteSourceMap(&buf, "./Max.hack"))
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	assert.Len(t, lines, 16)
+	assert.Equal(t, "Max.hack:1 Max.asm:8", lines[0])
+	// The label on line 18 takes no address.
+	assert.Equal(t, "Max.hack:11 Max.asm:19", lines[10])
+}
+
+func TestWriteSymbols(t *testing.T) {
+	asm, err := NewAssembler("./Rect.asm")
 	require.NoError(t, err)
-	com := computer.NewEmulator(ret)
-	com.WriteRom(0, 14)
-	com.WriteRom(1, 12)
-	for i := 0; i < 20; i++ {
-		com.FetchAndExecute(logic.O)
+	_, err = asm.Assemble()
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.NoError(t, asm.WriteSymbols(&buf))
+	assert.Equal(t, "LOOP 10\nINFINITE_LOOP 23\ncounter 16 RAM\naddress 17 RAM\n", buf.String())
+
+	syms, err := ReadSymbols(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, asm.Symbols(), syms)
+	assert.Equal(t, []Symbol{{Name: "LOOP", Addr: 10}, {Name: "INFINITE_LOOP", Addr: 23}}, Labels(syms))
+}
+
+func TestWriteListing(t *testing.T) {
+	asm, err := NewAssembler("./Max.asm")
+	require.NoError(t, err)
+	_, err = asm.Assemble()
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.NoError(t, asm.WriteListing(&buf))
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	assert.Len(t, lines, 26)
+	assert.Equal(t, "                             7", lines[6])
+	assert.Equal(t, "    0  0000000000000000      8     @0", lines[7])
+	assert.Equal(t, "    1  1111110000010000      9     D=M              // D = first number", lines[8])
+	assert.Equal(t, "                            18  (OUTPUT_FIRST)", lines[17])
+	assert.Equal(t, "   10  0000000000000000     19     @0", lines[18])
+}
+
+func TestAssembleErrors(t *testing.T) {
+	src := `// every line has an error
+   @40000
+   D = M+2   // comp
+   X=D
+	D;JXX
+(LOOP
+(1ABC)
+(LOOP)
+  (LOOP)
+(SP)
+@-1
+@1abc
+@a-b
+@
+D=
+@99999999999999999999
+`
+	f := filepath.Join(t.TempDir(), "Bad.asm")
+	require.NoError(t, os.WriteFile(f, []byte(src), 0644))
+	asm, err := NewAssembler(f)
+	require.NoError(t, err)
+	ret, err := asm.Assemble()
+	assert.Equal(t, "", ret)
+	var errs ErrorList
+	require.True(t, errors.As(err, &errs))
+	var msgs []string
+	for _, e := range errs {
+		assert.Equal(t, f, e.Pos.File)
+		msgs = append(msgs, fmt.Sprintf("%d:%d: %s", e.Pos.Line, e.Pos.Col, e.Msg))
 	}
-	assert.Equal(t, 14, com.ROMMap()[2])
+	assert.Equal(t, []string{
+		`2:5: constant 40000 out of range 0-32767`,
+		`3:8: unknown comp "M+2"`,
+		`4:4: unknown dest "X"`,
+		`5:4: unknown jump "JXX"`,
+		`6:1: malformed label "(LOOP", expected (SYMBOL)`,
+		`7:2: malformed label "1ABC"`,
+		`9:4: label LOOP redefined; previous definition at ` + f + `:8:2`,
+		`10:2: label SP redefines a predefined symbol`,
+		`11:2: constant -1 out of range 0-32767`,
+		`12:2: malformed constant "1abc"`,
+		`13:2: undefined symbol "a"`,
+		`14:1: missing value after @`,
+		`15:3: missing comp`,
+		`16:2: constant 99999999999999999999 out of range 0-32767`,
+	}, msgs)
+	assert.True(t, strings.HasPrefix(err.Error(), f+":2:5: constant 40000 out of range 0-32767\n"+f+":3:8: "))
 }
@@ -1,6 +1,10 @@
 package main
 
 import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"os"
@@ -9,20 +13,48 @@ import (
 	assembler "github.com/kazufusa/nand2tetris/06_Assembler"
 )
 
+var (
+	sourceMap = flag.Bool("map", false, "also write a source map of ROM addresses to assembly lines to FILE.hack.map")
+	symbols   = flag.Bool("symbols", false, "also write the labels and variables with their addresses to FILE.sym")
+	listing   = flag.Bool("listing", false, "also write a listing of addresses, binary and source lines to FILE.lst")
+)
+
 func main() {
-	in := os.Args[1]
+	flag.Parse()
+	in := flag.Arg(0)
 	asm, err := assembler.NewAssembler(in)
 	if err != nil {
 		log.Fatal(err)
 	}
 	ret, err := asm.Assemble()
 	if err != nil {
-		log.Fatal(err)
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
 	ext := path.Ext(in)
-	out := os.Args[1][0:len(in)-len(ext)] + ".hack"
+	base := in[0 : len(in)-len(ext)]
+	out := base + ".hack"
 	err = ioutil.WriteFile(out, []byte(ret), 0644)
 	if err != nil {
 		log.Fatal(err)
 	}
+	if *sourceMap {
+		writeFile(out+".map", func(w io.Writer) error { return asm.WriteSourceMap(w, out) })
+	}
+	if *symbols {
+		writeFile(base+".sym", asm.WriteSymbols)
+	}
+	if *listing {
+		writeFile(base+".lst", asm.WriteListing)
+	}
+}
+
+func writeFile(f string, write func(w io.Writer) error) {
+	var buf bytes.Buffer
+	if err := write(&buf); err != nil {
+		log.Fatal(err)
+	}
+	if err := ioutil.WriteFile(f, buf.Bytes(), 0644); err != nil {
+		log.Fatal(err)
+	}
 }
@@ -1,5 +1,7 @@
 package assembler
 
+import "strings"
+
 var (
 	comp = map[string]string{
 		"0":  "0101010",
@@ -100,3 +102,43 @@ func (c *Code) Jump(s string) string {
 	}
 	return ""
 }
+
+var (
+	compMnemonics = invert(comp, nil)
+	// The canonical dest mnemonics name the registers in the order A, M, D.
+	destMnemonics = invert(dest, func(s string) bool {
+		return strings.Index("AMD", s) >= 0 || s == "AD"
+	})
+	jumpMnemonics = invert(jump, nil)
+)
+
+// invert maps bits back to mnemonics. Of several mnemonics for the same
+// bits, the canonical one wins.
+func invert(m map[string]string, canonical func(string) bool) map[string]string {
+	inv := make(map[string]string, len(m))
+	for mnemonic, bits := range m {
+		if _, ok := inv[bits]; !ok || canonical != nil && canonical(mnemonic) {
+			inv[bits] = mnemonic
+		}
+	}
+	return inv
+}
+
+// CompMnemonic returns the mnemonic of the a and c bits, e.g. "1110111"
+// gives "M+1". It is the inverse of Comp.
+func (c *Code) CompMnemonic(bits string) (string, bool) {
+	ret, ok := compMnemonics[bits]
+	return ret, ok
+}
+
+// DestMnemonic is the inverse of Dest, e.g. "011" gives "MD".
+func (c *Code) DestMnemonic(bits string) (string, bool) {
+	ret, ok := destMnemonics[bits]
+	return ret, ok
+}
+
+// JumpMnemonic is the inverse of Jump, e.g. "111" gives "JMP".
+func (c *Code) JumpMnemonic(bits string) (string, bool) {
+	ret, ok := jumpMnemonics[bits]
+	return ret, ok
+}
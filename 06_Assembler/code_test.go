@@ -1,6 +1,10 @@
 package assembler
 
-import "testing"
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
 
 func TestCodeComp(t *testing.T) {
 	code := Code{}
@@ -109,3 +113,26 @@ func TestCodeJump(t *testing.T) {
 		})
 	}
 }
+
+func TestCodeMnemonics(t *testing.T) {
+	code := Code{}
+	for mnemonic, bits := range comp {
+		m, ok := code.CompMnemonic(bits)
+		assert.True(t, ok)
+		assert.Equal(t, mnemonic, m)
+	}
+	for bits, want := range map[string]string{
+		"000": "", "001": "M", "010": "D", "011": "MD",
+		"100": "A", "101": "AM", "110": "AD", "111": "AMD",
+	} {
+		m, ok := code.DestMnemonic(bits)
+		assert.True(t, ok)
+		assert.Equal(t, want, m, bits)
+		assert.Equal(t, bits, code.Dest(m))
+	}
+	m, ok := code.JumpMnemonic("101")
+	assert.True(t, ok)
+	assert.Equal(t, "JNE", m)
+	_, ok = code.CompMnemonic("1111111")
+	assert.False(t, ok)
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+
+	assembler "github.com/kazufusa/nand2tetris/06_Assembler"
+	"github.com/kazufusa/nand2tetris/06_Assembler/disassembler"
+)
+
+var (
+	symbols   = flag.String("symbols", "", "name labels and variables after a symbol file of the assembler")
+	addresses = flag.Bool("addresses", false, "comment every instruction with its ROM address")
+	out       = flag.String("o", "", "write the assembly to this file instead of stdout")
+)
+
+func main() {
+	flag.Parse()
+	d, err := disassembler.NewDisassembler(flag.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *symbols != "" {
+		f, err := os.Open(*symbols)
+		if err != nil {
+			log.Fatal(err)
+		}
+		syms, err := assembler.ReadSymbols(f)
+		f.Close()
+		if err != nil {
+			log.Fatal(err)
+		}
+		d.SetSymbols(syms)
+	}
+	d.SetAddresses(*addresses)
+	ret := d.Disassemble()
+	for _, w := range d.Warnings() {
+		fmt.Fprintln(os.Stderr, w)
+	}
+	if *out == "" {
+		fmt.Print(ret)
+		return
+	}
+	if err := ioutil.WriteFile(*out, []byte(ret), 0644); err != nil {
+		log.Fatal(err)
+	}
+}
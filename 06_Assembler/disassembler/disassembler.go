@@ -0,0 +1,226 @@
+package disassembler
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	assembler "github.com/kazufusa/nand2tetris/06_Assembler"
+)
+
+// VARIABLE_SYMBOLS_STARTING_ADDR is the RAM address of the first variable
+// the assembler allocates.
+const VARIABLE_SYMBOLS_STARTING_ADDR = 16
+
+// predefined names the addresses of the predefined symbols. The registers
+// are only annotated where the next instruction accesses M.
+var predefined = map[int]string{
+	0: "SP", 1: "LCL", 2: "ARG", 3: "THIS", 4: "THAT",
+	5: "R5", 6: "R6", 7: "R7", 8: "R8", 9: "R9", 10: "R10",
+	11: "R11", 12: "R12", 13: "R13", 14: "R14", 15: "R15",
+	16384: "SCREEN", 24576: "KBD",
+}
+
+// instruction is a decoded word.
+type instruction struct {
+	word    uint16
+	text    string
+	ok      bool
+	jumps   bool
+	usesM   bool
+	isA     bool
+	comment []string
+}
+
+// Disassembler turns the words of a .hack file back into assembly.
+type Disassembler struct {
+	code      *assembler.Code
+	words     []uint16
+	symbols   []assembler.Symbol
+	addresses bool
+	warnings  []string
+}
+
+// NewDisassembler reads a .hack file of "0101..." lines.
+func NewDisassembler(f string) (*Disassembler, error) {
+	b, err := ioutil.ReadFile(f)
+	if err != nil {
+		return nil, err
+	}
+	words, err := ParseHack(string(b))
+	if err != nil {
+		return nil, fmt.Errorf("%s%v", f, err)
+	}
+	return &Disassembler{code: &assembler.Code{}, words: words}, nil
+}
+
+// ParseHack decodes the "0101..." lines of a .hack file. Blank lines are
+// skipped.
+func ParseHack(s string) ([]uint16, error) {
+	var words []uint16
+	for i, l := range strings.Split(s, "\n") {
+		l = strings.TrimSpace(l)
+		if l == "" {
+			continue
+		}
+		w, err := strconv.ParseUint(l, 2, 16)
+		if err != nil || len(l) != 16 {
+			return nil, fmt.Errorf(":%d: expected 16 binary digits, got %q", i+1, l)
+		}
+		words = append(words, uint16(w))
+	}
+	return words, nil
+}
+
+// SetSymbols makes Disassemble name labels and variables after syms, as far
+// as that keeps the output assembling to the same words.
+func (d *Disassembler) SetSymbols(syms []assembler.Symbol) {
+	d.symbols = append([]assembler.Symbol(nil), syms...)
+}
+
+// SetAddresses makes Disassemble append the ROM address of every
+// instruction as a comment.
+func (d *Disassembler) SetAddresses(on bool) {
+	d.addresses = on
+}
+
+// Warnings returns the problems of the last Disassemble, such as words
+// which have no assembly form.
+func (d *Disassembler) Warnings() []string {
+	return d.warnings
+}
+
+// Disassemble returns the program as assembly which assembles back to the
+// same words. Jump targets get labels, from the symbol map or else named
+// after their address like "L42". Words without an assembly form are
+// written in binary, so assembling the output reports them.
+func (d *Disassembler) Disassemble() string {
+	d.warnings = nil
+	insts := make([]instruction, len(d.words))
+	for i, w := range d.words {
+		insts[i] = d.decode(w)
+		if !insts[i].ok {
+			d.warnings = append(d.warnings, fmt.Sprintf("ROM[%d]: %016b has no assembly form", i, w))
+		}
+	}
+
+	labels := make(map[int][]string)
+	taken := make(map[string]bool)
+	for _, name := range predefined {
+		taken[name] = true
+	}
+	variables := make(map[int]string)
+	for _, s := range d.symbols {
+		if taken[s.Name] || s.Addr < 0 {
+			continue
+		}
+		taken[s.Name] = true
+		if s.RAM {
+			variables[s.Addr] = s.Name
+		} else if s.Addr <= len(insts) {
+			labels[s.Addr] = append(labels[s.Addr], s.Name)
+		}
+	}
+
+	// "@N" followed by a jump refers to the label at N.
+	for i := 0; i+1 < len(insts); i++ {
+		a, next := &insts[i], insts[i+1]
+		if !a.isA || !next.jumps || int(a.word) > len(insts) {
+			continue
+		}
+		target := int(a.word)
+		if len(labels[target]) == 0 {
+			name := fmt.Sprintf("L%d", target)
+			for taken[name] {
+				name += "_"
+			}
+			taken[name] = true
+			labels[target] = []string{name}
+		}
+		names := labels[target]
+		a.text = "@" + names[len(names)-1]
+	}
+
+	// A variable is named where it is first used only if the assembler
+	// allocates it the same address then.
+	next := VARIABLE_SYMBOLS_STARTING_ADDR
+	named := make(map[string]bool)
+	for i := 0; i+1 < len(insts); i++ {
+		a := &insts[i]
+		name, ok := variables[int(a.word)]
+		if !a.isA || a.text[1] < '0' || a.text[1] > '9' || !ok || !insts[i+1].usesM {
+			continue
+		}
+		if !named[name] {
+			if int(a.word) != next {
+				continue
+			}
+			named[name] = true
+			next++
+		}
+		a.text = "@" + name
+	}
+
+	for i := range insts {
+		a := &insts[i]
+		if !a.isA || a.text[1] < '0' || a.text[1] > '9' {
+			continue
+		}
+		name, ok := predefined[int(a.word)]
+		if ok && (a.word >= 16 || i+1 < len(insts) && insts[i+1].usesM) {
+			a.comment = append(a.comment, name)
+		}
+	}
+
+	var b strings.Builder
+	writeLabels := func(addr int) {
+		for _, name := range labels[addr] {
+			fmt.Fprintf(&b, "(%s)\n", name)
+		}
+	}
+	for i, inst := range insts {
+		writeLabels(i)
+		comment := inst.comment
+		if d.addresses {
+			comment = append([]string{fmt.Sprintf("ROM[%d]", i)}, comment...)
+		}
+		if !inst.ok {
+			comment = append(comment, "no assembly form")
+		}
+		if len(comment) == 0 {
+			fmt.Fprintf(&b, "    %s\n", inst.text)
+		} else {
+			fmt.Fprintf(&b, "    %-20s // %s\n", inst.text, strings.Join(comment, " "))
+		}
+	}
+	writeLabels(len(insts))
+	return b.String()
+}
+
+func (d *Disassembler) decode(w uint16) instruction {
+	if w&0x8000 == 0 {
+		return instruction{word: w, text: fmt.Sprintf("@%d", w), ok: true, isA: true}
+	}
+	bits := fmt.Sprintf("%016b", w)
+	comp, okc := d.code.CompMnemonic(bits[3:10])
+	dest, _ := d.code.DestMnemonic(bits[10:13])
+	jump, _ := d.code.JumpMnemonic(bits[13:16])
+	if bits[:3] != "111" || !okc {
+		return instruction{word: w, text: bits}
+	}
+	inst := instruction{
+		word:  w,
+		text:  comp,
+		ok:    true,
+		jumps: jump != "",
+		usesM: bits[3] == '1' || bits[12] == '1',
+	}
+	if dest != "" {
+		inst.text = dest + "=" + inst.text
+	}
+	if jump != "" {
+		inst.text += ";" + jump
+	}
+	return inst
+}
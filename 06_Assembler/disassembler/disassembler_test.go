@@ -0,0 +1,114 @@
+package disassembler
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	assembler "github.com/kazufusa/nand2tetris/06_Assembler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func assemble(t *testing.T, src string) string {
+	f := filepath.Join(t.TempDir(), "Prog.asm")
+	require.NoError(t, os.WriteFile(f, []byte(src), 0644))
+	asm, err := assembler.NewAssembler(f)
+	require.NoError(t, err)
+	ret, err := asm.Assemble()
+	require.NoError(t, err)
+	return ret
+}
+
+func disassemble(t *testing.T, hack string, syms []assembler.Symbol) string {
+	f := filepath.Join(t.TempDir(), "Prog.hack")
+	require.NoError(t, os.WriteFile(f, []byte(hack), 0644))
+	d, err := NewDisassembler(f)
+	require.NoError(t, err)
+	d.SetSymbols(syms)
+	ret := d.Disassemble()
+	assert.Empty(t, d.Warnings())
+	return ret
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, f := range []string{"../Max.asm", "../Rect.asm"} {
+		t.Run(filepath.Base(f), func(t *testing.T) {
+			src, err := os.ReadFile(f)
+			require.NoError(t, err)
+			hack := assemble(t, string(src))
+			assert.Equal(t, hack, assemble(t, disassemble(t, hack, nil)))
+		})
+	}
+}
+
+func TestDisassemble(t *testing.T) {
+	hack := assemble(t, `
+		@i
+		M=1
+	(LOOP)
+		@i
+		D=M
+		@R0
+		D=D-M
+		@END
+		D;JGT
+		@i
+		M=M+1
+		@SCREEN
+		D=A
+		@LOOP
+		0;JMP
+	(END)
+		@END
+		0;JMP
+	`)
+	expected := `    @16
+    M=1
+(L2)
+    @16
+    D=M
+    @0                   // SP
+    D=D-M
+    @L14
+    D;JGT
+    @16
+    M=M+1
+    @16384               // SCREEN
+    D=A
+    @L2
+    0;JMP
+(L14)
+    @L14
+    0;JMP
+`
+	assert.Equal(t, expected, disassemble(t, hack, nil))
+
+	syms, err := assembler.ReadSymbols(strings.NewReader("LOOP 2\nEND 14 ROM\ni 16 RAM\nj 17 RAM\nSP 3\n"))
+	require.NoError(t, err)
+	ret := disassemble(t, hack, syms)
+	assert.Contains(t, ret, "(LOOP)\n    @i\n    D=M\n")
+	assert.Contains(t, ret, "    @END\n    D;JGT\n")
+	assert.NotContains(t, ret, "@j")
+	assert.Equal(t, hack, assemble(t, ret))
+}
+
+func TestDisassembleVariablesKeepAddresses(t *testing.T) {
+	hack := assemble(t, "@17\nM=0\n@16\nM=0\n")
+	ret := disassemble(t, hack, []assembler.Symbol{{Name: "x", Addr: 16, RAM: true}, {Name: "y", Addr: 17, RAM: true}})
+	assert.Equal(t, "    @17\n    M=0\n    @x\n    M=0\n", ret)
+	assert.Equal(t, hack, assemble(t, ret))
+}
+
+func TestDisassembleInvalid(t *testing.T) {
+	words, err := ParseHack("0000000000000001\n1010101010101010\n")
+	require.NoError(t, err)
+	d := &Disassembler{code: &assembler.Code{}, words: words}
+	d.SetAddresses(true)
+	assert.Equal(t, "    @1                   // ROM[0]\n    1010101010101010     // ROM[1] no assembly form\n", d.Disassemble())
+	assert.Equal(t, []string{"ROM[1]: 1010101010101010 has no assembly form"}, d.Warnings())
+
+	_, err = ParseHack("0000000000000001\n01\n")
+	assert.EqualError(t, err, `:2: expected 16 binary digits, got "01"`)
+}
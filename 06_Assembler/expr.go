@@ -0,0 +1,218 @@
+package assembler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// exprError is an error at byte off of an expression.
+type exprError struct {
+	off int
+	msg string
+}
+
+func (e *exprError) Error() string {
+	return e.msg
+}
+
+// evaluator parses and evaluates a constant expression by recursive
+// descent, with the operators and precedence of C:
+//
+//	|  ^  &  << >>  + -  * / %  unary - + ~  ( )
+//
+// The operands are decimal, 0x hexadecimal and 0b binary numbers up to
+// MAX_CONSTANT, character literals like 'A', in which \\ and \' escape a
+// backslash and a quote, and symbols.
+type evaluator struct {
+	s      string
+	off    int
+	lookup func(name string) (int, bool)
+}
+
+// MIN_VALUE and MAX_VALUE bound the intermediate results of an expression
+// to what 16 bits hold, signed or unsigned.
+const (
+	MIN_VALUE = -1 << 15
+	MAX_VALUE = 1<<16 - 1
+)
+
+// evaluate evaluates s, resolving symbols with lookup.
+func evaluate(s string, lookup func(name string) (int, bool)) (n int64, err error) {
+	e := &evaluator{s: s, lookup: lookup}
+	defer func() {
+		if r := recover(); r != nil {
+			xe, ok := r.(*exprError)
+			if !ok {
+				panic(r)
+			}
+			err = xe
+		}
+	}()
+	n = e.binary(0)
+	if e.off < len(s) {
+		e.fail(e.off, "unexpected %q", s[e.off:e.off+1])
+	}
+	return n, nil
+}
+
+func (e *evaluator) fail(off int, format string, a ...interface{}) {
+	panic(&exprError{off: off, msg: fmt.Sprintf(format, a...)})
+}
+
+// levels holds the binary operators from the lowest precedence up.
+var levels = [][]string{{"|"}, {"^"}, {"&"}, {"<<", ">>"}, {"+", "-"}, {"*", "/", "%"}}
+
+// binary parses the operators of levels[level] and above.
+func (e *evaluator) binary(level int) int64 {
+	if level == len(levels) {
+		return e.unary()
+	}
+	x := e.binary(level + 1)
+	for {
+		op, off := e.operator(levels[level]), e.off
+		if op == "" {
+			return x
+		}
+		y := e.binary(level + 1)
+		switch op {
+		case "|":
+			x |= y
+		case "^":
+			x ^= y
+		case "&":
+			x &= y
+		case "<<", ">>":
+			if y < 0 || y > 15 {
+				e.fail(off, "shift count %d out of range 0-15", y)
+			}
+			if op == "<<" {
+				x <<= uint(y)
+			} else {
+				x >>= uint(y)
+			}
+		case "+":
+			x += y
+		case "-":
+			x -= y
+		case "*":
+			x *= y
+		case "/", "%":
+			if y == 0 {
+				e.fail(off, "division by zero")
+			}
+			if op == "/" {
+				x /= y
+			} else {
+				x %= y
+			}
+		}
+		if x < MIN_VALUE || x > MAX_VALUE {
+			e.fail(off, "intermediate value %d out of 16-bit range %d-%d", x, MIN_VALUE, MAX_VALUE)
+		}
+	}
+}
+
+// operator consumes and returns the first of ops at the current offset.
+func (e *evaluator) operator(ops []string) string {
+	for _, op := range ops {
+		if strings.HasPrefix(e.s[e.off:], op) {
+			e.off += len(op)
+			return op
+		}
+	}
+	return ""
+}
+
+func (e *evaluator) unary() int64 {
+	switch op := e.operator([]string{"-", "+", "~"}); op {
+	case "-":
+		return -e.unary()
+	case "+":
+		return e.unary()
+	case "~":
+		return ^e.unary()
+	}
+	return e.primary()
+}
+
+func (e *evaluator) primary() int64 {
+	start := e.off
+	if start == len(e.s) {
+		e.fail(start, "missing operand")
+	}
+	c := e.s[start]
+	switch {
+	case c == '(':
+		e.off++
+		x := e.binary(0)
+		if e.operator([]string{")"}) == "" {
+			e.fail(e.off, "missing )")
+		}
+		return x
+	case c == '\'':
+		return e.char()
+	case c >= '0' && c <= '9':
+		for e.off < len(e.s) && isSymbolChar(e.s[e.off]) {
+			e.off++
+		}
+		return e.number(start, e.s[start:e.off])
+	case isSymbolChar(c):
+		for e.off < len(e.s) && isSymbolChar(e.s[e.off]) {
+			e.off++
+		}
+		name := e.s[start:e.off]
+		addr, ok := e.lookup(name)
+		if !ok {
+			e.fail(start, "undefined symbol %q", name)
+		}
+		return int64(addr)
+	}
+	e.fail(start, "unexpected %q", e.s[start:start+1])
+	return 0
+}
+
+func (e *evaluator) number(off int, lit string) int64 {
+	digits, base := lit, 10
+	switch {
+	case strings.HasPrefix(lit, "0x"), strings.HasPrefix(lit, "0X"):
+		digits, base = lit[2:], 16
+	case strings.HasPrefix(lit, "0b"), strings.HasPrefix(lit, "0B"):
+		digits, base = lit[2:], 2
+	}
+	n, err := strconv.ParseInt(digits, base, 64)
+	if ne, ok := err.(*strconv.NumError); ok && ne.Err != strconv.ErrRange || digits == "" {
+		e.fail(off, "malformed constant %q", lit)
+	}
+	if err != nil || n > MAX_CONSTANT {
+		e.fail(off, "constant %s out of range 0-%d", lit, MAX_CONSTANT)
+	}
+	return n
+}
+
+// char parses a character literal: a printable character, or \' or \\.
+func (e *evaluator) char() int64 {
+	start := e.off
+	end := strings.IndexByte(e.s[start+1:], '\'') + start + 1
+	if strings.HasPrefix(e.s[start:], `'\''`) {
+		end = start + 3
+	}
+	lit := e.s[start:]
+	if end > start {
+		lit = e.s[start : end+1]
+	}
+	body := strings.TrimSuffix(strings.TrimPrefix(lit, "'"), "'")
+	if body == `\'` || body == `\\` {
+		body = body[1:]
+	}
+	if end <= start || len(body) != 1 || body[0] < ' ' || body[0] > '~' {
+		e.fail(start, "malformed character literal %s", lit)
+	}
+	e.off = end + 1
+	return int64(body[0])
+}
+
+func isSymbolChar(c byte) bool {
+	return c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' ||
+		strings.IndexByte("_.$:", c) >= 0
+}
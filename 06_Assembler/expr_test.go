@@ -0,0 +1,125 @@
+package assembler
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvaluate(t *testing.T) {
+	symbols := map[string]int{"SCREEN": 16384, "row": 3, "LOOP": 10}
+	lookup := func(name string) (int, bool) {
+		addr, ok := symbols[name]
+		return addr, ok
+	}
+	for s, expected := range map[string]int64{
+		"42":            42,
+		"0x4000":        16384,
+		"0X7fff":        32767,
+		"0b101":         5,
+		"'A'":           65,
+		"' '":           32,
+		`'\''`:          39,
+		`'\\'`:          92,
+		"SCREEN+32*row": 16480,
+		"LOOP-1":        9,
+		"(1+2)*3":       9,
+		"1+2*3":         7,
+		"-1":            -1,
+		"--1":           1,
+		"~0&0x7fff":     32767,
+		"1<<14|3":       16387,
+		"0x4000>>2^1":   4097,
+		"17/5+17%5":     5,
+	} {
+		n, err := evaluate(s, lookup)
+		if assert.NoError(t, err, s) {
+			assert.Equal(t, expected, n, s)
+		}
+	}
+
+	for s, expected := range map[string]string{
+		"":        "0: missing operand",
+		"1+":      "2: missing operand",
+		"(1+2":    "4: missing )",
+		"1)":      "1: unexpected \")\"",
+		"nope+1":  "0: undefined symbol \"nope\"",
+		"2+0x":    "2: malformed constant \"0x\"",
+		"0b102":   "0: malformed constant \"0b102\"",
+		"1abc":    "0: malformed constant \"1abc\"",
+		"1+40000": "2: constant 40000 out of range 0-32767",
+		"'ab'":    "0: malformed character literal 'ab'",
+		"'a":      "0: malformed character literal 'a",
+		"1/0":     "2: division by zero",
+		"1<<16":   "3: shift count 16 out of range 0-15",
+		"1#2":     "1: unexpected \"#\"",
+
+		"16384*16384*16384*16384*16": "6: intermediate value 268435456 out of 16-bit range -32768-65535",
+		"0-32767-32767":              "8: intermediate value -65534 out of 16-bit range -32768-65535",
+	} {
+		_, err := evaluate(s, lookup)
+		if assert.Error(t, err, s) {
+			e := err.(*exprError)
+			assert.Equal(t, expected, fmt.Sprintf("%d: %s", e.off, e.msg), s)
+		}
+	}
+}
+
+func TestAssembleExpressions(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "Expr.asm")
+	require.NoError(t, os.WriteFile(f, []byte(`.equ ROW 2
+.equ THREE 1 + 2
+.equ NINE THREE*3
+.equ BLANK ' '
+(LOOP)
+    @SCREEN + 32 * ROW
+    @LOOP+1
+    @0x10
+    @0b11
+    @ ' '
+    @x
+    @x+1
+    @KBD-0x4000
+    @THREE*3
+    @NINE
+    @BLANK
+`), 0644))
+	asm, err := NewAssembler(f)
+	require.NoError(t, err)
+	ret, err := asm.Assemble()
+	require.NoError(t, err)
+	assert.Equal(t, strings.Join([]string{
+		"0100000001000000",
+		"0000000000000001",
+		"0000000000010000",
+		"0000000000000011",
+		"0000000000100000",
+		"0000000000010000",
+		"0000000000010001",
+		"0010000000000000",
+		"0000000000001001",
+		"0000000000001001",
+		"0000000000100000",
+	}, "\n")+"\n", ret)
+
+	require.NoError(t, os.WriteFile(f, []byte(`@KBD+0x2000
+  @y+1
+@y
+@SCREEN - 0x8000
+@(1
+`), 0644))
+	asm, err = NewAssembler(f)
+	require.NoError(t, err)
+	_, err = asm.Assemble()
+	assert.Equal(t, strings.Join([]string{
+		f + ":1:2: KBD+0x2000 evaluates to 32768, out of range 0-32767",
+		f + `:2:4: undefined symbol "y"`,
+		f + ":4:11: constant 0x8000 out of range 0-32767",
+		f + ":5:4: missing )",
+	}, "\n"), err.Error())
+}
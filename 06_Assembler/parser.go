@@ -14,8 +14,6 @@ const (
 )
 
 var (
-	reComment = regexp.MustCompile(`//[^\n]*`)
-
 	reA = regexp.MustCompile(`@(.+)`)
 	reL = regexp.MustCompile(`^\((.+)\)$`)
 
@@ -38,22 +36,63 @@ type IParser interface {
 
 type Parser struct {
 	lines []string
-	count int
+	// files and lineNos hold the source file and 1-based line of each of
+	// lines, origins the line of the main file it was expanded from and
+	// cols the 1-based source column of each of their bytes.
+	files   []string
+	lineNos []int
+	origins []int
+	cols    [][]int
+	count   int
 }
 
 var _ IParser = (*Parser)(nil)
 
+// NewParser splits s into instructions without comments and white space,
+// remembering where each of them came from.
 func NewParser(s string) *Parser {
-	s = reComment.ReplaceAllString(s, "")
-	s = strings.ReplaceAll(s, "\r", "")
-	s = strings.ReplaceAll(s, " ", "")
-	lines := strings.Split(s, "\n")
-	for i := len(lines) - 1; i >= 0; i-- {
-		if lines[i] == "" {
-			lines = append(lines[:i], lines[i+1:]...)
+	return newParser(splitLines("", s))
+}
+
+// newParser parses the lines of the preprocessor.
+func newParser(src []sourceLine) *Parser {
+	p := &Parser{}
+	for _, sl := range src {
+		l := sl.Text
+		if j := strings.Index(l, "//"); j >= 0 {
+			l = l[:j]
+		}
+		var b strings.Builder
+		var cols []int
+		quoted := false
+		for j := 0; j < len(l); j++ {
+			switch l[j] {
+			case ' ', '\t', '\r':
+				if !quoted {
+					continue
+				}
+			case '\'':
+				// White space is kept in a character literal like ' '.
+				quoted = !quoted
+			case '\\':
+				if quoted && j+1 < len(l) {
+					b.WriteByte(l[j])
+					cols = append(cols, j+1)
+					j++
+				}
+			}
+			b.WriteByte(l[j])
+			cols = append(cols, j+1)
+		}
+		if b.Len() > 0 {
+			p.lines = append(p.lines, b.String())
+			p.files = append(p.files, sl.File)
+			p.lineNos = append(p.lineNos, sl.Line)
+			p.origins = append(p.origins, sl.origin)
+			p.cols = append(p.cols, cols)
 		}
 	}
-	return &Parser{lines: lines}
+	return p
 }
 
 func (p *Parser) HasMoreLines() bool {
@@ -66,6 +105,51 @@ func (p *Parser) Advance() {
 	}
 }
 
+// LineNumber returns the source line of the current instruction, or 0 if
+// it is unknown.
+func (p *Parser) LineNumber() int {
+	if p.count < len(p.lineNos) {
+		return p.lineNos[p.count]
+	}
+	return 0
+}
+
+// File returns the source file of the current instruction.
+func (p *Parser) File() string {
+	if p.count < len(p.files) {
+		return p.files[p.count]
+	}
+	return ""
+}
+
+// origin returns the line of the main file the current instruction was
+// expanded from.
+func (p *Parser) origin() int {
+	if p.count < len(p.origins) {
+		return p.origins[p.count]
+	}
+	return 0
+}
+
+// Column returns the source column of byte off of the current instruction;
+// an offset at its end gives the column after it. It returns 0 if the column
+// is unknown.
+func (p *Parser) Column(off int) int {
+	if p.count >= len(p.cols) || len(p.cols[p.count]) == 0 {
+		return 0
+	}
+	cols := p.cols[p.count]
+	if off < len(cols) {
+		return cols[off]
+	}
+	return cols[len(cols)-1] + 1
+}
+
+// text returns the current instruction without white space.
+func (p *Parser) text() string {
+	return p.lines[p.count]
+}
+
 func (p *Parser) InstructionType() InstructionType {
 	l := p.lines[p.count]
 	switch []rune(l)[0] {
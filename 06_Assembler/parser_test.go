@@ -18,6 +18,14 @@ func TestNewParser(t *testing.T) {
 `)
 	expected := []string{"@123", "D=A", "@17", "M=D"}
 	assert.Equal(t, expected, parser.lines)
+	assert.Equal(t, []int{3, 4, 6, 7}, parser.lineNos)
+	assert.Equal(t, 3, parser.Column(0))
+	parser.Advance()
+	assert.Equal(t, 4, parser.LineNumber())
+	// "  D =  A // comment"
+	assert.Equal(t, []int{3, 5, 8}, parser.cols[1])
+	assert.Equal(t, 8, parser.Column(2))
+	assert.Equal(t, 9, parser.Column(3), "the end of the instruction")
 }
 
 func TestParser(t *testing.T) {
@@ -0,0 +1,391 @@
+package assembler
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// MAX_MACRO_DEPTH bounds nested macro expansions, so that a macro invoking
+// itself is reported rather than expanded forever.
+const MAX_MACRO_DEPTH = 64
+
+// reToken matches a character literal, a symbol or a number, or with a
+// leading backslash a macro parameter.
+var reToken = regexp.MustCompile(`'(\\.|[^'])*'|\\?[A-Za-z0-9_.$:]+`)
+
+// sourceLine is a line of the program with the place it came from and the
+// line of the main file it was expanded from.
+type sourceLine struct {
+	File   string
+	Line   int
+	Text   string
+	origin int
+}
+
+func splitLines(file, s string) []sourceLine {
+	var lines []sourceLine
+	for i, l := range strings.Split(s, "\n") {
+		lines = append(lines, sourceLine{File: file, Line: i + 1, Text: l, origin: i + 1})
+	}
+	return lines
+}
+
+type macro struct {
+	name   string
+	params []string
+	body   []sourceLine
+	pos    Position
+}
+
+// cond is an open .if, .ifdef or .ifndef.
+type cond struct {
+	directive string
+	pos       Position
+	// active tells whether the current branch is assembled and taken
+	// whether any branch has been.
+	active  bool
+	taken   bool
+	hasElse bool
+}
+
+// preprocessor expands the directives of a program into plain Hack
+// assembly:
+//
+//	.equ NAME VALUE          replaces NAME after @ with VALUE, in
+//	                         parentheses if it is an expression
+//	.macro NAME [P1, P2...]  defines a macro up to .endm, in which \P1 is
+//	                         replaced by the argument; labels defined in
+//	                         the body are local to every expansion
+//	NAME [A1, A2...]         expands a macro
+//	.include "FILE"          assembles FILE, relative to the current file
+//	.ifdef NAME, .ifndef NAME, .if VALUE, .else, .endif
+//
+// NAME is defined by .equ or .macro, so a file guards against being
+// included twice with ".ifndef FILE_ASM", ".equ FILE_ASM 1" and ".endif".
+type preprocessor struct {
+	equs   map[string]string
+	macros map[string]*macro
+	st     *SymbolTable
+	// defining is the macro whose body is being read.
+	defining   *macro
+	conds      []cond
+	includes   []string
+	expansions int
+	out        []sourceLine
+	errs       ErrorList
+}
+
+func newPreprocessor() *preprocessor {
+	return &preprocessor{
+		equs:   make(map[string]string),
+		macros: make(map[string]*macro),
+		st:     NewSymbolTable(),
+	}
+}
+
+func (pp *preprocessor) errorf(sl sourceLine, col int, format string, a ...interface{}) {
+	pp.errs = append(pp.errs, &Error{
+		Pos: Position{File: sl.File, Line: sl.Line, Col: col},
+		Msg: fmt.Sprintf(format, a...),
+	})
+}
+
+func (pp *preprocessor) active() bool {
+	for _, c := range pp.conds {
+		if !c.active {
+			return false
+		}
+	}
+	return true
+}
+
+// file preprocesses the source src of file f. Lines of an included file are
+// given the origin of the .include.
+func (pp *preprocessor) file(f, src string, origin int) {
+	pp.includes = append(pp.includes, f)
+	conds := len(pp.conds)
+	for _, sl := range splitLines(f, src) {
+		if origin > 0 {
+			sl.origin = origin
+		}
+		pp.line(sl, 0)
+	}
+	if m := pp.defining; m != nil {
+		pp.errs = append(pp.errs, &Error{Pos: m.pos, Msg: fmt.Sprintf(".macro %s without .endm", m.name)})
+		pp.defining = nil
+	}
+	pp.closeConds(conds)
+	pp.includes = pp.includes[:len(pp.includes)-1]
+}
+
+// closeConds reports and drops the conditionals opened above depth n.
+func (pp *preprocessor) closeConds(n int) {
+	for len(pp.conds) > n {
+		c := pp.conds[len(pp.conds)-1]
+		pp.errs = append(pp.errs, &Error{Pos: c.pos, Msg: fmt.Sprintf("%s without .endif", c.directive)})
+		pp.conds = pp.conds[:len(pp.conds)-1]
+	}
+}
+
+func (pp *preprocessor) line(sl sourceLine, depth int) {
+	code := sl.Text
+	if i := strings.Index(code, "//"); i >= 0 {
+		code = code[:i]
+	}
+	fields := strings.Fields(code)
+	col := fieldCol(code, 0)
+	if m := pp.defining; m != nil {
+		switch {
+		case len(fields) > 0 && fields[0] == ".endm":
+			if m.name != "" {
+				pp.macros[m.name] = m
+			}
+			pp.defining = nil
+		case len(fields) > 0 && fields[0] == ".macro":
+			pp.errorf(sl, col, ".macro inside the definition of macro %s", m.name)
+		default:
+			m.body = append(m.body, sl)
+		}
+		return
+	}
+	switch {
+	case len(fields) == 0:
+	case strings.HasPrefix(fields[0], "."):
+		pp.directive(sl, code, fields, col)
+	case !pp.active():
+	case pp.macros[fields[0]] != nil:
+		pp.expand(pp.macros[fields[0]], sl, code, col, depth)
+	default:
+		pp.out = append(pp.out, pp.substitute(sl, code, col))
+	}
+}
+
+// substitute replaces the constants after the @ of an A-instruction.
+func (pp *preprocessor) substitute(sl sourceLine, code string, col int) sourceLine {
+	text := strings.TrimSpace(code)
+	switch {
+	case strings.HasPrefix(text, "@"):
+		i := strings.Index(code, "@") + 1
+		sl.Text = code[:i] + pp.replaceEqus(code[i:])
+	case reL.MatchString(text):
+		if name := reL.FindStringSubmatch(text)[1]; pp.defined(name) {
+			pp.errorf(sl, col+1, "label %s redefines a constant or macro", name)
+		}
+	}
+	return sl
+}
+
+func (pp *preprocessor) replaceEqus(s string) string {
+	return reToken.ReplaceAllStringFunc(s, func(tok string) string {
+		if v, ok := pp.equs[tok]; ok {
+			return v
+		}
+		return tok
+	})
+}
+
+// defined tells whether name is a constant or a macro.
+func (pp *preprocessor) defined(name string) bool {
+	_, equ := pp.equs[name]
+	return equ || pp.macros[name] != nil
+}
+
+// checkName reports whether name can be defined as a constant or a macro.
+func (pp *preprocessor) checkName(sl sourceLine, col int, kind, name string) bool {
+	switch {
+	case !reSymbol.MatchString(name):
+		pp.errorf(sl, col, "malformed %s name %q", kind, name)
+	case pp.defined(name):
+		pp.errorf(sl, col, "%s %s redefined", kind, name)
+	case pp.st.Contains(name):
+		pp.errorf(sl, col, "%s %s redefines a predefined symbol", kind, name)
+	default:
+		return true
+	}
+	return false
+}
+
+func (pp *preprocessor) directive(sl sourceLine, code string, fields []string, col int) {
+	arg := strings.TrimSpace(code[strings.Index(code, fields[0])+len(fields[0]):])
+	argCol := fieldCol(code, 1)
+	if !pp.active() {
+		switch fields[0] {
+		case ".if", ".ifdef", ".ifndef":
+			pp.conds = append(pp.conds, cond{directive: fields[0], pos: Position{sl.File, sl.Line, col}, taken: true})
+		case ".else", ".endif":
+			pp.endBranch(sl, fields[0], col)
+		}
+		return
+	}
+	switch fields[0] {
+	case ".equ":
+		if len(fields) < 3 {
+			pp.errorf(sl, col, "expected .equ NAME VALUE")
+			return
+		}
+		if pp.checkName(sl, argCol, "constant", fields[1]) {
+			// An expression is parenthesized to keep its precedence where
+			// it is substituted.
+			v := pp.replaceEqus(strings.Join(fields[2:], " "))
+			if reToken.FindString(v) != v {
+				v = "(" + v + ")"
+			}
+			pp.equs[fields[1]] = v
+		}
+	case ".macro":
+		if len(fields) < 2 {
+			pp.errorf(sl, col, "expected .macro NAME [PARAM, ...]")
+			return
+		}
+		m := &macro{name: fields[1], pos: Position{sl.File, sl.Line, col}}
+		if params := strings.TrimSpace(arg[len(fields[1]):]); params != "" {
+			for _, p := range strings.Split(params, ",") {
+				p = strings.TrimSpace(p)
+				if !reSymbol.MatchString(p) {
+					pp.errorf(sl, col, "malformed parameter %q of macro %s", p, m.name)
+				}
+				m.params = append(m.params, p)
+			}
+		}
+		if !pp.checkName(sl, argCol, "macro", m.name) {
+			m.name = ""
+		}
+		pp.defining = m
+	case ".endm":
+		pp.errorf(sl, col, ".endm without .macro")
+	case ".include":
+		pp.include(sl, strings.Trim(arg, `"`), col)
+	case ".ifdef", ".ifndef":
+		if len(fields) != 2 {
+			pp.errorf(sl, col, "expected %s NAME", fields[0])
+			return
+		}
+		v := pp.defined(fields[1]) == (fields[0] == ".ifdef")
+		pp.conds = append(pp.conds, cond{directive: fields[0], pos: Position{sl.File, sl.Line, col}, active: v, taken: v})
+	case ".if":
+		n, err := evaluate(strings.Join(strings.Fields(pp.replaceEqus(arg)), ""), func(string) (int, bool) {
+			return 0, false
+		})
+		if err != nil {
+			pp.errorf(sl, argCol, "%v in condition %q", err, arg)
+		}
+		pp.conds = append(pp.conds, cond{directive: ".if", pos: Position{sl.File, sl.Line, col}, active: n != 0, taken: n != 0})
+	case ".else", ".endif":
+		pp.endBranch(sl, fields[0], col)
+	default:
+		pp.errorf(sl, col, "unknown directive %s", fields[0])
+	}
+}
+
+// endBranch handles an .else or an .endif.
+func (pp *preprocessor) endBranch(sl sourceLine, directive string, col int) {
+	if len(pp.conds) == 0 {
+		pp.errorf(sl, col, "%s without .if", directive)
+		return
+	}
+	c := &pp.conds[len(pp.conds)-1]
+	switch {
+	case directive == ".endif":
+		pp.conds = pp.conds[:len(pp.conds)-1]
+	case c.hasElse:
+		pp.errorf(sl, col, "duplicate .else; previous %s at %s", c.directive, c.pos)
+	default:
+		c.hasElse = true
+		c.active = !c.taken
+		c.taken = true
+	}
+}
+
+func (pp *preprocessor) include(sl sourceLine, name string, col int) {
+	if name == "" {
+		pp.errorf(sl, col, `expected .include "FILE"`)
+		return
+	}
+	f := filepath.Join(filepath.Dir(sl.File), name)
+	for _, g := range pp.includes {
+		if g == f {
+			pp.errorf(sl, col, "%s includes itself", f)
+			return
+		}
+	}
+	b, err := ioutil.ReadFile(f)
+	if err != nil {
+		pp.errorf(sl, col, "cannot include %s: %v", name, err)
+		return
+	}
+	pp.file(f, string(b), sl.origin)
+}
+
+// expand expands the invocation of m on sl. Every expansion renames the
+// labels defined in the body to NAME$LABEL.N, N counting the expansions.
+func (pp *preprocessor) expand(m *macro, sl sourceLine, code string, col, depth int) {
+	if depth >= MAX_MACRO_DEPTH {
+		pp.errorf(sl, col, "macro %s nested more than %d deep", m.name, MAX_MACRO_DEPTH)
+		return
+	}
+	var args []string
+	if rest := strings.TrimSpace(code[strings.Index(code, m.name)+len(m.name):]); rest != "" {
+		for _, a := range strings.Split(rest, ",") {
+			args = append(args, strings.TrimSpace(a))
+		}
+	}
+	if len(args) != len(m.params) {
+		pp.errorf(sl, col, "macro %s takes %d arguments, got %d", m.name, len(m.params), len(args))
+		return
+	}
+	params := make(map[string]string)
+	for i, p := range m.params {
+		params[p] = args[i]
+	}
+	locals := make(map[string]string)
+	for _, b := range m.body {
+		text := strings.Join(strings.Fields(b.Text), "")
+		if l := reL.FindStringSubmatch(text); l != nil && reSymbol.MatchString(l[1]) {
+			locals[l[1]] = fmt.Sprintf("%s$%s.%d", m.name, l[1], pp.expansions)
+		}
+	}
+	pp.expansions++
+
+	conds := len(pp.conds)
+	for _, b := range m.body {
+		if i := strings.Index(b.Text, "//"); i >= 0 {
+			b.Text = b.Text[:i]
+		}
+		b.Text = reToken.ReplaceAllStringFunc(b.Text, func(tok string) string {
+			if strings.HasPrefix(tok, "'") {
+				return tok
+			}
+			if strings.HasPrefix(tok, `\`) {
+				if v, ok := params[tok[1:]]; ok {
+					return v
+				}
+			} else if v, ok := locals[tok]; ok {
+				return v
+			}
+			return tok
+		})
+		b.origin = sl.origin
+		pp.line(b, depth+1)
+	}
+	pp.closeConds(conds)
+}
+
+// fieldCol returns the 1-based column of field n of code, or the column
+// after code if it has fewer fields.
+func fieldCol(code string, n int) int {
+	inField := false
+	for i := 0; i < len(code); i++ {
+		space := code[i] == ' ' || code[i] == '\t' || code[i] == '\r'
+		if !space && !inField {
+			if n == 0 {
+				return i + 1
+			}
+			n--
+		}
+		inField = !space
+	}
+	return len(code) + 1
+}
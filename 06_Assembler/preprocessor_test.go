@@ -0,0 +1,189 @@
+package assembler
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const stackAsm = `.ifndef STACK_ASM
+.equ STACK_ASM 1
+
+// PUSH_D pushes D.
+.macro PUSH_D
+    @SP
+    AM=M+1
+    A=A-1
+    M=D
+.endm
+
+.macro PUSH_CONST n
+    @\n
+    D=A
+    PUSH_D
+.endm
+.endif
+`
+
+const mainAsm = `.include "lib/stack.asm"
+.include "lib/stack.asm"
+.equ SIZE 3
+.equ LIMIT SIZE
+
+// COUNT counts \reg down to zero.
+.macro COUNT reg
+(LOOP)
+    @\reg
+    M=M-1
+    D=M
+    @LOOP
+    D;JGT
+.endm
+
+(LOOP)
+    PUSH_CONST LIMIT
+.ifdef PUSH_D
+    COUNT R13
+.else
+    COUNT R14
+.endif
+.if 0
+    @999
+.endif
+    COUNT R15
+    @LOOP
+    0;JMP
+`
+
+const expandedAsm = `(LOOP)
+    @3
+    D=A
+    @SP
+    AM=M+1
+    A=A-1
+    M=D
+(COUNT$LOOP.2)
+    @R13
+    M=M-1
+    D=M
+    @COUNT$LOOP.2
+    D;JGT
+(COUNT$LOOP.3)
+    @R15
+    M=M-1
+    D=M
+    @COUNT$LOOP.3
+    D;JGT
+    @LOOP
+    0;JMP
+`
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, src := range files {
+		f := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(f), 0755))
+		require.NoError(t, os.WriteFile(f, []byte(src), 0644))
+	}
+	return dir
+}
+
+func assembleFile(t *testing.T, f string) (*Assembler, string, error) {
+	asm, err := NewAssembler(f)
+	require.NoError(t, err)
+	ret, err := asm.Assemble()
+	return asm, ret, err
+}
+
+func TestPreprocessor(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"Main.asm":      mainAsm,
+		"lib/stack.asm": stackAsm,
+		"Expanded.asm":  expandedAsm,
+	})
+	asm, ret, err := assembleFile(t, filepath.Join(dir, "Main.asm"))
+	require.NoError(t, err)
+	_, expected, err := assembleFile(t, filepath.Join(dir, "Expanded.asm"))
+	require.NoError(t, err)
+	assert.Equal(t, expected, ret)
+
+	var buf bytes.Buffer
+	require.NoError(t, asm.WriteSourceMap(&buf, filepath.Join(dir, "Main.hack")))
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	assert.Len(t, lines, 18)
+	assert.Equal(t, "Main.hack:1 lib/stack.asm:13", lines[0], "the body of PUSH_CONST")
+	assert.Equal(t, "Main.hack:3 lib/stack.asm:6", lines[2], "the body of PUSH_D")
+	assert.Equal(t, "Main.hack:18 Main.asm:28", lines[17])
+
+	buf.Reset()
+	require.NoError(t, asm.WriteListing(&buf))
+	lines = strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	assert.Equal(t, []string{
+		"                            17      PUSH_CONST LIMIT",
+		"    0  0000000000000011         + @3",
+		"    1  1110110000010000         + D=A",
+		"    2  0000000000000000         + @SP",
+		"    3  1111110111101000         + AM=M+1",
+		"    4  1110110010100000         + A=A-1",
+		"    5  1110001100001000         + M=D",
+	}, lines[16:23])
+	assert.Contains(t, lines, "   17  1110101010000111     28      0;JMP")
+}
+
+func TestPreprocessorErrors(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"Bad.asm": `.equ SP 1
+.equ N
+.equ N 1
+.equ N 2
+(N)
+.macro M2 a, 1b
+.endm
+.macro REC
+    REC
+.endm
+    REC
+    M2 1
+.bogus
+.endm
+.else
+.endif
+.include "Missing.asm"
+.include "Bad.asm"
+.if N + X
+.else
+.else
+.endif
+.ifdef
+.macro OPEN
+`,
+	})
+	_, _, err := assembleFile(t, filepath.Join(dir, "Bad.asm"))
+	require.Error(t, err)
+	f := filepath.Join(dir, "Bad.asm")
+	expected := []string{
+		f + ":1:6: constant SP redefines a predefined symbol",
+		f + ":2:1: expected .equ NAME VALUE",
+		f + ":4:6: constant N redefined",
+		f + ":5:2: label N redefines a constant or macro",
+		f + `:6:1: malformed parameter "1b" of macro M2`,
+		f + ":9:5: macro REC nested more than 64 deep",
+		f + ":12:5: macro M2 takes 2 arguments, got 1",
+		f + ":13:1: unknown directive .bogus",
+		f + ":14:1: .endm without .macro",
+		f + ":15:1: .else without .if",
+		f + ":16:1: .endif without .if",
+		f + `:17:1: cannot include Missing.asm: open ` + filepath.Join(dir, "Missing.asm") + ": no such file or directory",
+		f + ":18:1: " + f + " includes itself",
+		f + `:19:5: undefined symbol "X" in condition "N + X"`,
+		f + ":21:1: duplicate .else; previous .if at " + f + ":19:1",
+		f + ":23:1: expected .ifdef NAME",
+		f + ":24:1: .macro OPEN without .endm",
+	}
+	assert.Equal(t, strings.Join(expected, "\n"), err.Error())
+}
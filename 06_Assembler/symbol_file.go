@@ -0,0 +1,65 @@
+package assembler
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Symbol is one "NAME ADDRESS [RAM|ROM]" line of a symbol file: a label of
+// a ROM address or, marked RAM, a variable.
+type Symbol struct {
+	Name string
+	Addr int
+	RAM  bool
+}
+
+// ReadSymbols reads "NAME ADDRESS" pairs, one per line, in file order. A
+// third field of RAM marks a variable and ROM, the default, a label.
+func ReadSymbols(r io.Reader) ([]Symbol, error) {
+	var syms []Symbol
+	sc := bufio.NewScanner(r)
+	for ln := 1; sc.Scan(); ln++ {
+		fields := strings.Fields(sc.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) < 2 || len(fields) > 3 || len(fields) == 3 && fields[2] != "RAM" && fields[2] != "ROM" {
+			return nil, fmt.Errorf("symbols:%d: expected \"NAME ADDRESS [RAM|ROM]\"", ln)
+		}
+		addr, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("symbols:%d: %v", ln, err)
+		}
+		syms = append(syms, Symbol{Name: fields[0], Addr: addr, RAM: len(fields) == 3 && fields[2] == "RAM"})
+	}
+	return syms, sc.Err()
+}
+
+// Labels returns the ROM labels of syms.
+func Labels(syms []Symbol) []Symbol {
+	var labels []Symbol
+	for _, s := range syms {
+		if !s.RAM {
+			labels = append(labels, s)
+		}
+	}
+	return labels
+}
+
+// WriteSymbols writes syms in the format ReadSymbols reads, marking the
+// variables RAM.
+func WriteSymbols(w io.Writer, syms []Symbol) error {
+	for _, s := range syms {
+		suffix := ""
+		if s.RAM {
+			suffix = " RAM"
+		}
+		if _, err := fmt.Fprintf(w, "%s %d%s\n", s.Name, s.Addr, suffix); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,27 @@
+package assembler
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadSymbols(t *testing.T) {
+	syms, err := ReadSymbols(strings.NewReader("LOOP 10\n\nEND 14 ROM\ni 16 RAM\n"))
+	require.NoError(t, err)
+	expected := []Symbol{{Name: "LOOP", Addr: 10}, {Name: "END", Addr: 14}, {Name: "i", Addr: 16, RAM: true}}
+	assert.Equal(t, expected, syms)
+	assert.Equal(t, expected[:2], Labels(syms))
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteSymbols(&buf, syms))
+	assert.Equal(t, "LOOP 10\nEND 14\ni 16 RAM\n", buf.String())
+
+	_, err = ReadSymbols(strings.NewReader("LOOP 10\nEND 14 RAW\n"))
+	assert.EqualError(t, err, `symbols:2: expected "NAME ADDRESS [RAM|ROM]"`)
+	_, err = ReadSymbols(strings.NewReader("LOOP ten\n"))
+	assert.Error(t, err)
+}
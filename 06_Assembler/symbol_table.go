@@ -11,6 +11,9 @@ var (
 type SymbolTable struct {
 	table           map[string]int
 	variableCounter int
+	// labels and variables hold the symbols added, in the order added.
+	labels    []string
+	variables []string
 }
 
 func NewSymbolTable() *SymbolTable {
@@ -45,12 +48,14 @@ func NewSymbolTable() *SymbolTable {
 func (st *SymbolTable) AddEntry(s string, lineNo int, isLabel bool) {
 	if isLabel {
 		st.table[s] = lineNo
+		st.labels = append(st.labels, s)
 	} else {
 		if _, ok := st.table[s]; ok {
 			return
 		}
 		st.table[s] = st.variableCounter
 		st.variableCounter += 1
+		st.variables = append(st.variables, s)
 	}
 	return
 }
@@ -66,3 +71,14 @@ func (st *SymbolTable) GetAddress(s string) (int, error) {
 	}
 	return 0, errors.New("input entry not found")
 }
+
+// Labels returns the labels added, in the order added.
+func (st *SymbolTable) Labels() []string {
+	return st.labels
+}
+
+// Variables returns the variables allocated, in the order of their
+// addresses.
+func (st *SymbolTable) Variables() []string {
+	return st.variables
+}
@@ -56,4 +56,7 @@ func TestSymbolTable(t *testing.T) {
 	assert.True(t, st.Contains("R0"))
 	assert.Equal(t, 0, addr)
 	assert.NoError(t, err)
+
+	assert.Equal(t, []string{"LOOP", "LOOP2"}, st.Labels())
+	assert.Equal(t, []string{"LOOP", "a", "b"}, st.Variables())
 }
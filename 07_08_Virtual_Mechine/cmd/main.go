@@ -0,0 +1,37 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"strings"
+
+	vm "github.com/kazufusa/nand2tetris/07_08_Virtual_Mechine"
+)
+
+var sourceMap = flag.Bool("map", false, "also write a source map of assembly lines to VM lines to FILE.asm.map")
+
+// main translates a .vm file, or every .vm file of a directory, to FILE.asm.
+func main() {
+	flag.Parse()
+	in := flag.Arg(0)
+	translator, err := vm.NewVMTranslator(in)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := translator.Conv(); err != nil {
+		log.Fatal(err)
+	}
+	if *sourceMap {
+		var buf bytes.Buffer
+		if err := translator.WriteSourceMap(&buf); err != nil {
+			log.Fatal(err)
+		}
+		out := strings.TrimSuffix(in, filepath.Ext(in)) + ".asm.map"
+		if err := ioutil.WriteFile(out, buf.Bytes(), 0644); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
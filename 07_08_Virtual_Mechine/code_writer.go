@@ -45,6 +45,15 @@ type CodeWriter struct {
 	funcName string
 	iRet     int
 	iJmp     int
+	// sources holds the VM line each line of asm was generated from; the
+	// bootstrap code has none.
+	sources []sourceLine
+	mapped  int
+}
+
+type sourceLine struct {
+	file string
+	line int
 }
 
 func NewCodeWriter(out string) *CodeWriter {
@@ -83,6 +92,15 @@ func (c *CodeWriter) jmpLabel() string {
 	return fmt.Sprintf("%s.%d", c.funcName, c.iJmp)
 }
 
+// mapLines records that the lines written since the last call were
+// generated from line of the VM file; lines of no VM command have no file.
+func (c *CodeWriter) mapLines(file string, line int) {
+	for n := strings.Count(c.asm[c.mapped:], "\n"); n > 0; n-- {
+		c.sources = append(c.sources, sourceLine{file: file, line: line})
+	}
+	c.mapped = len(c.asm)
+}
+
 func (c *CodeWriter) write(s string) {
 	c.asm += s
 }
@@ -29,29 +29,27 @@ const (
 )
 
 var (
-	reComment       = regexp.MustCompile(`//[^\n]*`)
-	reForwardSpace  = regexp.MustCompile(`(?m)^\s*`)
-	reBackwardSpace = regexp.MustCompile(`(?m)\s*$`)
-	reSpace         = regexp.MustCompile(`(?m) +`)
+	reComment = regexp.MustCompile(`//.*`)
+	reSpace   = regexp.MustCompile(` +`)
 )
 
 type Parser struct {
 	fileName string
-	lines    []string
-	count    int
+	// source is the path of the VM file, used in source maps.
+	source  string
+	lines   []string
+	lineNos []int
+	count   int
 }
 
 func NewParser(s, fileName string) (*Parser, error) {
-	s = reComment.ReplaceAllString(s, "")
-	s = reForwardSpace.ReplaceAllString(s, "")
-	s = reBackwardSpace.ReplaceAllString(s, "")
-	s = reSpace.ReplaceAllString(s, " ")
-	s = strings.ReplaceAll(s, "\r", "")
 	parser := Parser{fileName: fileName}
-	parser.lines = strings.Split(s, "\n")
-	for i := len(parser.lines) - 1; i >= 0; i-- {
-		if parser.lines[i] == "" {
-			parser.lines = append(parser.lines[:i], parser.lines[i+1:]...)
+	for i, l := range strings.Split(s, "\n") {
+		l = reComment.ReplaceAllString(l, "")
+		l = reSpace.ReplaceAllString(strings.TrimSpace(l), " ")
+		if l != "" {
+			parser.lines = append(parser.lines, l)
+			parser.lineNos = append(parser.lineNos, i+1)
 		}
 	}
 	return &parser, nil
@@ -61,6 +59,11 @@ func (p *Parser) line() string {
 	return p.lines[p.count]
 }
 
+// lineNumber returns the 1-based line of the current command in the VM file.
+func (p *Parser) lineNumber() int {
+	return p.lineNos[p.count]
+}
+
 func (p *Parser) hasMoreLines() bool {
 	return p.count < (len(p.lines) - 1)
 }
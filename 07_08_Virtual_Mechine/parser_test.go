@@ -18,6 +18,7 @@ func TestParser(t *testing.T) {
   //`, "test")
 
 	// line 1
+	assert.Equal(t, 3, parser.lineNumber())
 	c, err := parser.commandType()
 	assert.NoError(t, err)
 	assert.Equal(t, C_PUSH, c)
@@ -32,6 +33,7 @@ func TestParser(t *testing.T) {
 	parser.advance()
 
 	// line 2
+	assert.Equal(t, 5, parser.lineNumber())
 	c, err = parser.commandType()
 	assert.NoError(t, err)
 	assert.Equal(t, C_PUSH, c)
@@ -2,6 +2,7 @@ package vm
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -30,6 +31,7 @@ func NewVMTranslator(in string) (*VMTranslator, error) {
 		if err != nil {
 			return nil, err
 		}
+		parser.source = f
 		parsers = append(parsers, parser)
 	}
 
@@ -61,6 +63,7 @@ func (t *VMTranslator) Conv() error {
 
 func (t *VMTranslator) conv(parser *Parser) error {
 	for {
+		t.codeWriter.mapLines("", 0)
 		t.codeWriter.write("// " + parser.line() + "\n")
 		cmd, err := parser.commandType()
 		if err != nil {
@@ -104,6 +107,7 @@ func (t *VMTranslator) conv(parser *Parser) error {
 			t.codeWriter.writeCall(arg1, arg2)
 		default:
 		}
+		t.codeWriter.mapLines(parser.source, parser.lineNumber())
 
 		if parser.hasMoreLines() {
 			t.codeWriter.write("\n")
@@ -114,6 +118,26 @@ func (t *VMTranslator) conv(parser *Parser) error {
 	}
 }
 
+// WriteSourceMap writes the source map of the last Conv, one
+// "asm:LINE vm:LINE" line per generated line of assembly. Paths are
+// relative to the directory of the assembly file.
+func (t *VMTranslator) WriteSourceMap(w io.Writer) error {
+	out := t.codeWriter.out
+	for i, src := range t.codeWriter.sources {
+		if src.file == "" {
+			continue
+		}
+		file, err := filepath.Rel(filepath.Dir(out), src.file)
+		if err != nil {
+			file = src.file
+		}
+		if _, err := fmt.Fprintf(w, "%s:%d %s:%d\n", filepath.Base(out), i+1, filepath.ToSlash(file), src.line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func findVMs(f string) ([]string, error) {
 	s, err := os.Stat(f)
 	if err != nil {
@@ -1,6 +1,7 @@
 package vm
 
 import (
+	"bytes"
 	"os"
 	"path/filepath"
 	"strings"
@@ -349,3 +350,44 @@ func TestFindVMs(t *testing.T) {
 	}
 	assert.Equal(t, expected, actual)
 }
+
+func TestSourceMapCoverage(t *testing.T) {
+	asm := filepath.Join("examples", "FibonacciElement.asm")
+	defer os.Remove(asm)
+	translator, err := NewVMTranslator(filepath.Join("examples", "FibonacciElement"))
+	require.NoError(t, err)
+	require.NoError(t, translator.Conv())
+	var vmMap bytes.Buffer
+	require.NoError(t, translator.WriteSourceMap(&vmMap))
+	assert.True(t, strings.HasPrefix(vmMap.String(), "FibonacciElement.asm:65 FibonacciElement/Main.vm:11\n"),
+		"the bootstrap code is not mapped")
+
+	a, err := assembler.NewAssembler(asm)
+	require.NoError(t, err)
+	hack, err := a.Assemble()
+	require.NoError(t, err)
+	var hackMap bytes.Buffer
+	require.NoError(t, a.WriteSourceMap(&hackMap, filepath.Join("examples", "FibonacciElement.hack")))
+
+	com := computer.NewEmulator(hack)
+	cov := computer.NewCoverage()
+	com.AddObserver(cov)
+	for i := 0; i < 10000; i++ {
+		com.FetchAndExecute(logic.O)
+	}
+	require.NoError(t, cov.LoadSourceMap(&hackMap, "examples"))
+	require.NoError(t, cov.LoadSourceMap(&vmMap, "examples"))
+
+	files := cov.Files()
+	require.Len(t, files, 3)
+	main := files[1]
+	assert.Equal(t, filepath.Join("examples", "FibonacciElement", "Main.vm"), main.File)
+	// Labels and a function without locals generate no code.
+	assert.Len(t, main.Lines, 17)
+	assert.Equal(t, 17, main.Hit())
+	assert.NotContains(t, main.Lines, 11)
+	assert.NotContains(t, main.Lines, 17)
+	assert.Equal(t, uint64(9), main.Lines[12], "fibonacci(4) calls itself 8 times")
+	assert.Equal(t, filepath.Join("examples", "FibonacciElement", "Sys.vm"), files[2].File)
+	assert.Equal(t, len(files[2].Lines), files[2].Hit())
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	compiler "github.com/kazufusa/nand2tetris/10_Compiler"
+)
+
+var sourceMap = flag.Bool("map", false, "also write a source map of VM lines to Jack lines to FILE.vm.map")
+
+// main compiles a .jack file, or every .jack file of a directory, to FILE.vm.
+func main() {
+	flag.Parse()
+	fs, err := findJacks(flag.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, jack := range fs {
+		w, err := compiler.CompileFile(jack)
+		if err != nil {
+			log.Fatal(err)
+		}
+		out := strings.TrimSuffix(jack, filepath.Ext(jack)) + ".vm"
+		var buf bytes.Buffer
+		if _, err := w.WriteTo(&buf); err != nil {
+			log.Fatal(err)
+		}
+		if err := ioutil.WriteFile(out, buf.Bytes(), 0644); err != nil {
+			log.Fatal(err)
+		}
+		if *sourceMap {
+			buf.Reset()
+			if err := w.WriteSourceMap(&buf, out, jack); err != nil {
+				log.Fatal(err)
+			}
+			if err := ioutil.WriteFile(out+".map", buf.Bytes(), 0644); err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
+}
+
+func findJacks(f string) ([]string, error) {
+	s, err := os.Stat(f)
+	if err != nil {
+		return nil, err
+	}
+	if !s.IsDir() {
+		return []string{f}, nil
+	}
+	return filepath.Glob(filepath.Join(f, "*.jack"))
+}
@@ -0,0 +1,467 @@
+package compiler
+
+import (
+	"fmt"
+	"strconv"
+)
+
+type ICodeGenerator interface {
+	CompileClass(class *Node) error
+}
+
+// CodeGenerator writes the VM code of a class from its parse tree.
+type CodeGenerator struct {
+	w        *VMWriter
+	symbols  *SymbolTable
+	lines    map[*Token]int
+	class    string
+	function string
+	nLabel   int
+}
+
+var _ ICodeGenerator = (*CodeGenerator)(nil)
+
+func NewCodeGenerator(w *VMWriter) *CodeGenerator {
+	return &CodeGenerator{w: w, symbols: NewSymbolTable()}
+}
+
+// SetLines sets the Jack lines of the tokens of the parse tree, which the
+// VM commands are mapped to.
+func (g *CodeGenerator) SetLines(lines map[*Token]int) {
+	g.lines = lines
+}
+
+// CompileFile compiles a .jack file into VM commands.
+func CompileFile(jack string) (*VMWriter, error) {
+	tk, err := NewTokenizer(jack)
+	if err != nil {
+		return nil, err
+	}
+	lines := make(map[*Token]int, len(tk.tokens))
+	for i := range tk.tokens {
+		lines[&tk.tokens[i]] = tk.lines[i]
+	}
+
+	ce := NewCompilationEngine(tk.tokens)
+	class, err := ce.compileClass()
+	if err != nil {
+		if e, ok := err.(ErrCompileFailed); ok && lines[e.token] > 0 {
+			return nil, fmt.Errorf("%s: line %d: %w", jack, lines[e.token], err)
+		}
+		return nil, fmt.Errorf("%s: %w", jack, err)
+	}
+	if token := ce.nextToken(); token != nil {
+		return nil, fmt.Errorf("%s: line %d: unexpected %q after the class", jack, lines[token], token.value)
+	}
+
+	w := NewVMWriter()
+	g := NewCodeGenerator(w)
+	g.SetLines(lines)
+	if err := g.CompileClass(class); err != nil {
+		return nil, fmt.Errorf("%s: %w", jack, err)
+	}
+	return w, nil
+}
+
+// class className '{' classVarDec* subroutineDec* '}'
+func (g *CodeGenerator) CompileClass(class *Node) error {
+	g.class = asToken(class.children[1]).value
+	for _, c := range class.children {
+		n := asNode(c)
+		if n == nil {
+			continue
+		}
+		switch n.structureTag {
+		case StrClassVarDec:
+			g.defineVars(n, Kind(asToken(n.children[0]).value))
+		case StrSubroutineDec:
+			if err := g.compileSubroutine(n); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// defineVars defines the names of a classVarDec or a varDec, which are
+// ('static'|'field'|'var') type varName (',' varName)* ';'
+func (g *CodeGenerator) defineVars(n *Node, kind Kind) {
+	typ := asToken(n.children[1]).value
+	for _, c := range n.children[2:] {
+		if t := asToken(c); t.tokenType == TkIdentifier {
+			g.symbols.Define(t.value, typ, kind)
+		}
+	}
+}
+
+// ('constructor'|'function'|'method') ('void'|type) subroutineName
+// '(' parameterList ')' subroutineBody
+func (g *CodeGenerator) compileSubroutine(n *Node) error {
+	kw := asToken(n.children[0])
+	params := asNode(n.children[4])
+	body := asNode(n.children[6])
+
+	g.symbols.StartSubroutine()
+	g.function = g.class + "." + asToken(n.children[2]).value
+	g.nLabel = 0
+	if kw.value == string(KwMethod) {
+		g.symbols.Define("this", g.class, KindArg)
+	}
+	for i := 0; i+1 < len(params.children); i += 3 {
+		g.symbols.Define(asToken(params.children[i+1]).value, asToken(params.children[i]).value, KindArg)
+	}
+	var statements *Node
+	for _, c := range body.children {
+		switch b := asNode(c); {
+		case b == nil:
+		case b.structureTag == StrVarDec:
+			g.defineVars(b, KindVar)
+		case b.structureTag == StrStatements:
+			statements = b
+		}
+	}
+
+	g.at(kw)
+	g.w.WriteFunction(g.function, g.symbols.VarCount(KindVar))
+	switch kw.value {
+	case string(KwConstructor):
+		g.w.WritePush(SegConst, g.symbols.VarCount(KindField))
+		g.w.WriteCall("Memory.alloc", 1)
+		g.w.WritePop(SegPointer, 0)
+	case string(KwMethod):
+		g.w.WritePush(SegArg, 0)
+		g.w.WritePop(SegPointer, 0)
+	}
+	if statements == nil {
+		return nil
+	}
+	return g.compileStatements(statements)
+}
+
+func (g *CodeGenerator) compileStatements(n *Node) error {
+	for _, c := range n.children {
+		s := asNode(c)
+		var err error
+		switch s.structureTag {
+		case StrLetStatement:
+			err = g.compileLet(s)
+		case StrIfStatement:
+			err = g.compileIf(s)
+		case StrWhileStatement:
+			err = g.compileWhile(s)
+		case StrDoStatement:
+			err = g.compileDo(s)
+		case StrReturnStatement:
+			err = g.compileReturn(s)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 1. let varName = expression;
+// 2. let varName[expression1] = expression2;
+func (g *CodeGenerator) compileLet(n *Node) error {
+	kw := asToken(n.children[0])
+	name := asToken(n.children[1])
+	if asToken(n.children[2]).value != "[" {
+		if err := g.compileExpression(asNode(n.children[3])); err != nil {
+			return err
+		}
+		g.at(kw)
+		return g.writeVar(name, false)
+	}
+
+	g.at(kw)
+	if err := g.writeVar(name, true); err != nil {
+		return err
+	}
+	if err := g.compileExpression(asNode(n.children[3])); err != nil {
+		return err
+	}
+	g.at(kw)
+	g.w.WriteArithmetic("add")
+	if err := g.compileExpression(asNode(n.children[6])); err != nil {
+		return err
+	}
+	g.at(kw)
+	g.w.WritePop(SegTemp, 0)
+	g.w.WritePop(SegPointer, 1)
+	g.w.WritePush(SegTemp, 0)
+	g.w.WritePop(SegThat, 0)
+	return nil
+}
+
+// if ( expression ) { statements } (else { statements })?
+func (g *CodeGenerator) compileIf(n *Node) error {
+	kw := asToken(n.children[0])
+	elseLabel, endLabel := g.label("IF_ELSE"), g.label("IF_END")
+	g.nLabel++
+
+	if err := g.compileExpression(asNode(n.children[2])); err != nil {
+		return err
+	}
+	g.at(kw)
+	g.w.WriteArithmetic("not")
+	if len(n.children) == 7 {
+		g.w.WriteIf(endLabel)
+		if err := g.compileStatements(asNode(n.children[5])); err != nil {
+			return err
+		}
+		g.at(kw)
+		g.w.WriteLabel(endLabel)
+		return nil
+	}
+
+	g.w.WriteIf(elseLabel)
+	if err := g.compileStatements(asNode(n.children[5])); err != nil {
+		return err
+	}
+	g.at(kw)
+	g.w.WriteGoto(endLabel)
+	g.w.WriteLabel(elseLabel)
+	if err := g.compileStatements(asNode(n.children[9])); err != nil {
+		return err
+	}
+	g.at(kw)
+	g.w.WriteLabel(endLabel)
+	return nil
+}
+
+// while ( expression ) { statements }
+func (g *CodeGenerator) compileWhile(n *Node) error {
+	kw := asToken(n.children[0])
+	expLabel, endLabel := g.label("WHILE_EXP"), g.label("WHILE_END")
+	g.nLabel++
+
+	g.at(kw)
+	g.w.WriteLabel(expLabel)
+	if err := g.compileExpression(asNode(n.children[2])); err != nil {
+		return err
+	}
+	g.at(kw)
+	g.w.WriteArithmetic("not")
+	g.w.WriteIf(endLabel)
+	if err := g.compileStatements(asNode(n.children[5])); err != nil {
+		return err
+	}
+	g.at(kw)
+	g.w.WriteGoto(expLabel)
+	g.w.WriteLabel(endLabel)
+	return nil
+}
+
+// do subroutineCall;
+func (g *CodeGenerator) compileDo(n *Node) error {
+	if err := g.compileSubroutineCall(n.children[1 : len(n.children)-1]); err != nil {
+		return err
+	}
+	g.at(asToken(n.children[0]))
+	g.w.WritePop(SegTemp, 0)
+	return nil
+}
+
+// return expression?;
+func (g *CodeGenerator) compileReturn(n *Node) error {
+	kw := asToken(n.children[0])
+	if len(n.children) == 3 {
+		if err := g.compileExpression(asNode(n.children[1])); err != nil {
+			return err
+		}
+	} else {
+		g.at(kw)
+		g.w.WritePush(SegConst, 0)
+	}
+	g.at(kw)
+	g.w.WriteReturn()
+	return nil
+}
+
+// term (op term)*
+func (g *CodeGenerator) compileExpression(n *Node) error {
+	if err := g.compileTerm(asNode(n.children[0])); err != nil {
+		return err
+	}
+	for i := 1; i+1 < len(n.children); i += 2 {
+		if err := g.compileTerm(asNode(n.children[i+1])); err != nil {
+			return err
+		}
+		op := asToken(n.children[i])
+		g.at(op)
+		switch op.value {
+		case "+":
+			g.w.WriteArithmetic("add")
+		case "-":
+			g.w.WriteArithmetic("sub")
+		case "*":
+			g.w.WriteCall("Math.multiply", 2)
+		case "/":
+			g.w.WriteCall("Math.divide", 2)
+		case "&":
+			g.w.WriteArithmetic("and")
+		case "|":
+			g.w.WriteArithmetic("or")
+		case "<":
+			g.w.WriteArithmetic("lt")
+		case ">":
+			g.w.WriteArithmetic("gt")
+		case "=":
+			g.w.WriteArithmetic("eq")
+		}
+	}
+	return nil
+}
+
+// intergerConst
+// stringConst
+// keywordConst (true, false, null, this)
+// varName
+// varName[expression]
+// (expression)
+// unaryOp term
+// subroutineCall
+func (g *CodeGenerator) compileTerm(n *Node) error {
+	first := asToken(n.children[0])
+	g.at(first)
+	switch {
+	case first.tokenType == TkIntConst:
+		v, err := strconv.Atoi(first.value)
+		if err != nil || v > 32767 {
+			return g.errorf(first, "integer constant %s is out of range", first.value)
+		}
+		g.w.WritePush(SegConst, v)
+	case first.tokenType == TkStringConst:
+		g.w.WritePush(SegConst, len(first.value))
+		g.w.WriteCall("String.new", 1)
+		for _, c := range first.value {
+			g.w.WritePush(SegConst, int(c))
+			g.w.WriteCall("String.appendChar", 2)
+		}
+	case first.tokenType == TkKeyWord:
+		switch first.value {
+		case string(KwTrue):
+			g.w.WritePush(SegConst, 0)
+			g.w.WriteArithmetic("not")
+		case string(KwThis):
+			g.w.WritePush(SegPointer, 0)
+		default:
+			g.w.WritePush(SegConst, 0)
+		}
+	case first.tokenType == TkSymbol && first.value == "(":
+		return g.compileExpression(asNode(n.children[1]))
+	case first.tokenType == TkSymbol:
+		if err := g.compileTerm(asNode(n.children[1])); err != nil {
+			return err
+		}
+		g.at(first)
+		if first.value == "-" {
+			g.w.WriteArithmetic("neg")
+		} else {
+			g.w.WriteArithmetic("not")
+		}
+	case len(n.children) == 1:
+		return g.writeVar(first, true)
+	case asToken(n.children[1]).value == "[":
+		if err := g.writeVar(first, true); err != nil {
+			return err
+		}
+		if err := g.compileExpression(asNode(n.children[2])); err != nil {
+			return err
+		}
+		g.at(first)
+		g.w.WriteArithmetic("add")
+		g.w.WritePop(SegPointer, 1)
+		g.w.WritePush(SegThat, 0)
+	default:
+		return g.compileSubroutineCall(n.children)
+	}
+	return nil
+}
+
+// 1. subroutineName(expressionList)
+// 2. (className|varName).subroutineName(expressionList)
+func (g *CodeGenerator) compileSubroutineCall(children []interface{}) error {
+	first := asToken(children[0])
+	g.at(first)
+	var name string
+	var args *Node
+	nArgs := 0
+	switch {
+	case asToken(children[1]).value != ".":
+		g.w.WritePush(SegPointer, 0)
+		nArgs++
+		name = g.class + "." + first.value
+		args = asNode(children[2])
+	case g.symbols.KindOf(first.value) != KindNone:
+		if err := g.writeVar(first, true); err != nil {
+			return err
+		}
+		nArgs++
+		name = g.symbols.TypeOf(first.value) + "." + asToken(children[2]).value
+		args = asNode(children[4])
+	default:
+		name = first.value + "." + asToken(children[2]).value
+		args = asNode(children[4])
+	}
+	for _, c := range args.children {
+		if e := asNode(c); e != nil {
+			if err := g.compileExpression(e); err != nil {
+				return err
+			}
+			nArgs++
+		}
+	}
+	g.at(first)
+	g.w.WriteCall(name, nArgs)
+	return nil
+}
+
+// writeVar pushes or pops a variable.
+func (g *CodeGenerator) writeVar(name *Token, push bool) error {
+	var segment Segment
+	switch g.symbols.KindOf(name.value) {
+	case KindStatic:
+		segment = SegStatic
+	case KindField:
+		segment = SegThis
+	case KindArg:
+		segment = SegArg
+	case KindVar:
+		segment = SegLocal
+	default:
+		return g.errorf(name, "undefined variable %s", name.value)
+	}
+	if push {
+		g.w.WritePush(segment, g.symbols.IndexOf(name.value))
+	} else {
+		g.w.WritePop(segment, g.symbols.IndexOf(name.value))
+	}
+	return nil
+}
+
+// label returns a label unique to the current if or while statement, which
+// is prefixed with the function as the VM translator does not scope labels.
+func (g *CodeGenerator) label(name string) string {
+	return fmt.Sprintf("%s.%s%d", g.function, name, g.nLabel)
+}
+
+// at maps the following VM commands to the line of token.
+func (g *CodeGenerator) at(token *Token) {
+	g.w.SetLine(g.lines[token])
+}
+
+func (g *CodeGenerator) errorf(token *Token, format string, a ...interface{}) error {
+	return fmt.Errorf("line %d: %s", g.lines[token], fmt.Sprintf(format, a...))
+}
+
+func asToken(c interface{}) *Token {
+	t, _ := c.(*Token)
+	return t
+}
+
+func asNode(c interface{}) *Node {
+	n, _ := c.(*Node)
+	return n
+}
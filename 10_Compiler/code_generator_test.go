@@ -0,0 +1,219 @@
+package compiler
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	logic "github.com/kazufusa/nand2tetris/01_Boolean_Logic"
+	computer "github.com/kazufusa/nand2tetris/05_Computer_Architecture"
+	assembler "github.com/kazufusa/nand2tetris/06_Assembler"
+	vm "github.com/kazufusa/nand2tetris/07_08_Virtual_Mechine"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeJack(t *testing.T, dir, name, src string) string {
+	f := filepath.Join(dir, name+".jack")
+	require.NoError(t, os.WriteFile(f, []byte(src), 0644))
+	return f
+}
+
+func TestCompileFile(t *testing.T) {
+	jack := writeJack(t, t.TempDir(), "Main", `class Main {
+    function int f(int a) {
+        var String s;
+        let s = "hi";
+        return -a * 2;
+    }
+}
+`)
+	w, err := CompileFile(jack)
+	require.NoError(t, err)
+	var out bytes.Buffer
+	_, err = w.WriteTo(&out)
+	require.NoError(t, err)
+	assert.Equal(t, "function Main.f 1\n"+
+		"push constant 2\n"+
+		"call String.new 1\n"+
+		"push constant 104\n"+
+		"call String.appendChar 2\n"+
+		"push constant 105\n"+
+		"call String.appendChar 2\n"+
+		"pop local 0\n"+
+		"push argument 0\n"+
+		"neg\n"+
+		"push constant 2\n"+
+		"call Math.multiply 2\n"+
+		"return\n", out.String())
+
+	var m bytes.Buffer
+	require.NoError(t, w.WriteSourceMap(&m, filepath.Join(filepath.Dir(jack), "Main.vm"), jack))
+	assert.Equal(t, "Main.vm:1 Main.jack:2\n"+
+		"Main.vm:2 Main.jack:4\nMain.vm:3 Main.jack:4\nMain.vm:4 Main.jack:4\n"+
+		"Main.vm:5 Main.jack:4\nMain.vm:6 Main.jack:4\nMain.vm:7 Main.jack:4\n"+
+		"Main.vm:8 Main.jack:4\n"+
+		"Main.vm:9 Main.jack:5\nMain.vm:10 Main.jack:5\nMain.vm:11 Main.jack:5\n"+
+		"Main.vm:12 Main.jack:5\nMain.vm:13 Main.jack:5\n", m.String())
+}
+
+func TestCompileFileErrors(t *testing.T) {
+	dir := t.TempDir()
+	_, err := CompileFile(writeJack(t, dir, "Undefined", "class Undefined {\n  function void f() {\n    let x = 1;\n    return;\n  }\n}\n"))
+	assert.EqualError(t, err, filepath.Join(dir, "Undefined.jack")+": line 3: undefined variable x")
+
+	_, err = CompileFile(writeJack(t, dir, "Trailing", "class Trailing {\n}\n}\n"))
+	assert.EqualError(t, err, filepath.Join(dir, "Trailing.jack")+`: line 3: unexpected "}" after the class`)
+
+	_, err = CompileFile(filepath.Join(dir, "Missing.jack"))
+	assert.Error(t, err)
+}
+
+var programJack = map[string]string{
+	"Sys": `class Sys {
+    function void init() {
+        var Point p, q;
+        var Array a;
+        var int i;
+        let p = Point.new(3, 4);
+        let q = Point.new(10, -2);
+        do p.add(q);
+        let a = 8000;
+        let i = 0;
+        while (i < 5) {
+            let a[i] = i + p.getX();
+            let i = i + 1;
+        }
+        if (~(p.getY() = 2)) {
+            let a[5] = 1;
+        } else {
+            let a[5] = 2;
+        }
+        let a[6] = Point.count();
+        let a[7] = true;
+        let a[8] = p.sum();
+        let a[9] = a[a[5] + 1] | 1;
+        while (true) {
+        }
+        return;
+    }
+}
+`,
+	"Memory": `class Memory {
+    static int free;
+
+    function int alloc(int size) {
+        var int p;
+        if (free = 0) {
+            let free = 2048;
+        }
+        let p = free;
+        let free = free + size;
+        return p;
+    }
+}
+`,
+	"Point": `class Point {
+    field int x, y;
+    static int n;
+
+    constructor Point new(int ax, int ay) {
+        let x = ax;
+        let y = ay;
+        let n = n + 1;
+        return this;
+    }
+
+    method void add(Point other) {
+        let x = x + other.getX();
+        let y = y + other.getY();
+        return;
+    }
+
+    method int getX() { return x; }
+    method int getY() { return y; }
+    method int sum() { return getX() + getY(); }
+    function int count() { return n; }
+}
+`,
+}
+
+// TestCompiledProgram runs compiled Jack code through the VM translator, the
+// assembler and the emulator, and reports its coverage through the maps.
+func TestCompiledProgram(t *testing.T) {
+	dir := t.TempDir()
+	prog := filepath.Join(dir, "Prog")
+	require.NoError(t, os.Mkdir(prog, 0755))
+	for name, src := range programJack {
+		jack := writeJack(t, prog, name, src)
+		w, err := CompileFile(jack)
+		require.NoError(t, err)
+		var out, m bytes.Buffer
+		_, err = w.WriteTo(&out)
+		require.NoError(t, err)
+		require.NoError(t, w.WriteSourceMap(&m, filepath.Join(prog, name+".vm"), jack))
+		require.NoError(t, os.WriteFile(filepath.Join(prog, name+".vm"), out.Bytes(), 0644))
+		require.NoError(t, os.WriteFile(filepath.Join(prog, name+".vm.map"), m.Bytes(), 0644))
+	}
+
+	translator, err := vm.NewVMTranslator(prog)
+	require.NoError(t, err)
+	require.NoError(t, translator.Conv())
+	var asmMap bytes.Buffer
+	require.NoError(t, translator.WriteSourceMap(&asmMap))
+	a, err := assembler.NewAssembler(filepath.Join(dir, "Prog.asm"))
+	require.NoError(t, err)
+	hack, err := a.Assemble()
+	require.NoError(t, err)
+	var hackMap bytes.Buffer
+	require.NoError(t, a.WriteSourceMap(&hackMap, filepath.Join(dir, "Prog.hack")))
+
+	com := computer.NewEmulator(hack)
+	cov := computer.NewCoverage()
+	com.AddObserver(cov)
+	for i := 0; i < 20000; i++ {
+		com.FetchAndExecute(logic.O)
+	}
+	var ram []int
+	for addr := 8000; addr < 8010; addr++ {
+		ram = append(ram, com.RAM(addr))
+	}
+	assert.Equal(t, []int{13, 14, 15, 16, 17, 2, 2, -1, 15, 17}, ram)
+
+	require.NoError(t, cov.LoadSourceMap(&hackMap, dir))
+	require.NoError(t, cov.LoadSourceMap(&asmMap, dir))
+	for name := range programJack {
+		f, err := os.Open(filepath.Join(prog, name+".vm.map"))
+		require.NoError(t, err)
+		require.NoError(t, cov.LoadSourceMap(f, prog))
+		f.Close()
+	}
+	lines := map[string]map[int]uint64{}
+	for _, f := range cov.Files() {
+		lines[filepath.Base(f.File)] = f.Lines
+	}
+	sys := lines["Sys.jack"]
+	require.NotNil(t, sys)
+	assert.Equal(t, uint64(5), sys[13], "let i = i + 1")
+	assert.Equal(t, uint64(0), sys[16], "the then branch is not taken")
+	assert.Equal(t, uint64(1), sys[18], "the else branch is")
+	assert.NotContains(t, sys, 3, "declarations generate no code")
+	assert.Equal(t, uint64(2), lines["Point.jack"][6], "two points are constructed")
+	assert.Equal(t, uint64(2), lines["Memory.jack"][6])
+	assert.Equal(t, uint64(1), lines["Memory.jack"][7], "free is initialized once")
+}
+
+func TestCompileFileCallLines(t *testing.T) {
+	jack := writeJack(t, t.TempDir(), "Main", "class Main {\n  function void f(Point p) {\n    do p.draw(\n      1);\n    return;\n  }\n}\n")
+	w, err := CompileFile(jack)
+	require.NoError(t, err)
+	var m bytes.Buffer
+	require.NoError(t, w.WriteSourceMap(&m, filepath.Join(filepath.Dir(jack), "Main.vm"), jack))
+	assert.Equal(t, "Main.vm:1 Main.jack:2\n"+
+		"Main.vm:2 Main.jack:3\n"+ // push argument 0
+		"Main.vm:3 Main.jack:4\n"+ // push constant 1
+		"Main.vm:4 Main.jack:3\n"+ // call Point.draw 2
+		"Main.vm:5 Main.jack:3\n"+ // pop temp 0
+		"Main.vm:6 Main.jack:5\nMain.vm:7 Main.jack:5\n", m.String())
+}
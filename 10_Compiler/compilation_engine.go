@@ -630,9 +630,14 @@ func (c *CompilationEngine) compileParameterList() (_ *Node, err error) {
 	node := Node{structureTag: StrParameterList, children: []interface{}{}}
 
 	for {
+		// type (int, boolean, char or className)
 		child, err = c.processKeyword(KwInt, KwBoolean, KwChar)
 		if err != nil {
-			break
+			c.rollbackNextToken()
+			child, err = c.processIdentifier()
+			if err != nil {
+				break
+			}
 		}
 		node.children = append(node.children, child)
 
@@ -792,3 +792,38 @@ func TestCompileClassVarDec(t *testing.T) {
 		})
 	}
 }
+
+func TestCompileParameterList(t *testing.T) {
+	var tests = []struct {
+		expected string
+		given    []Token
+	}{
+		{"<parameterList>\n</parameterList>\n", []Token{}},
+		{
+			"<parameterList>\n" +
+				"  <keyword> int </keyword>\n" +
+				"  <identifier> x </identifier>\n" +
+				"  <symbol> , </symbol>\n" +
+				"  <identifier> Array </identifier>\n" +
+				"  <identifier> a </identifier>\n" +
+				"</parameterList>\n",
+			[]Token{
+				{TkKeyWord, "int"},
+				{TkIdentifier, "x"},
+				{TkSymbol, ","},
+				{TkIdentifier, "Array"},
+				{TkIdentifier, "a"},
+			},
+		},
+	}
+	for i, tt := range tests {
+		tt := tt
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			ce := NewCompilationEngine(append(tt.given, Token{TkSymbol, ")"}))
+			tree, err := ce.compileParameterList()
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, tree.ToString(""))
+			assert.Equal(t, &Token{TkSymbol, ")"}, ce.nextToken())
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package compiler
+
+type Kind string
+
+const (
+	KindNone   Kind = ""
+	KindStatic Kind = "static"
+	KindField  Kind = "field"
+	KindArg    Kind = "argument"
+	KindVar    Kind = "var"
+)
+
+type ISymbolTable interface {
+	StartSubroutine()
+	Define(name, typ string, kind Kind)
+	VarCount(kind Kind) int
+	KindOf(name string) Kind
+	TypeOf(name string) string
+	IndexOf(name string) int
+}
+
+type symbol struct {
+	typ   string
+	kind  Kind
+	index int
+}
+
+// SymbolTable holds the static and field variables of a class and the
+// arguments and local variables of the subroutine being compiled.
+type SymbolTable struct {
+	class      map[string]symbol
+	subroutine map[string]symbol
+	counts     map[Kind]int
+}
+
+var _ ISymbolTable = (*SymbolTable)(nil)
+
+func NewSymbolTable() *SymbolTable {
+	return &SymbolTable{
+		class:      map[string]symbol{},
+		subroutine: map[string]symbol{},
+		counts:     map[Kind]int{},
+	}
+}
+
+// StartSubroutine forgets the arguments and local variables.
+func (st *SymbolTable) StartSubroutine() {
+	st.subroutine = map[string]symbol{}
+	st.counts[KindArg] = 0
+	st.counts[KindVar] = 0
+}
+
+func (st *SymbolTable) Define(name, typ string, kind Kind) {
+	s := symbol{typ: typ, kind: kind, index: st.counts[kind]}
+	st.counts[kind]++
+	switch kind {
+	case KindStatic, KindField:
+		st.class[name] = s
+	default:
+		st.subroutine[name] = s
+	}
+}
+
+func (st *SymbolTable) VarCount(kind Kind) int {
+	return st.counts[kind]
+}
+
+// KindOf returns KindNone for an undefined name, which is then a class or
+// a subroutine.
+func (st *SymbolTable) KindOf(name string) Kind {
+	s, _ := st.lookup(name)
+	return s.kind
+}
+
+func (st *SymbolTable) TypeOf(name string) string {
+	s, _ := st.lookup(name)
+	return s.typ
+}
+
+func (st *SymbolTable) IndexOf(name string) int {
+	s, _ := st.lookup(name)
+	return s.index
+}
+
+// lookup searches the subroutine scope before the class scope.
+func (st *SymbolTable) lookup(name string) (symbol, bool) {
+	if s, ok := st.subroutine[name]; ok {
+		return s, true
+	}
+	s, ok := st.class[name]
+	return s, ok
+}
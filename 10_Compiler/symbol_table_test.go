@@ -0,0 +1,40 @@
+package compiler
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSymbolTable(t *testing.T) {
+	st := NewSymbolTable()
+	st.Define("x", "int", KindField)
+	st.Define("y", "int", KindField)
+	st.Define("count", "int", KindStatic)
+
+	st.StartSubroutine()
+	st.Define("this", "Point", KindArg)
+	st.Define("other", "Point", KindArg)
+	st.Define("x", "boolean", KindVar)
+
+	assert.Equal(t, 2, st.VarCount(KindField))
+	assert.Equal(t, 1, st.VarCount(KindStatic))
+	assert.Equal(t, 2, st.VarCount(KindArg))
+	assert.Equal(t, 1, st.VarCount(KindVar))
+
+	assert.Equal(t, KindVar, st.KindOf("x"), "locals hide fields")
+	assert.Equal(t, "boolean", st.TypeOf("x"))
+	assert.Equal(t, 0, st.IndexOf("x"))
+	assert.Equal(t, KindField, st.KindOf("y"))
+	assert.Equal(t, 1, st.IndexOf("y"))
+	assert.Equal(t, "Point", st.TypeOf("other"))
+	assert.Equal(t, 1, st.IndexOf("other"))
+	assert.Equal(t, KindNone, st.KindOf("Math"))
+
+	st.StartSubroutine()
+	assert.Equal(t, 0, st.VarCount(KindArg))
+	assert.Equal(t, 0, st.VarCount(KindVar))
+	assert.Equal(t, KindField, st.KindOf("x"))
+	assert.Equal(t, KindNone, st.KindOf("other"))
+	assert.Equal(t, 2, st.VarCount(KindField), "class scope is kept")
+}
@@ -21,19 +21,24 @@ type ITokenizer interface {
 	Identifier() string
 	IntVal() int
 	StringVal() string
+	Line() int
 }
 
 type Tokenizer struct {
 	tokens []Token
-	jack   string
-	index  int
+	// lines holds the source line of each token.
+	lines []int
+	jack  string
+	index int
 }
 
 var _ ITokenizer = (*Tokenizer)(nil)
 
 func NewTokenizer(jack string) (*Tokenizer, error) {
 	tk := Tokenizer{jack: jack}
-	tk.parse()
+	if err := tk.parse(); err != nil {
+		return nil, err
+	}
 	return &tk, nil
 }
 
@@ -70,6 +75,11 @@ func (tk *Tokenizer) StringVal() string {
 	return tk.tokens[tk.index].value
 }
 
+// Line returns the line of the current token in the source file.
+func (tk *Tokenizer) Line() int {
+	return tk.lines[tk.index]
+}
+
 func (tk *Tokenizer) parse() error {
 	buf, err := os.ReadFile(tk.jack)
 	if err != nil {
@@ -81,7 +91,11 @@ func (tk *Tokenizer) parse() error {
 	comment1 := false
 	comment2 := false
 	stringConstant := false
+	line := 1
 	for i, r := range s {
+		if r == '\n' {
+			line++
+		}
 		if comment1 && r == '\n' {
 			comment1 = false
 			continue
@@ -104,7 +118,7 @@ func (tk *Tokenizer) parse() error {
 
 		if stringConstant && r == '"' {
 			stringConstant = false
-			tk.tokens = append(tk.tokens, Token{})
+			tk.appendToken(Token{}, line)
 			continue
 		} else if stringConstant {
 			token := tk.lastToken()
@@ -112,14 +126,14 @@ func (tk *Tokenizer) parse() error {
 			continue
 		} else if !stringConstant && r == '"' {
 			stringConstant = true
-			tk.tokens = append(tk.tokens, Token{tokenType: TkStringConst})
+			tk.appendToken(Token{tokenType: TkStringConst}, line)
 			continue
 		}
 
 		switch r {
 		case '\r', '\n', ' ', '	':
 			if !tk.lastTokenIsEmpty() {
-				tk.tokens = append(tk.tokens, Token{})
+				tk.appendToken(Token{}, line)
 			}
 		case '{', '}', '(', ')', '[', ']', '.', ',', ';',
 			'+', '-', '*', '/', '&', '|', '<', '>', '=', '~':
@@ -127,19 +141,22 @@ func (tk *Tokenizer) parse() error {
 				lastToken := tk.lastToken()
 				lastToken.tokenType = TkSymbol
 				lastToken.value = string([]rune{r})
+				tk.lines[len(tk.lines)-1] = line
 			} else {
-				tk.tokens = append(tk.tokens, Token{
+				tk.appendToken(Token{
 					tokenType: TkSymbol,
 					value:     string([]rune{r}),
-				})
+				}, line)
 			}
-			tk.tokens = append(tk.tokens, Token{})
+			tk.appendToken(Token{}, line)
 		default:
 			lastToken := tk.lastToken()
 			if lastToken == nil {
-				tk.tokens = append(tk.tokens, Token{value: string(r)})
-				lastToken = tk.lastToken()
+				tk.appendToken(Token{value: string(r)}, line)
 			} else {
+				if lastToken.value == "" {
+					tk.lines[len(tk.lines)-1] = line
+				}
 				lastToken.value += string(r)
 			}
 		}
@@ -153,6 +170,7 @@ func (tk *Tokenizer) finalizeTokens() {
 		token := &tk.tokens[i]
 		if token.value == "" {
 			tk.tokens = append(tk.tokens[0:i], tk.tokens[i+1:]...)
+			tk.lines = append(tk.lines[0:i], tk.lines[i+1:]...)
 			continue
 		} else if token.tokenType != "" {
 			continue
@@ -193,6 +211,11 @@ func (tk *Tokenizer) finalizeTokens() {
 	}
 }
 
+func (tk *Tokenizer) appendToken(t Token, line int) {
+	tk.tokens = append(tk.tokens, t)
+	tk.lines = append(tk.lines, line)
+}
+
 func (tk *Tokenizer) lastToken() *Token {
 	if len(tk.tokens) == 0 {
 		return nil
@@ -48,3 +48,23 @@ func TestTokenizerInterface(t *testing.T) {
 	}
 	assert.False(t, tk.HasMoreToken())
 }
+
+func TestTokenizerLine(t *testing.T) {
+	tk, err := NewTokenizer("./test/Square/Main.jack")
+	require.NoError(t, err)
+	lines := map[string]int{}
+	for ; tk.HasMoreToken(); tk.Advance() {
+		if _, ok := lines[tk.StringVal()]; !ok {
+			lines[tk.StringVal()] = tk.Line()
+		}
+	}
+	assert.Equal(t, 9, lines["class"])
+	assert.Equal(t, 10, lines["test"], "after a line comment")
+	assert.Equal(t, 12, lines["main"])
+	assert.Equal(t, 13, lines["SquareGame"])
+}
+
+func TestTokenizerMissingFile(t *testing.T) {
+	_, err := NewTokenizer("./test/Missing.jack")
+	assert.Error(t, err)
+}
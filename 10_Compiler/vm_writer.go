@@ -0,0 +1,121 @@
+package compiler
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+)
+
+type Segment string
+
+const (
+	SegConst   Segment = "constant"
+	SegArg     Segment = "argument"
+	SegLocal   Segment = "local"
+	SegStatic  Segment = "static"
+	SegThis    Segment = "this"
+	SegThat    Segment = "that"
+	SegPointer Segment = "pointer"
+	SegTemp    Segment = "temp"
+)
+
+type IVMWriter interface {
+	WritePush(segment Segment, index int)
+	WritePop(segment Segment, index int)
+	WriteArithmetic(command string)
+	WriteLabel(label string)
+	WriteGoto(label string)
+	WriteIf(label string)
+	WriteCall(name string, nArgs int)
+	WriteFunction(name string, nLocals int)
+	WriteReturn()
+}
+
+// VMWriter collects VM commands together with the line of the Jack source
+// each of them was compiled from.
+type VMWriter struct {
+	commands []string
+	lines    []int
+	line     int
+}
+
+var _ IVMWriter = (*VMWriter)(nil)
+
+func NewVMWriter() *VMWriter {
+	return &VMWriter{}
+}
+
+// SetLine sets the Jack line of the commands written next.
+func (w *VMWriter) SetLine(line int) {
+	w.line = line
+}
+
+func (w *VMWriter) WritePush(segment Segment, index int) {
+	w.write(fmt.Sprintf("push %s %d", segment, index))
+}
+
+func (w *VMWriter) WritePop(segment Segment, index int) {
+	w.write(fmt.Sprintf("pop %s %d", segment, index))
+}
+
+func (w *VMWriter) WriteArithmetic(command string) {
+	w.write(command)
+}
+
+func (w *VMWriter) WriteLabel(label string) {
+	w.write("label " + label)
+}
+
+func (w *VMWriter) WriteGoto(label string) {
+	w.write("goto " + label)
+}
+
+func (w *VMWriter) WriteIf(label string) {
+	w.write("if-goto " + label)
+}
+
+func (w *VMWriter) WriteCall(name string, nArgs int) {
+	w.write(fmt.Sprintf("call %s %d", name, nArgs))
+}
+
+func (w *VMWriter) WriteFunction(name string, nLocals int) {
+	w.write(fmt.Sprintf("function %s %d", name, nLocals))
+}
+
+func (w *VMWriter) WriteReturn() {
+	w.write("return")
+}
+
+func (w *VMWriter) write(command string) {
+	w.commands = append(w.commands, command)
+	w.lines = append(w.lines, w.line)
+}
+
+// WriteTo writes the VM commands, one per line.
+func (w *VMWriter) WriteTo(out io.Writer) (int64, error) {
+	var n int64
+	for _, c := range w.commands {
+		m, err := fmt.Fprintln(out, c)
+		n += int64(m)
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// WriteSourceMap writes a "VM:LINE JACK:LINE" line for every command, with
+// the Jack file relative to the directory of the VM file, in the format of
+// the VM translator's source maps.
+func (w *VMWriter) WriteSourceMap(out io.Writer, vm, jack string) error {
+	file, err := filepath.Rel(filepath.Dir(vm), jack)
+	if err != nil {
+		file = jack
+	}
+	for i, line := range w.lines {
+		if _, err := fmt.Fprintf(out, "%s:%d %s:%d\n", filepath.Base(vm), i+1, filepath.ToSlash(file), line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package compiler
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVMWriter(t *testing.T) {
+	w := NewVMWriter()
+	w.SetLine(3)
+	w.WriteFunction("Main.main", 1)
+	w.SetLine(4)
+	w.WritePush(SegConst, 7)
+	w.WritePop(SegLocal, 0)
+	w.SetLine(5)
+	w.WriteLabel("Main.main.WHILE_EXP0")
+	w.WritePush(SegLocal, 0)
+	w.WriteArithmetic("not")
+	w.WriteIf("Main.main.WHILE_END0")
+	w.WriteGoto("Main.main.WHILE_EXP0")
+	w.WriteCall("Math.multiply", 2)
+	w.WriteReturn()
+
+	var vm bytes.Buffer
+	_, err := w.WriteTo(&vm)
+	require.NoError(t, err)
+	assert.Equal(t, "function Main.main 1\n"+
+		"push constant 7\n"+
+		"pop local 0\n"+
+		"label Main.main.WHILE_EXP0\n"+
+		"push local 0\n"+
+		"not\n"+
+		"if-goto Main.main.WHILE_END0\n"+
+		"goto Main.main.WHILE_EXP0\n"+
+		"call Math.multiply 2\n"+
+		"return\n", vm.String())
+
+	var m bytes.Buffer
+	require.NoError(t, w.WriteSourceMap(&m, "out/Main.vm", "src/Main.jack"))
+	assert.Equal(t, "Main.vm:1 ../src/Main.jack:3\n"+
+		"Main.vm:2 ../src/Main.jack:4\n"+
+		"Main.vm:3 ../src/Main.jack:4\n"+
+		"Main.vm:4 ../src/Main.jack:5\n"+
+		"Main.vm:5 ../src/Main.jack:5\n"+
+		"Main.vm:6 ../src/Main.jack:5\n"+
+		"Main.vm:7 ../src/Main.jack:5\n"+
+		"Main.vm:8 ../src/Main.jack:5\n"+
+		"Main.vm:9 ../src/Main.jack:5\n"+
+		"Main.vm:10 ../src/Main.jack:5\n", m.String())
+}
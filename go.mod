@@ -9,4 +9,5 @@ require (
 	github.com/mattn/go-colorable v0.1.9 // indirect
 	github.com/rivo/tview v0.0.0-20210624165335-29d673af0ce2
 	github.com/stretchr/testify v1.7.0
+	golang.org/x/sys v0.0.0-20210309074719-68d13333faf2
 )